@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/evgfitil/qx/internal/config"
+	"github.com/evgfitil/qx/internal/llm"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the response cache",
+	Long: `The response cache serves repeated queries without calling the LLM.
+Enable it with llm.cache.enabled in the config file; --no-cache skips it
+for a single query.`,
+	SilenceErrors: true,
+	SilenceUsage:  true,
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove all cached responses",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCacheClear(os.Stdout)
+	},
+	SilenceErrors: true,
+	SilenceUsage:  true,
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheClearCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
+
+// runCacheClear removes every cached response and reports how many were
+// removed to w.
+func runCacheClear(w io.Writer) error {
+	dir, err := config.CacheDir()
+	if err != nil {
+		return err
+	}
+	n, err := llm.NewCache(dir, config.DefaultCacheTTL, config.DefaultCacheMaxEntries).Clear()
+	if err != nil {
+		return fmt.Errorf("failed to clear cache: %w", err)
+	}
+	fmt.Fprintf(w, "Removed %d cached responses\n", n)
+	return nil
+}
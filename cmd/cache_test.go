@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/evgfitil/qx/internal/config"
+	"github.com/evgfitil/qx/internal/llm"
+)
+
+func TestRunCacheClear(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	cache := llm.NewCache(filepath.Join(home, config.Dir, config.CacheSubdir), time.Hour, 10)
+	_ = cache.Put("a", []llm.Variant{{Command: "ls"}})
+	_ = cache.Put("b", []llm.Variant{{Command: "pwd"}})
+
+	var out bytes.Buffer
+	if err := runCacheClear(&out); err != nil {
+		t.Fatalf("runCacheClear() error = %v", err)
+	}
+	if got, want := out.String(), "Removed 2 cached responses\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+	if _, ok := cache.Get("a"); ok {
+		t.Error("cache entry should be removed")
+	}
+}
+
+func TestLLMConfig_NoCache(t *testing.T) {
+	withConfigContent(t, "llm:\n  cache:\n    enabled: true\n")
+	t.Cleanup(func() { noCacheFlag = false })
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if providerConfig(cfg).CacheDir == "" {
+		t.Error("CacheDir should be set when the cache is enabled")
+	}
+	noCacheFlag = true
+	if dir := providerConfig(cfg).CacheDir; dir != "" {
+		t.Errorf("CacheDir = %q with --no-cache, want empty", dir)
+	}
+}
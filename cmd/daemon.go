@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/evgfitil/qx/internal/config"
+	"github.com/evgfitil/qx/internal/daemon"
+	"github.com/evgfitil/qx/pkg/qx"
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Keep providers and connections warm for faster queries",
+	Long: `Daemon runs in the foreground and answers qx queries over a Unix socket
+that only the current user can open, so each query skips loading a client
+and opening a new TLS connection:
+  qx daemon &
+
+The socket is $QX_DAEMON_SOCKET, or qx/daemon.sock in $XDG_RUNTIME_DIR,
+or ~/.config/qx/daemon.sock. Without a running daemon qx generates
+in-process as usual. Stop it with Ctrl+C or SIGTERM.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return runDaemon(ctx)
+	},
+	SilenceErrors: true,
+	SilenceUsage:  true,
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+}
+
+// runDaemon serves requests on the user's socket until ctx is done. The
+// provider for the configured model is created and connected up front.
+func runDaemon(ctx context.Context) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	path, err := daemon.SocketPath()
+	if err != nil {
+		return err
+	}
+	ln, err := daemon.Listen(path)
+	if err != nil {
+		return err
+	}
+
+	providerCfg := cfg.LLM.ToProviderConfig()
+	if _, err := qx.NewProvider(providerCfg); err != nil {
+		_ = ln.Close()
+		return fmt.Errorf("failed to create LLM provider: %w", err)
+	}
+	go func() {
+		prewarmCtx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+		defer cancel()
+		_ = qx.Prewarm(prewarmCtx, providerCfg)
+	}()
+
+	fmt.Fprintf(os.Stderr, "qx daemon listening on %s\n", path)
+	return (&daemon.Server{Timeout: config.DefaultTimeout}).Serve(ctx, ln)
+}
+
+// newProvider sends requests to the daemon when one is running and
+// generates in-process otherwise.
+func newProvider(cfg qx.ProviderConfig) (qx.Provider, error) {
+	path, err := daemon.SocketPath()
+	if err != nil {
+		return qx.NewProvider(cfg)
+	}
+	return daemon.NewProviderFunc(path, qx.NewProvider)(cfg)
+}
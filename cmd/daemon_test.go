@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/evgfitil/qx/internal/daemon"
+	"github.com/evgfitil/qx/pkg/qx"
+	"github.com/evgfitil/qx/pkg/qx/qxtest"
+)
+
+func TestRunDaemon(t *testing.T) {
+	withConfigContent(t, "llm:\n  base_url: http://127.0.0.1:1\n")
+	path := filepath.Join(t.TempDir(), "daemon.sock")
+	t.Setenv(daemon.EnvSocket, path)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- runDaemon(ctx) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if conn, err := net.Dial("unix", path); err == nil {
+			_ = conn.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("daemon did not start listening")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("runDaemon() error = %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("the socket should be removed on shutdown")
+	}
+}
+
+func TestNewProvider_UsesDaemon(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "daemon.sock")
+	t.Setenv(daemon.EnvSocket, path)
+	ln, err := daemon.Listen(path)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	f := &qxtest.Provider{Variants: []qx.Variant{{Command: "ls -la"}}}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- (&daemon.Server{NewProvider: func(qx.ProviderConfig) (qx.Provider, error) { return f, nil }}).Serve(ctx, ln)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	p, err := newProvider(qx.ProviderConfig{APIKey: "test-key", Model: "test-model"})
+	if err != nil {
+		t.Fatalf("newProvider() error = %v", err)
+	}
+	variants, err := p.Generate(context.Background(), qx.ProviderRequest{Query: "list files", Count: 1})
+	if err != nil || len(variants) != 1 || variants[0].Command != "ls -la" {
+		t.Errorf("Generate() = %+v, %v, want the daemon's variants", variants, err)
+	}
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/cobra"
+
+	"github.com/evgfitil/qx/internal/config"
+	"github.com/evgfitil/qx/internal/guard"
+	"github.com/evgfitil/qx/internal/tui"
+	"github.com/evgfitil/qx/pkg/qx"
+)
+
+var explainCmd = &cobra.Command{
+	Use:   "explain <command>",
+	Short: "Explain what a shell command does",
+	Long: `Explain breaks a shell command down into its pipeline stages and flags
+and rates how risky it is to run:
+  qx explain 'find . -name "*.log" -mtime +7 -delete'
+
+Everything after "explain" is taken as the command, so quoting is optional.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateContextFlags(); err != nil {
+			return err
+		}
+		return runExplain(strings.Join(args, " "), os.Stdout)
+	},
+	SilenceErrors: true,
+	SilenceUsage:  true,
+}
+
+func init() {
+	// Flags of the explained command must not be parsed as qx flags.
+	explainCmd.Flags().SetInterspersed(false)
+	rootCmd.AddCommand(explainCmd)
+}
+
+// runExplain asks the LLM to explain command and writes the breakdown
+// together with its risk classification to w.
+func runExplain(command string, w io.Writer) error {
+	if err := guard.CheckQuery(command, forceSend); err != nil {
+		return err
+	}
+
+	shell, err := targetShell()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	client, err := newClient(cfg, shell)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.DefaultTimeout)
+	defer cancel()
+
+	explanation, err := client.Explain(ctx, command)
+	if err != nil {
+		return err
+	}
+
+	theme := cfg.Theme.ToTheme().WithRenderer(lipgloss.NewRenderer(w))
+	_, err = fmt.Fprint(w, tui.RenderExplanation(command, explanation, qx.ClassifyRisk(command), theme))
+	return err
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/evgfitil/qx/internal/guard"
+	"github.com/evgfitil/qx/internal/lastcmd"
+	"github.com/evgfitil/qx/pkg/qx"
+)
+
+// fixQuery is sent when qx fix is run without a hint.
+const fixQuery = "Fix the failed command"
+
+var fixCmd = &cobra.Command{
+	Use:   "fix [hint]",
+	Short: "Fix the last failed command",
+	Long: `Fix sends the last command run in the shell, its exit code and its
+captured error output to the LLM and offers corrected variants:
+  qx fix
+  qx fix "the branch is called trunk"
+
+The command is recorded by the shell integration; set QX_CAPTURE_STDERR=1
+before loading it to capture error output as well.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := targetShell(); err != nil {
+			return err
+		}
+		if err := validateContextFlags(); err != nil {
+			return err
+		}
+		hint := ""
+		if len(args) > 0 {
+			hint = args[0]
+		}
+		return runFix(hint)
+	},
+	SilenceErrors: true,
+	SilenceUsage:  true,
+}
+
+func init() {
+	rootCmd.AddCommand(fixCmd)
+}
+
+// runFix generates corrected variants of the last failed command. hint,
+// when set, replaces the default query.
+func runFix(hint string) error {
+	dir := os.Getenv(lastcmd.EnvDir)
+	if dir == "" {
+		return fmt.Errorf("no recorded command: load the shell integration (qx --shell-integration bash|zsh|fish)")
+	}
+
+	rec, err := lastcmd.Load(dir)
+	if errors.Is(err, lastcmd.ErrNotRecorded) {
+		return fmt.Errorf("no recorded command: run a command first")
+	}
+	if err != nil {
+		return err
+	}
+	if rec.ExitCode == 0 {
+		return fmt.Errorf("the last command succeeded, nothing to fix: %s", rec.Command)
+	}
+
+	if err := guard.CheckQuery(rec.Command+"\n"+rec.ErrorOutput, forceSend); err != nil {
+		return err
+	}
+
+	query := hint
+	if query == "" {
+		query = fixQuery
+	}
+	return generateCommandsFn(query, "", &qx.FollowUp{
+		PreviousQuery:   generatedBy(rec.Command),
+		PreviousCommand: rec.Command,
+		ExitCode:        rec.ExitCode,
+		ErrorOutput:     rec.ErrorOutput,
+	})
+}
+
+// generatedBy returns the query that produced command according to the
+// history, or an empty string if the user wrote it.
+func generatedBy(command string) string {
+	store, err := newHistoryStore()
+	if err != nil {
+		return ""
+	}
+	entries, _ := store.List()
+	for _, e := range entries {
+		if e.Selected == command {
+			return e.Query
+		}
+	}
+	return ""
+}
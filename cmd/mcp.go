@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/evgfitil/qx/internal/config"
+	"github.com/evgfitil/qx/internal/mcp"
+)
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Serve qx tools to AI agents over the Model Context Protocol",
+	Long: `MCP speaks the Model Context Protocol on stdin and stdout, so coding
+agents can use qx as a tool. Register it with the agent as a stdio server
+running "qx mcp". The tools are:
+  generate_command   generate command variants with confidence and risk
+  explain_command    explain a command stage by stage
+  check_secrets      check text for secrets
+  search_history     search past queries and picked commands
+
+Queries with secrets are rejected, as in the CLI.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return runMCP(ctx, os.Stdin, os.Stdout)
+	},
+	SilenceErrors: true,
+	SilenceUsage:  true,
+}
+
+func init() {
+	rootCmd.AddCommand(mcpCmd)
+}
+
+// runMCP answers MCP messages from r on w until r ends.
+func runMCP(ctx context.Context, r io.Reader, w io.Writer) error {
+	shell, err := targetShell()
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	client, err := newServiceClient(cfg, shell)
+	if err != nil {
+		return err
+	}
+
+	return mcp.NewServer(mcp.Options{
+		Client:  client,
+		Timeout: config.DefaultTimeout,
+		Version: Version,
+	}).Serve(ctx, r, w)
+}
@@ -6,17 +6,28 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/evgfitil/qx/internal/action"
 	"github.com/evgfitil/qx/internal/config"
+	"github.com/evgfitil/qx/internal/contextfile"
+	"github.com/evgfitil/qx/internal/environment"
+	"github.com/evgfitil/qx/internal/gitcontext"
 	"github.com/evgfitil/qx/internal/guard"
 	"github.com/evgfitil/qx/internal/history"
+	"github.com/evgfitil/qx/internal/keymap"
+	"github.com/evgfitil/qx/internal/lastcmd"
 	"github.com/evgfitil/qx/internal/llm"
+	"github.com/evgfitil/qx/internal/pipeinput"
+	"github.com/evgfitil/qx/internal/placeholder"
+	"github.com/evgfitil/qx/internal/rank"
 	"github.com/evgfitil/qx/internal/shell"
 	"github.com/evgfitil/qx/internal/tui"
+	"github.com/evgfitil/qx/pkg/qx"
 )
 
 const ExitCodeCancelled = 130
@@ -30,22 +41,54 @@ var (
 	lastFlag         bool
 	historyFlag      bool
 	continueFlag     bool
+	showContextFlag  bool
+	shellFlag        string
+	contextFiles     []string
+	contextFlag      []string
+	noContextFlag    []string
+	scriptFlag       bool
+	noCacheFlag      bool
 )
 
+// fixRounds counts the fix-and-retry rounds taken in this run; the loop
+// stops offering a fix after iterate.max_iterations rounds.
+var fixRounds int
+
 // ErrCancelled indicates user cancelled the operation.
 var ErrCancelled = errors.New("operation cancelled")
 
 // Overridable function references for testing.
 var (
-	shouldPromptFn       = action.ShouldPrompt
-	shouldPromptStderrFn = action.ShouldPromptStderr
-	promptActionFn       = action.PromptAction
-	readRefinementFn     = action.ReadRefinement
-	generateCommandsFn   func(query string, pipeContext string, followUp *llm.FollowUpContext) error
-	uiRunFn              = tui.Run
-	uiRunSelectorFn      = tui.RunSelector
+	shouldPromptFn           = action.ShouldPrompt
+	shouldPromptStderrFn     = action.ShouldPromptStderr
+	promptActionFn           = action.PromptAction
+	promptFixFn              = action.PromptFix
+	readRefinementFn         = action.ReadRefinement
+	generateCommandsFn       func(query string, pipeContext string, followUp *qx.FollowUp) error
+	explainFn                = func(command string) error { return runExplain(command, os.Stderr) }
+	newProviderFn            = newProvider
+	uiRunFn                  = tui.Run
+	uiRunSelectorFn          = tui.RunSelector
+	uiRunRankedSelectorFn    = tui.RunRankedSelector
+	uiRunScriptFn            = tui.RunScript
+	uiRunFormFn              = tui.RunForm
+	confirmStepFn            = action.ConfirmStep
+	executeFn                = action.Execute
+	newEnvironmentProviderFn = func(opts environment.Options) qx.ContextProvider {
+		return environment.NewProvider(opts)
+	}
+	newGitProviderFn = func(commits int) qx.ContextProvider {
+		return &gitcontext.Provider{Commits: commits, ForceSend: forceSend}
+	}
 )
 
+// contextTimeout bounds how long collecting context may delay a query.
+const contextTimeout = 5 * time.Second
+
+// optionalContexts are the context providers that can be switched per
+// query with --context and --no-context.
+var optionalContexts = []string{"environment", "git"}
+
 var rootCmd = &cobra.Command{
 	Use:   "qx [query]",
 	Short: "Generate shell commands using LLM",
@@ -56,7 +99,11 @@ After selecting a command, choose an action: execute it, copy to clipboard, or p
 
 Pipe command output into qx to provide context for more precise command generation:
   ls -la | qx "delete files larger than 1GB"
-  docker ps | qx "stop all nginx containers"`,
+  docker ps | qx "stop all nginx containers"
+
+Use --script for tasks that take several commands; the steps can be reviewed,
+run one at a time or saved as an executable script:
+  qx --script "back up the app database and upload it to s3"`,
 	Version:       Version,
 	Args:          cobra.MaximumNArgs(1),
 	RunE:          run,
@@ -70,12 +117,19 @@ func init() {
 	rootCmd.Flags().StringVar(&shellIntegration, "shell-integration", "", "output shell integration script (bash|zsh|fish)")
 	rootCmd.Flags().BoolVar(&showConfig, "config", false, "show config file path")
 	rootCmd.Flags().StringVarP(&queryFlag, "query", "q", "", "initial query for TUI input (pre-fills the input field)")
-	rootCmd.Flags().BoolVar(&forceSend, "force-send", false, "send query even if secrets detected")
+	rootCmd.PersistentFlags().BoolVar(&forceSend, "force-send", false, "send query even if secrets detected")
 	rootCmd.Flags().BoolVarP(&lastFlag, "last", "l", false, "show last selected command")
 	rootCmd.Flags().BoolVar(&historyFlag, "history", false, "browse command history with interactive picker")
 	rootCmd.Flags().BoolVarP(&continueFlag, "continue", "c", false, "refine the last command with a new query")
-
-	rootCmd.MarkFlagsMutuallyExclusive("last", "history", "continue")
+	rootCmd.PersistentFlags().StringVar(&shellFlag, "shell", "", "shell dialect to generate commands for (bash|zsh|fish); defaults to $QX_SHELL")
+	rootCmd.PersistentFlags().StringArrayVar(&contextFiles, "context-file", nil, "attach a project file to the prompt (repeatable, globs allowed)")
+	rootCmd.PersistentFlags().StringArrayVar(&contextFlag, "context", nil, "send an optional context with this query (environment|git, repeatable)")
+	rootCmd.PersistentFlags().StringArrayVar(&noContextFlag, "no-context", nil, "do not send an optional context with this query (environment|git, repeatable)")
+	rootCmd.Flags().BoolVar(&showContextFlag, "show-context", false, "print the extra context sent with queries and exit")
+	rootCmd.Flags().BoolVar(&scriptFlag, "script", false, "generate a multi-step script to review, then run step by step or save")
+	rootCmd.PersistentFlags().BoolVar(&noCacheFlag, "no-cache", false, "do not read or write the response cache for this query")
+
+	rootCmd.MarkFlagsMutuallyExclusive("last", "history", "continue", "script")
 }
 
 // Execute runs the root command
@@ -93,6 +147,18 @@ func run(cmd *cobra.Command, args []string) error {
 		return handleShellIntegration(shellIntegration)
 	}
 
+	if _, err := targetShell(); err != nil {
+		return err
+	}
+
+	if err := validateContextFlags(); err != nil {
+		return err
+	}
+
+	if showContextFlag {
+		return runShowContext()
+	}
+
 	if lastFlag {
 		return runLast()
 	}
@@ -101,17 +167,39 @@ func run(cmd *cobra.Command, args []string) error {
 		return runHistory()
 	}
 
-	pipeContext, err := readStdin()
+	in, err := readStdin()
 	if err != nil {
 		return err
 	}
 
+	var pipe pipeinput.Result
+	if in.Bytes > 0 {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		pipe = reducePipeInput(in, cfg)
+	}
+	pipeContext := pipe.Text
+
 	if pipeContext != "" {
 		if err := guard.CheckQuery(pipeContext, forceSend); err != nil {
 			return err
 		}
 	}
 
+	if scriptFlag && len(args) == 0 {
+		return fmt.Errorf("--script requires a query argument")
+	}
+
+	if len(args) == 0 && !continueFlag {
+		return runInteractive(queryFlag, pipe)
+	}
+
+	if pipe.Reduced {
+		fmt.Fprintf(os.Stderr, "Note: piped input %s\n", pipe.Summary())
+	}
+
 	if continueFlag {
 		if len(args) == 0 {
 			return fmt.Errorf("--continue requires a query argument")
@@ -119,16 +207,17 @@ func run(cmd *cobra.Command, args []string) error {
 		return runContinue(args[0], pipeContext)
 	}
 
-	if len(args) == 0 {
-		return runInteractive(queryFlag, pipeContext)
+	if scriptFlag {
+		return runScript(args[0], pipeContext)
 	}
 
 	query := args[0]
 	return generateCommands(query, pipeContext, nil)
 }
 
-func runInteractive(initialQuery string, pipeContext string) error {
-	initialQuery = llm.UnformatCommand(initialQuery)
+func runInteractive(initialQuery string, pipe pipeinput.Result) error {
+	shell, _ := targetShell()
+	initialQuery = llm.UnformatCommand(initialQuery, shell)
 
 	cfg, err := config.Load()
 	if err != nil {
@@ -139,12 +228,30 @@ func runInteractive(initialQuery string, pipeContext string) error {
 		return ErrCancelled
 	}
 
+	client, err := newClient(cfg, shell)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if initialQuery != "" {
+			fmt.Println(initialQuery)
+		}
+		return ErrCancelled
+	}
+
 	result, err := uiRunFn(tui.RunOptions{
 		InitialQuery: initialQuery,
-		LLMConfig:    cfg.LLM.ToLLMConfig(),
 		ForceSend:    forceSend,
-		PipeContext:  pipeContext,
+		PipeContext:  pipe.Text,
+		PipeSummary:  pipeSummary(pipe),
 		Theme:        cfg.Theme.ToTheme(),
+		Preview:      cfg.Preview.ToPreviewOptions(),
+		Keys:         cfg.Keys.ToKeyMap(),
+		CharLimit:    cfg.Input.CharLimit,
+		Shell:        shell,
+		History:      loadQueryHistory(),
+		Accepted:     loadAcceptedCommands(),
+		Prewarm:      cfg.LLM.Prewarm,
+		LLMConfig:    providerConfig(cfg),
+		Client:       client,
 	})
 	if err != nil {
 		return err
@@ -158,7 +265,7 @@ func runInteractive(initialQuery string, pipeContext string) error {
 		return ErrCancelled
 	case tui.SelectedResult:
 		if r.Command != "" {
-			return handleSelectedCommand(r.Command, r.Query, pipeContext, cfg.ActionMenu)
+			return handleSelectedCommand(r.Command, r.Query, pipe.Text, newMenuOptions(cfg))
 		}
 		return nil
 	default:
@@ -180,9 +287,9 @@ func handleShellIntegration(shellName string) error {
 // Config errors are non-fatal: action_menu defaults to false since runLast
 // does not need LLM credentials.
 func runLast() error {
-	actionMenu := false
+	menu := menuOptions{keys: keymap.Default(), theme: tui.DefaultTheme()}
 	if cfg, err := config.Load(); err == nil {
-		actionMenu = cfg.ActionMenu
+		menu = newMenuOptions(cfg)
 	}
 
 	store, err := newHistoryStore()
@@ -198,7 +305,7 @@ func runLast() error {
 		return fmt.Errorf("failed to read history: %w", err)
 	}
 
-	return handleSelectedCommand(entry.Selected, entry.Query, entry.PipeContext, actionMenu)
+	return handleSelectedCommand(entry.Selected, entry.Query, entry.PipeContext, menu)
 }
 
 // runHistory loads all history entries and presents an interactive picker.
@@ -223,12 +330,14 @@ func runHistory() error {
 	}
 
 	theme := tui.DefaultTheme()
+	keys := keymap.Default()
 	if cfg, loadErr := config.Load(); loadErr == nil {
 		theme = cfg.Theme.ToTheme()
+		keys = cfg.Keys.ToKeyMap()
 	}
 	idx, err := uiRunSelectorFn(items, func(i int) string {
 		return items[i]
-	}, theme)
+	}, theme, keys)
 	if err != nil {
 		return fmt.Errorf("failed to pick from history: %w", err)
 	}
@@ -237,7 +346,7 @@ func runHistory() error {
 		return ErrCancelled
 	}
 
-	return handleSelectedCommand(entries[idx].Selected, entries[idx].Query, entries[idx].PipeContext, true)
+	return handleSelectedCommand(entries[idx].Selected, entries[idx].Query, entries[idx].PipeContext, menuOptions{enabled: true, keys: keys, theme: theme})
 }
 
 // runContinue loads the last history entry and uses it as follow-up context
@@ -256,7 +365,7 @@ func runContinue(query string, pipeContext string) error {
 		return fmt.Errorf("failed to read history: %w", err)
 	}
 
-	followUp := &llm.FollowUpContext{
+	followUp := &qx.FollowUp{
 		PreviousQuery:   entry.Query,
 		PreviousCommand: entry.Selected,
 	}
@@ -264,6 +373,108 @@ func runContinue(query string, pipeContext string) error {
 	return generateCommands(query, pipeContext, followUp)
 }
 
+// targetShell returns the shell dialect from --shell, falling back to
+// $QX_SHELL, which the shell integration scripts and users can set.
+func targetShell() (llm.Shell, error) {
+	name := shellFlag
+	if name == "" {
+		name = os.Getenv("QX_SHELL")
+	}
+	return llm.ParseShell(name)
+}
+
+// validateContextFlags checks the names given with --context and
+// --no-context.
+func validateContextFlags() error {
+	for _, name := range slices.Concat(contextFlag, noContextFlag) {
+		if !slices.Contains(optionalContexts, name) {
+			return fmt.Errorf("unknown context provider %q (supported: %s)", name, strings.Join(optionalContexts, ", "))
+		}
+	}
+	return nil
+}
+
+// enabledContexts returns which optional context providers are enabled
+// for this query: the config settings, overridden by --context and
+// --no-context.
+func enabledContexts(cfg *config.Config) (map[string]bool, error) {
+	if err := validateContextFlags(); err != nil {
+		return nil, err
+	}
+	enabled := map[string]bool{
+		"environment": cfg.Context.Environment,
+		"git":         cfg.Context.Git,
+	}
+	for _, name := range contextFlag {
+		enabled[name] = true
+	}
+	for _, name := range noContextFlag {
+		enabled[name] = false
+	}
+	return enabled, nil
+}
+
+// contextProviders returns the providers enabled for this query in the
+// order their blocks are sent. Context files are always included; they
+// are switched by --context-file and the discovery setting.
+func contextProviders(cfg *config.Config, shell llm.Shell) ([]qx.ContextProvider, error) {
+	enabled, err := enabledContexts(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var providers []qx.ContextProvider
+	if enabled["environment"] {
+		providers = append(providers, newEnvironmentProviderFn(environment.Options{Shell: string(shell), Tools: cfg.Context.Tools}))
+	}
+	if enabled["git"] {
+		providers = append(providers, newGitProviderFn(cfg.Context.GitCommits))
+	}
+	providers = append(providers, contextfile.NewProvider(contextfile.Options{
+		Patterns:  contextFiles,
+		Discover:  cfg.Context.Discover,
+		Budget:    cfg.Context.FilesBudget,
+		ForceSend: forceSend,
+	}, func(w string) {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+	}))
+	return providers, nil
+}
+
+// contextBlocks collects the extra context enabled for this query.
+// Context files that were truncated or skipped are reported on stderr.
+func contextBlocks(cfg *config.Config) ([]qx.ContextBlock, error) {
+	shell, _ := targetShell()
+	providers, err := contextProviders(cfg, shell)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), contextTimeout)
+	defer cancel()
+	return qx.CollectContext(ctx, providers)
+}
+
+// runShowContext prints the context blocks exactly as they are sent with
+// each query, so users can check what leaves their machine.
+func runShowContext() error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	blocks, err := contextBlocks(cfg)
+	if err != nil {
+		return err
+	}
+	if len(blocks) == 0 {
+		fmt.Fprintln(os.Stderr, "No extra context is sent with queries (see the context section of the config).")
+		return nil
+	}
+	fmt.Print(qx.FormatContext(blocks))
+	return nil
+}
+
 // formatHistoryEntry formats a history entry for display in the picker.
 func formatHistoryEntry(e history.Entry) string {
 	ts := e.Timestamp.Format("Jan 02 15:04")
@@ -271,7 +482,7 @@ func formatHistoryEntry(e history.Entry) string {
 }
 
 // generateCommands generates shell commands using LLM based on user query.
-func generateCommands(query string, pipeContext string, followUp *llm.FollowUpContext) error {
+func generateCommands(query string, pipeContext string, followUp *qx.FollowUp) error {
 	if err := guard.CheckQuery(query, forceSend); err != nil {
 		return err
 	}
@@ -281,34 +492,33 @@ func generateCommands(query string, pipeContext string, followUp *llm.FollowUpCo
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	provider, err := llm.NewProvider(cfg.LLM.ToLLMConfig())
+	shell, _ := targetShell()
+	client, err := newClient(cfg, shell)
 	if err != nil {
-		return fmt.Errorf("failed to create LLM provider: %w", err)
+		return err
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), config.DefaultTimeout)
 	defer cancel()
 
-	commands, err := provider.Generate(ctx, query, cfg.LLM.Count, pipeContext, followUp)
+	variants, err := client.Generate(ctx, qx.Request{
+		Query:       query,
+		PipeContext: pipeContext,
+		FollowUp:    followUp,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to generate commands: %w", err)
-	}
-
-	for i, cmd := range commands {
-		commands[i] = guard.SanitizeOutput(cmd)
+		return err
 	}
-
-	if len(commands) == 0 {
-		return fmt.Errorf("no commands generated")
+	if variants[0].Cached {
+		fmt.Fprintln(os.Stderr, "Using cached results (--no-cache to regenerate)")
 	}
 
-	if len(commands) == 1 {
-		return handleSelectedCommand(commands[0], query, pipeContext, cfg.ActionMenu)
+	if len(variants) == 1 {
+		return handleSelectedCommand(variants[0].Command, query, pipeContext, newMenuOptions(cfg))
 	}
 
-	idx, err := uiRunSelectorFn(commands, func(i int) string {
-		return commands[i]
-	}, cfg.Theme.ToTheme())
+	ranked := rank.Rank(variants, rank.Options{Shell: shell, Accepted: loadAcceptedCommands()})
+	idx, err := uiRunRankedSelectorFn(ranked, cfg.Theme.ToTheme(), cfg.Keys.ToKeyMap())
 	if err != nil {
 		return fmt.Errorf("failed to pick command: %w", err)
 	}
@@ -318,7 +528,66 @@ func generateCommands(query string, pipeContext string, followUp *llm.FollowUpCo
 		return ErrCancelled
 	}
 
-	return handleSelectedCommand(commands[idx], query, pipeContext, cfg.ActionMenu)
+	return handleSelectedCommand(ranked[idx].Command, query, pipeContext, newMenuOptions(cfg))
+}
+
+// newClient creates the client that generates commands for shell from
+// the loaded config and the command-line flags.
+func newClient(cfg *config.Config, shell llm.Shell) (*qx.Client, error) {
+	providers, err := contextProviders(cfg, shell)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := newProviderFn(providerConfig(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create LLM provider: %w", err)
+	}
+	return qx.New(
+		qx.WithProvider(provider),
+		qx.WithGuardPolicy(guardPolicy()),
+		qx.WithContextProviders(providers...),
+		qx.WithContextTimeout(contextTimeout),
+		qx.WithShell(qx.Shell(shell)),
+		qx.WithCount(cfg.LLM.Count),
+	)
+}
+
+// newServiceClient creates the client behind qx serve and qx mcp. It
+// always blocks secrets, since their callers cannot pass --force-send,
+// and reads the history of the CLI.
+func newServiceClient(cfg *config.Config, shell llm.Shell) (*qx.Client, error) {
+	provider, err := newProviderFn(providerConfig(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create LLM provider: %w", err)
+	}
+	opts := []qx.Option{
+		qx.WithProvider(provider),
+		qx.WithGuardPolicy(qx.GuardBlock),
+		qx.WithShell(qx.Shell(shell)),
+		qx.WithCount(cfg.LLM.Count),
+	}
+	if store, err := newHistoryStore(); err == nil {
+		opts = append(opts, qx.WithHistory(store.Dir()))
+	}
+	return qx.New(opts...)
+}
+
+// guardPolicy returns the secrets policy set by --force-send.
+func guardPolicy() qx.GuardPolicy {
+	if forceSend {
+		return qx.GuardAllow
+	}
+	return qx.GuardBlock
+}
+
+// providerConfig returns the provider configuration, without the response
+// cache when --no-cache is set.
+func providerConfig(cfg *config.Config) qx.ProviderConfig {
+	c := cfg.LLM.ToProviderConfig()
+	if noCacheFlag {
+		c.CacheDir = ""
+	}
+	return c
 }
 
 // newHistoryStore creates a history store using the default config directory.
@@ -331,6 +600,38 @@ var newHistoryStore = func() (*history.Store, error) {
 	return history.NewStore(filepath.Join(home, config.Dir)), nil
 }
 
+// newPlaceholderStore creates a store for remembered placeholder values in
+// the default config directory. Overridden in tests to use a temp directory.
+var newPlaceholderStore = func() (*placeholder.Store, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	return placeholder.NewStore(filepath.Join(home, config.Dir)), nil
+}
+
+// loadQueryHistory returns past queries, newest first, for recall in the
+// TUI input. Errors are ignored for the same reason as in saveToHistory.
+func loadQueryHistory() []string {
+	store, err := newHistoryStore()
+	if err != nil {
+		return nil
+	}
+	queries, _ := store.Queries()
+	return queries
+}
+
+// loadAcceptedCommands returns the commands picked before, newest first,
+// or nil when history is unavailable.
+func loadAcceptedCommands() []string {
+	store, err := newHistoryStore()
+	if err != nil {
+		return nil
+	}
+	commands, _ := store.Selected()
+	return commands
+}
+
 // saveToHistory persists a history entry. Errors are silently ignored
 // because history is a convenience feature that should not break the main flow.
 func saveToHistory(entry history.Entry) {
@@ -341,19 +642,52 @@ func saveToHistory(entry history.Entry) {
 	_ = store.Add(entry)
 }
 
-// handleSelectedCommand either shows the post-selection action menu or
-// prints the command to stdout. The action menu is shown when actionMenu
+// menuOptions controls the post-selection action menu and the
+// placeholder form shown before it.
+type menuOptions struct {
+	enabled bool
+	keys    keymap.KeyMap
+	theme   tui.Theme
+	// fixesLeft is how many more times a failed execution may be fixed
+	// and retried; zero disables the loop.
+	fixesLeft int
+}
+
+// newMenuOptions builds menu options from the loaded config.
+func newMenuOptions(cfg *config.Config) menuOptions {
+	menu := menuOptions{
+		enabled: cfg.ActionMenu,
+		keys:    cfg.Keys.ToKeyMap(),
+		theme:   cfg.Theme.ToTheme(),
+	}
+	if cfg.Iterate.Enabled {
+		menu.fixesLeft = max(cfg.Iterate.MaxIterations-fixRounds, 0)
+	}
+	return menu
+}
+
+// handleSelectedCommand first asks for the values of any placeholders in
+// the command, then either shows the post-selection action menu or
+// prints the command to stdout. The action menu is shown when menu.enabled
 // is true AND a TTY is available (stdout first, then stderr as fallback
-// for shell integration mode where stdout is captured). When the user chooses "revise",
-// it reads a refinement query and starts a new generation cycle with
-// follow-up context. History is saved only on the final action
+// for shell integration mode where stdout is captured). When the user chooses
+// "explain", it prints the explanation and shows the menu again. When the
+// user chooses "revise", it reads a refinement query and starts a new
+// generation cycle with follow-up context. When an executed command fails
+// and fixes are left, it offers to send the failure to the LLM and pick a
+// corrected command. History is saved only on the final action
 // (execute/copy/quit), not on intermediate revisions.
-func handleSelectedCommand(command, query, pipeContext string, actionMenu bool) error {
+func handleSelectedCommand(command, query, pipeContext string, menu menuOptions) error {
+	command, err := fillPlaceholders(command, menu)
+	if err != nil {
+		return err
+	}
+
 	showMenu := shouldPromptFn()
-	if !showMenu && actionMenu {
+	if !showMenu && menu.enabled {
 		showMenu = shouldPromptStderrFn()
 	}
-	if !actionMenu || !showMenu {
+	if !menu.enabled || !showMenu {
 		saveToHistory(history.Entry{
 			Query:       query,
 			Selected:    command,
@@ -364,7 +698,15 @@ func handleSelectedCommand(command, query, pipeContext string, actionMenu bool)
 		return nil
 	}
 
-	err := promptActionFn(command)
+	opts := action.Options{Keys: menu.keys, CaptureStderr: menu.fixesLeft > 0}
+	err = promptActionFn(command, opts)
+	var explainReq *action.ExplainRequestedError
+	for errors.As(err, &explainReq) {
+		if explainErr := explainFn(command); explainErr != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", explainErr)
+		}
+		err = promptActionFn(command, opts)
+	}
 	if errors.Is(err, action.ErrCancelled) {
 		return ErrCancelled
 	}
@@ -378,7 +720,7 @@ func handleSelectedCommand(command, query, pipeContext string, actionMenu bool)
 			}
 			return readErr
 		}
-		followUp := &llm.FollowUpContext{
+		followUp := &qx.FollowUp{
 			PreviousQuery:   query,
 			PreviousCommand: command,
 		}
@@ -391,5 +733,70 @@ func handleSelectedCommand(command, query, pipeContext string, actionMenu bool)
 		PipeContext: pipeContext,
 		Timestamp:   time.Now(),
 	})
+
+	var exitErr *action.ExitError
+	if menu.fixesLeft > 0 && errors.As(err, &exitErr) {
+		return fixAndRetry(command, query, pipeContext, exitErr, menu.keys)
+	}
 	return err
 }
+
+// fillPlaceholders asks for a value for each placeholder in command and
+// returns the command with the values substituted, quoted for the target
+// shell. Without a terminal the command is returned unchanged. If the user
+// cancels, the unfilled command is printed so the shell integration can
+// put it in the prompt for editing.
+func fillPlaceholders(command string, menu menuOptions) (string, error) {
+	if !placeholder.Has(command) || !(shouldPromptFn() || shouldPromptStderrFn()) {
+		return command, nil
+	}
+
+	var recent map[string][]string
+	store, err := newPlaceholderStore()
+	if err == nil {
+		recent, _ = store.Recent()
+	}
+
+	shell, _ := targetShell()
+	values, ok, err := uiRunFormFn(command, tui.FormOptions{
+		Theme:  menu.theme,
+		Keys:   menu.keys,
+		Shell:  shell,
+		Recent: recent,
+	})
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		fmt.Println(command)
+		return "", ErrCancelled
+	}
+
+	if store != nil {
+		_ = store.Add(values)
+	}
+	return placeholder.Substitute(command, values, shell), nil
+}
+
+// fixAndRetry offers to fix command, which failed with exitErr. If the
+// user accepts, the failed command and its error output are sent as
+// follow-up context and a new generation cycle starts; otherwise exitErr
+// is returned so qx exits with the command's exit code.
+func fixAndRetry(command, query, pipeContext string, exitErr *action.ExitError, keys keymap.KeyMap) error {
+	fix, err := promptFixFn(exitErr.Code, keys)
+	if err != nil || !fix {
+		return exitErr
+	}
+
+	followUp := &qx.FollowUp{
+		PreviousQuery:   query,
+		PreviousCommand: command,
+		ExitCode:        exitErr.Code,
+		ErrorOutput:     lastcmd.Clean(exitErr.Stderr),
+	}
+	if err := guard.CheckQuery(followUp.ErrorOutput, forceSend); err != nil {
+		return err
+	}
+	fixRounds++
+	return generateCommandsFn(fixQuery, pipeContext, followUp)
+}
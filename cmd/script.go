@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/evgfitil/qx/internal/action"
+	"github.com/evgfitil/qx/internal/config"
+	"github.com/evgfitil/qx/internal/guard"
+	"github.com/evgfitil/qx/internal/keymap"
+	"github.com/evgfitil/qx/internal/script"
+	"github.com/evgfitil/qx/internal/tui"
+	"github.com/evgfitil/qx/pkg/qx"
+)
+
+// runScript generates a multi-step script for query, lets the user review
+// it and then runs the steps one at a time or saves them to a file.
+func runScript(query string, pipeContext string) error {
+	if err := guard.CheckQuery(query, forceSend); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	shell, _ := targetShell()
+	client, err := newClient(cfg, shell)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.DefaultTimeout)
+	defer cancel()
+
+	generated, err := client.GenerateScript(ctx, qx.Request{Query: query, PipeContext: pipeContext})
+	if err != nil {
+		return err
+	}
+
+	keys := cfg.Keys.ToKeyMap()
+	result, err := uiRunScriptFn(query, script.FromLLM(generated), tui.ScriptOptions{
+		Theme: cfg.Theme.ToTheme(),
+		Keys:  keys,
+		Shell: shell,
+	})
+	if err != nil {
+		return err
+	}
+
+	switch result.Action {
+	case tui.ScriptRun:
+		return runSteps(script.Active(result.Steps), keys)
+	case tui.ScriptSave:
+		if err := script.Save(result.Path, script.Render(query, result.Steps, shell)); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Saved script to %s\n", result.Path)
+		return nil
+	default:
+		return ErrCancelled
+	}
+}
+
+// runSteps asks for confirmation before each step and runs it. It stops
+// at the first failing step and returns its error, so qx exits with the
+// step's exit code.
+func runSteps(steps []script.Step, keys keymap.KeyMap) error {
+	for i, step := range steps {
+		choice, err := confirmStepFn(i+1, len(steps), step.Description, step.Command, keys)
+		if err != nil {
+			return err
+		}
+		switch choice {
+		case action.StepQuit:
+			return ErrCancelled
+		case action.StepSkip:
+			continue
+		}
+
+		if err := executeFn(step.Command); err != nil {
+			fmt.Fprintf(os.Stderr, "Step %d failed: %v\n", i+1, err)
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/evgfitil/qx/internal/config"
+	"github.com/evgfitil/qx/internal/daemon"
+	"github.com/evgfitil/qx/internal/server"
+)
+
+// EnvServeToken names the environment variable with the API token.
+const EnvServeToken = "QX_SERVE_TOKEN"
+
+// DefaultServeAddr is the address qx serve listens on without --addr.
+const DefaultServeAddr = "127.0.0.1:7878"
+
+var (
+	serveAddr   string
+	serveSocket string
+	serveToken  string
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve generation, explanation and history over a local HTTP API",
+	Long: `Serve exposes a JSON API on a loopback address or a Unix socket, using
+the qx config and secret checks:
+  POST /v1/generate          generate command variants
+  POST /v1/explain           explain a command
+  GET  /v1/history           list history entries
+  GET  /v1/history/search    search history entries (?q=term)
+  GET  /v1/schema            JSON Schema of all requests and responses
+
+Every request needs "Authorization: Bearer <token>". The token is taken
+from --token or $QX_SERVE_TOKEN, or generated and printed at startup.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return runServe(ctx, os.Stderr)
+	},
+	SilenceErrors: true,
+	SilenceUsage:  true,
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveAddr, "addr", DefaultServeAddr, "loopback address to listen on")
+	serveCmd.Flags().StringVar(&serveSocket, "socket", "", "listen on this Unix socket instead of --addr")
+	serveCmd.Flags().StringVar(&serveToken, "token", "", "API token; defaults to $"+EnvServeToken+" or a generated one")
+	rootCmd.AddCommand(serveCmd)
+}
+
+// runServe serves the API until ctx is done, logging to w.
+func runServe(ctx context.Context, w io.Writer) error {
+	shell, err := targetShell()
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	token := serveToken
+	if token == "" {
+		token = os.Getenv(EnvServeToken)
+	}
+	if token == "" {
+		if token, err = newToken(); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "API token: %s\n", token)
+	}
+
+	client, err := newServiceClient(cfg, shell)
+	if err != nil {
+		return err
+	}
+	ln, err := serveListener()
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Handler: server.New(server.Options{
+			Token:   token,
+			Client:  client,
+			Timeout: config.DefaultTimeout,
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+	fmt.Fprintf(w, "qx serve listening on %s\n", ln.Addr())
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// serveListener listens on --socket, readable by the current user only,
+// or on --addr, which must be a loopback address.
+func serveListener() (net.Listener, error) {
+	if serveSocket != "" {
+		return daemon.Listen(serveSocket)
+	}
+	host, _, err := net.SplitHostPort(serveAddr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --addr %q: %w", serveAddr, err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("--addr must be a loopback address such as %s, got %s", DefaultServeAddr, serveAddr)
+	}
+	ln, err := net.Listen("tcp", serveAddr)
+	if err != nil {
+		return nil, fmt.Errorf("listening on %s: %w", serveAddr, err)
+	}
+	return ln, nil
+}
+
+// newToken returns a random API token.
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
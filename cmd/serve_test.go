@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withServeFlags restores the qx serve flags after the test.
+func withServeFlags(t *testing.T, addr, socket, token string) {
+	t.Helper()
+	origAddr, origSocket, origToken := serveAddr, serveSocket, serveToken
+	serveAddr, serveSocket, serveToken = addr, socket, token
+	t.Cleanup(func() {
+		serveAddr, serveSocket, serveToken = origAddr, origSocket, origToken
+	})
+}
+
+func TestServeListener_RequiresLoopback(t *testing.T) {
+	for _, addr := range []string{"0.0.0.0:7878", "192.168.1.10:7878", ":7878", "example.com:7878", "7878"} {
+		withServeFlags(t, addr, "", "")
+		if ln, err := serveListener(); err == nil {
+			_ = ln.Close()
+			t.Errorf("serveListener() accepted %q", addr)
+		}
+	}
+	withServeFlags(t, "127.0.0.1:0", "", "")
+	ln, err := serveListener()
+	if err != nil {
+		t.Fatalf("serveListener() on loopback error = %v", err)
+	}
+	_ = ln.Close()
+}
+
+func TestRunServe_Socket(t *testing.T) {
+	withMockFns(t)
+	withConfigContent(t, "")
+	withTempHistoryStore(t)
+	t.Setenv(EnvServeToken, "")
+	socket := filepath.Join(t.TempDir(), "qx.sock")
+	withServeFlags(t, DefaultServeAddr, socket, "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var log bytes.Buffer
+	done := make(chan error, 1)
+	go func() { done <- runServe(ctx, &log) }()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	var status int
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err := client.Get("http://qx/v1/history")
+		if err == nil {
+			status = resp.StatusCode
+			_ = resp.Body.Close()
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("runServe() error = %v", err)
+	}
+
+	if status != http.StatusUnauthorized {
+		t.Errorf("status without token = %d, want 401", status)
+	}
+	if !strings.Contains(log.String(), "API token: ") {
+		t.Errorf("a generated token should be printed, got %q", log.String())
+	}
+}
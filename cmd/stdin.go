@@ -1,42 +1,50 @@
 package cmd
 
 import (
-	"errors"
 	"fmt"
-	"io"
 	"os"
-	"strings"
 
 	"github.com/mattn/go-isatty"
-)
 
-const maxStdinSize = 64 * 1024 // 64KB
+	"github.com/evgfitil/qx/internal/config"
+	"github.com/evgfitil/qx/internal/pipeinput"
+)
 
-// ErrStdinTooLarge indicates piped input exceeds the size limit.
-var ErrStdinTooLarge = errors.New("stdin input too large (max 64KB)")
+// maxStdinRead bounds how much piped input is kept in memory; larger
+// input keeps its beginning and end.
+const maxStdinRead = 16 * 1024 * 1024 // 16MB
 
-// readStdin detects piped input and reads up to 64KB.
-// Returns empty string if stdin is a TTY (no pipe).
-func readStdin() (string, error) {
+// readStdin detects piped input and reads it.
+// Returns empty input if stdin is a TTY (no pipe).
+func readStdin() (pipeinput.Input, error) {
 	return readFromReader(os.Stdin)
 }
 
 // readFromReader reads piped input from the given file descriptor.
 // Extracted for testability - readStdin calls this with os.Stdin.
-func readFromReader(f *os.File) (string, error) {
+func readFromReader(f *os.File) (pipeinput.Input, error) {
 	if isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd()) {
-		return "", nil
+		return pipeinput.Input{}, nil
 	}
 
-	limited := io.LimitReader(f, int64(maxStdinSize)+1)
-	data, err := io.ReadAll(limited)
+	in, err := pipeinput.Read(f, maxStdinRead)
 	if err != nil {
-		return "", fmt.Errorf("failed to read stdin: %w", err)
+		return pipeinput.Input{}, fmt.Errorf("failed to read stdin: %w", err)
 	}
+	return in, nil
+}
 
-	if len(data) > maxStdinSize {
-		return "", ErrStdinTooLarge
-	}
+// reducePipeInput fits piped input into the budget derived from the
+// model's context window using the configured strategy.
+func reducePipeInput(in pipeinput.Input, cfg *config.Config) pipeinput.Result {
+	strategy, _ := pipeinput.ParseStrategy(cfg.Stdin.Strategy)
+	return pipeinput.Reduce(in, strategy, cfg.StdinBudget())
+}
 
-	return strings.TrimSpace(string(data)), nil
+// pipeSummary describes how much piped input is sent, empty without one.
+func pipeSummary(pipe pipeinput.Result) string {
+	if pipe.Text == "" {
+		return ""
+	}
+	return pipe.Summary()
 }
@@ -1,10 +1,12 @@
 package cmd
 
 import (
-	"errors"
 	"os"
 	"strings"
 	"testing"
+
+	"github.com/evgfitil/qx/internal/config"
+	"github.com/evgfitil/qx/internal/pipeinput"
 )
 
 func TestReadFromReader_PipedInput(t *testing.T) {
@@ -24,8 +26,8 @@ func TestReadFromReader_PipedInput(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	if got != content {
-		t.Errorf("got %q, want %q", got, content)
+	if got.Text != content || got.Bytes != len(content) {
+		t.Errorf("got %+v, want %q", got, content)
 	}
 }
 
@@ -44,8 +46,8 @@ func TestReadFromReader_PipedEmptyInput(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	if got != "" {
-		t.Errorf("expected empty string for empty pipe, got %q", got)
+	if got.Text != "" {
+		t.Errorf("expected empty string for empty pipe, got %q", got.Text)
 	}
 }
 
@@ -65,8 +67,8 @@ func TestReadFromReader_PipedWhitespaceOnly(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	if got != "" {
-		t.Errorf("expected empty string for whitespace-only pipe, got %q", got)
+	if reducePipeInput(got, testConfig()).Text != "" {
+		t.Errorf("expected empty string for whitespace-only pipe, got %q", got.Text)
 	}
 }
 
@@ -82,8 +84,8 @@ func TestReadFromReader_TTYInput(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	if got != "" {
-		t.Errorf("expected empty string for TTY, got %q", got)
+	if got.Text != "" || got.Bytes != 0 {
+		t.Errorf("expected empty input for TTY, got %+v", got)
 	}
 }
 
@@ -93,36 +95,44 @@ func TestReadFromReader_OversizedInput(t *testing.T) {
 		t.Fatalf("failed to create pipe: %v", err)
 	}
 
+	data := strings.Repeat("journal line\n", maxStdinRead/10)
 	go func() {
-		data := strings.Repeat("x", maxStdinSize+1)
 		_, _ = w.WriteString(data)
 		_ = w.Close()
 	}()
 
-	_, err = readFromReader(r)
-	if !errors.Is(err, ErrStdinTooLarge) {
-		t.Errorf("expected ErrStdinTooLarge, got %v", err)
+	got, err := readFromReader(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Bytes != len(data) {
+		t.Errorf("Bytes = %d, want %d", got.Bytes, len(data))
+	}
+	if len(got.Text) > maxStdinRead+100 {
+		t.Errorf("len(Text) = %d, want at most about %d", len(got.Text), maxStdinRead)
 	}
 }
 
-func TestReadFromReader_ExactLimitInput(t *testing.T) {
-	r, w, err := os.Pipe()
-	if err != nil {
-		t.Fatalf("failed to create pipe: %v", err)
-	}
+func testConfig() *config.Config {
+	return &config.Config{LLM: config.LLMConfig{ContextWindow: 1000}, Stdin: config.StdinConfig{Strategy: "head-tail"}}
+}
 
-	data := strings.Repeat("x", maxStdinSize)
-	go func() {
-		_, _ = w.WriteString(data)
-		_ = w.Close()
-	}()
+func TestReducePipeInput(t *testing.T) {
+	small := pipeinput.Input{Text: "a.txt\nb.txt\n", Bytes: 12}
+	if got := reducePipeInput(small, testConfig()); got.Reduced || got.Text != "a.txt\nb.txt" {
+		t.Errorf("small input = %+v, want sent unchanged", got)
+	}
 
-	got, err := readFromReader(r)
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
+	// A quarter of the 1000-token window is 1000 bytes.
+	data := strings.Repeat("journal line\n", 500)
+	got := reducePipeInput(pipeinput.Input{Text: data, Bytes: len(data)}, testConfig())
+	if !got.Reduced || len(got.Text) > 1100 {
+		t.Errorf("large input: Reduced = %v, len(Text) = %d, want reduced to about 1000 bytes", got.Reduced, len(got.Text))
 	}
 
-	if got != data {
-		t.Errorf("expected data of length %d, got length %d", len(data), len(got))
+	cfg := testConfig()
+	cfg.Stdin.MaxTokens = 10000
+	if got := reducePipeInput(pipeinput.Input{Text: data, Bytes: len(data)}, cfg); got.Reduced {
+		t.Error("stdin.max_tokens should override the context window budget")
 	}
 }
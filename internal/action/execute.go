@@ -3,15 +3,21 @@ package action
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 
 	"github.com/mattn/go-isatty"
 )
 
+// maxCapturedStderr caps the error output kept by ExecuteCapture.
+const maxCapturedStderr = 64 * 1024
+
 // ExitError wraps a subprocess exit code so callers can propagate it.
+// Stderr holds the end of the error output when it was captured.
 type ExitError struct {
-	Code int
+	Code   int
+	Stderr string
 }
 
 func (e *ExitError) Error() string {
@@ -33,6 +39,24 @@ func detectShell() string {
 // If stdin is a terminal it is passed through; otherwise /dev/tty is opened
 // so the subprocess can receive interactive input.
 func Execute(command string) error {
+	return execute(command, nil)
+}
+
+// ExecuteCapture runs command like Execute and also keeps the end of its
+// error output, which is reported in ExitError.Stderr if it fails. The
+// output still reaches the terminal, but the command's stderr is a pipe.
+func ExecuteCapture(command string) error {
+	capture := &tailBuffer{limit: maxCapturedStderr}
+	err := execute(command, capture)
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		exitErr.Stderr = string(capture.buf)
+	}
+	return err
+}
+
+// execute runs command, copying its error output to capture if non-nil.
+func execute(command string, capture io.Writer) error {
 	shell := detectShell()
 	cmd := exec.Command(shell, "-c", command)
 
@@ -45,6 +69,9 @@ func Execute(command string) error {
 			cmd.Stderr = ttyOut
 		}
 	}
+	if capture != nil {
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, capture)
+	}
 
 	cmd.Stdin = os.Stdin
 	if !isatty.IsTerminal(os.Stdin.Fd()) && !isatty.IsCygwinTerminal(os.Stdin.Fd()) {
@@ -63,3 +90,17 @@ func Execute(command string) error {
 	}
 	return nil
 }
+
+// tailBuffer keeps the last limit bytes written to it.
+type tailBuffer struct {
+	buf   []byte
+	limit int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.limit {
+		b.buf = b.buf[len(b.buf)-b.limit:]
+	}
+	return len(p), nil
+}
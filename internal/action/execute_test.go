@@ -255,3 +255,41 @@ func TestExecute_NormalMode_OutputOnStdout(t *testing.T) {
 		t.Errorf("expected output on stdout in normal mode, got %q", string(out))
 	}
 }
+
+func TestExecuteCapture_FailingCommand(t *testing.T) {
+	t.Setenv("SHELL", "/bin/sh")
+
+	// Redirect stderr to check the output is still passed through.
+	stderrR, stderrW, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create stderr pipe: %v", err)
+	}
+	origStderr := os.Stderr
+	os.Stderr = stderrW
+	t.Cleanup(func() { os.Stderr = origStderr })
+
+	execErr := ExecuteCapture("echo out; echo 'no such file' >&2; exit 3")
+	_ = stderrW.Close()
+	passed, _ := io.ReadAll(stderrR)
+	_ = stderrR.Close()
+
+	var exitErr *ExitError
+	if !errors.As(execErr, &exitErr) {
+		t.Fatalf("expected ExitError, got %T: %v", execErr, execErr)
+	}
+	if exitErr.Code != 3 || exitErr.Stderr != "no such file\n" {
+		t.Errorf("ExitError = %+v, want code 3 with captured stderr", exitErr)
+	}
+	if string(passed) != "no such file\n" {
+		t.Errorf("stderr passed through = %q, want it tee'd to the terminal", passed)
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	b := &tailBuffer{limit: 5}
+	_, _ = b.Write([]byte("abc"))
+	_, _ = b.Write([]byte("defg"))
+	if string(b.buf) != "cdefg" {
+		t.Errorf("tailBuffer = %q, want last 5 bytes", b.buf)
+	}
+}
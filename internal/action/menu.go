@@ -5,10 +5,13 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/mattn/go-isatty"
 	"golang.org/x/term"
+
+	"github.com/evgfitil/qx/internal/keymap"
 )
 
 // ErrCancelled indicates the user cancelled without choosing an action.
@@ -31,6 +34,14 @@ func (e *ReviseRequestedError) Error() string {
 	return "revise requested"
 }
 
+// ExplainRequestedError indicates the user wants an explanation of the
+// selected command before choosing another action.
+type ExplainRequestedError struct{}
+
+func (e *ExplainRequestedError) Error() string {
+	return "explain requested"
+}
+
 // Action represents a post-selection action chosen by the user.
 type Action int
 
@@ -38,8 +49,10 @@ const (
 	ActionExecute Action = iota
 	ActionCopy
 	ActionRevise
+	ActionExplain
 	ActionQuit
 	ActionCancel
+	ActionHelp
 )
 
 // ShouldPrompt returns true if stdout is a TTY, meaning the user is
@@ -55,29 +68,39 @@ func ShouldPromptStderr() bool {
 }
 
 // readKeypress reads a single keypress from the given reader, which should
-// be in raw mode. Handles multi-byte escape sequences by draining trailing
-// bytes so they don't leak into the parent shell.
-func readKeypress(r io.Reader) (Action, error) {
+// be in raw mode, and maps it to an action using keys. Ctrl+C always cancels.
+// Handles multi-byte escape sequences by draining trailing bytes so they
+// don't leak into the parent shell.
+func readKeypress(r io.Reader, keys keymap.KeyMap) (Action, error) {
 	buf := make([]byte, 1)
 	for {
 		if _, err := r.Read(buf); err != nil {
 			return ActionQuit, fmt.Errorf("failed to read keypress: %w", err)
 		}
 
-		switch buf[0] {
-		case 'e', 'E':
+		b := buf[0]
+		switch {
+		case b == 0x03: // Ctrl+C
+			return ActionCancel, nil
+		case b == 0x1b: // Escape (may be start of multi-byte sequence)
+			drainEscapeSequence(r)
+			if keymap.MatchesByte(keys.Cancel, b) {
+				return ActionCancel, nil
+			}
+		case keymap.MatchesByte(keys.Cancel, b):
+			return ActionCancel, nil
+		case keymap.MatchesByte(keys.Execute, b):
 			return ActionExecute, nil
-		case 'c', 'C':
+		case keymap.MatchesByte(keys.Copy, b):
 			return ActionCopy, nil
-		case 'r', 'R':
+		case keymap.MatchesByte(keys.Revise, b):
 			return ActionRevise, nil
-		case 'q', 'Q', '\r', '\n':
+		case keymap.MatchesByte(keys.Explain, b):
+			return ActionExplain, nil
+		case keymap.MatchesByte(keys.Quit, b):
 			return ActionQuit, nil
-		case 0x03: // Ctrl+C
-			return ActionCancel, nil
-		case 0x1b: // Escape (may be start of multi-byte sequence)
-			drainEscapeSequence(r)
-			return ActionCancel, nil
+		case keymap.MatchesByte(keys.Help, b):
+			return ActionHelp, nil
 		}
 	}
 }
@@ -97,22 +120,34 @@ func drainEscapeSequence(r io.Reader) {
 	_, _ = r.Read(discard)
 }
 
+// Options configures the action menu.
+type Options struct {
+	// Keys missing from Keys fall back to the default bindings.
+	Keys keymap.KeyMap
+	// CaptureStderr executes commands with ExecuteCapture, so a failure
+	// reports the error output.
+	CaptureStderr bool
+}
+
 // PromptAction displays the selected command and an action menu,
 // then dispatches the chosen action. It reads input from /dev/tty
 // to avoid conflicts with piped stdin.
-func PromptAction(command string) error {
-	return promptActionWith(command, nil)
+func PromptAction(command string, opts Options) error {
+	return promptActionWith(command, opts, nil)
 }
 
 // promptActionWith is the testable core of PromptAction. When ttyReader
 // is nil, it opens /dev/tty and sets raw mode; otherwise it reads from
 // the provided reader.
-func promptActionWith(command string, ttyReader io.Reader) error {
-	hi := "\033[38;5;205m"
-	rs := "\033[0m"
-	fmt.Fprintf(os.Stderr, "\n  %s\n\n  [%se%s]xecute  [%sc%s]opy  [%sr%s]evise  [%sq%s]uit ", command, hi, rs, hi, rs, hi, rs, hi, rs)
+func promptActionWith(command string, opts Options, ttyReader io.Reader) error {
+	keys := opts.Keys.WithDefaults()
+	fmt.Fprintf(os.Stderr, "\n  %s\n\n  %s ", command, menuLine(keys))
 
-	act, err := readAction(ttyReader)
+	act, err := readAction(ttyReader, keys, func() {
+		// Replace the menu line in place so the erase below still
+		// covers exactly three lines.
+		fmt.Fprintf(os.Stderr, "\r\033[K  %s ", keys.MenuHelp())
+	})
 	if err != nil {
 		return err
 	}
@@ -128,28 +163,185 @@ func promptActionWith(command string, ttyReader io.Reader) error {
 		}
 	}
 
+	if act == ActionExecute && opts.CaptureStderr {
+		return ExecuteCapture(command)
+	}
 	return dispatchAction(act, command)
 }
 
+// menuLine renders the action menu, e.g. "[e]xecute  [c]opy  [r]evise  [i] explain  [q]uit".
+// An action whose first key is not its initial letter is shown as "[key] name".
+func menuLine(keys keymap.KeyMap) string {
+	return renderItems([]menuItem{
+		{"execute", keys.Execute},
+		{"copy", keys.Copy},
+		{"revise", keys.Revise},
+		{"explain", keys.Explain},
+		{"quit", keys.Quit},
+	})
+}
+
+// menuItem is an action shown in a menu line with its keys.
+type menuItem struct {
+	name string
+	keys []string
+}
+
+// renderItems joins items into a menu line, highlighting the first key of
+// each.
+func renderItems(items []menuItem) string {
+	hi := "\033[38;5;205m"
+	rs := "\033[0m"
+	parts := make([]string, len(items))
+	for i, item := range items {
+		key := item.keys[0]
+		if strings.EqualFold(key, item.name[:1]) {
+			parts[i] = fmt.Sprintf("[%s%s%s]%s", hi, item.name[:1], rs, item.name[1:])
+		} else {
+			parts[i] = fmt.Sprintf("[%s%s%s] %s", hi, key, rs, item.name)
+		}
+	}
+	return strings.Join(parts, "  ")
+}
+
 // readAction reads a single-keypress action from the given reader or /dev/tty.
-func readAction(ttyReader io.Reader) (Action, error) {
-	if ttyReader != nil {
-		return readKeypress(ttyReader)
+// The help key calls showHelp and keeps waiting for an action.
+func readAction(ttyReader io.Reader, keys keymap.KeyMap, showHelp func()) (Action, error) {
+	r := ttyReader
+	if r == nil {
+		tty, restore, err := openRawTTY()
+		if err != nil {
+			return ActionQuit, err
+		}
+		defer restore()
+		r = tty
+	}
+
+	for {
+		act, err := readKeypress(r, keys)
+		if err != nil || act != ActionHelp {
+			return act, err
+		}
+		showHelp()
 	}
+}
 
+// openRawTTY opens /dev/tty in raw mode. The returned function restores
+// the terminal and closes it.
+func openRawTTY() (*os.File, func(), error) {
 	tty, err := os.Open("/dev/tty")
 	if err != nil {
-		return ActionQuit, fmt.Errorf("failed to open /dev/tty: %w", err)
+		return nil, nil, fmt.Errorf("failed to open /dev/tty: %w", err)
 	}
-	defer func() { _ = tty.Close() }()
-
 	oldState, err := term.MakeRaw(int(tty.Fd()))
 	if err != nil {
-		return ActionQuit, fmt.Errorf("failed to set raw mode: %w", err)
+		_ = tty.Close()
+		return nil, nil, fmt.Errorf("failed to set raw mode: %w", err)
 	}
-	defer func() { _ = term.Restore(int(tty.Fd()), oldState) }()
+	return tty, func() {
+		_ = term.Restore(int(tty.Fd()), oldState)
+		_ = tty.Close()
+	}, nil
+}
 
-	return readKeypress(tty)
+// PromptFix reports that an executed command failed with code and asks
+// whether to fix and retry it. It returns true if the user chooses to.
+func PromptFix(code int, keys keymap.KeyMap) (bool, error) {
+	return promptFixWith(code, keys, nil)
+}
+
+// promptFixWith is the testable core of PromptFix. When ttyReader is nil,
+// it opens /dev/tty and sets raw mode.
+func promptFixWith(code int, keys keymap.KeyMap, ttyReader io.Reader) (bool, error) {
+	keys = keys.WithDefaults()
+	fmt.Fprintf(os.Stderr, "\n  Command failed with exit code %d.  %s ", code,
+		renderItems([]menuItem{{"fix and retry", keys.Fix}, {"quit", keys.Quit}}))
+	defer fmt.Fprintln(os.Stderr)
+
+	r := ttyReader
+	if r == nil {
+		tty, restore, err := openRawTTY()
+		if err != nil {
+			return false, err
+		}
+		defer restore()
+		r = tty
+	}
+
+	buf := make([]byte, 1)
+	for {
+		if _, err := r.Read(buf); err != nil {
+			return false, fmt.Errorf("failed to read keypress: %w", err)
+		}
+		b := buf[0]
+		switch {
+		case b == 0x1b:
+			drainEscapeSequence(r)
+			if keymap.MatchesByte(keys.Cancel, b) {
+				return false, nil
+			}
+		case b == 0x03, keymap.MatchesByte(keys.Cancel, b), keymap.MatchesByte(keys.Quit, b):
+			return false, nil
+		case keymap.MatchesByte(keys.Fix, b):
+			return true, nil
+		}
+	}
+}
+
+// StepChoice is the answer to ConfirmStep.
+type StepChoice int
+
+const (
+	StepRun StepChoice = iota
+	StepSkip
+	StepQuit
+)
+
+// ConfirmStep shows step n of total with its description and command and
+// asks whether to run it with the run, skip and stop keys (y or enter, s
+// and q by default). Ctrl+C and the cancel key stop the script too.
+func ConfirmStep(n, total int, description, command string, keys keymap.KeyMap) (StepChoice, error) {
+	return confirmStepWith(n, total, description, command, keys, nil)
+}
+
+// confirmStepWith is the testable core of ConfirmStep. When ttyReader is
+// nil, it opens /dev/tty and sets raw mode.
+func confirmStepWith(n, total int, description, command string, keys keymap.KeyMap, ttyReader io.Reader) (StepChoice, error) {
+	keys = keys.WithDefaults()
+	fmt.Fprintf(os.Stderr, "\n  Step %d/%d: %s\n  \033[1m%s\033[0m\n  %s ", n, total, description, command,
+		renderItems([]menuItem{{"run", keys.Run}, {"skip", keys.Skip}, {"quit", keys.Stop}}))
+	defer fmt.Fprintln(os.Stderr)
+
+	r := ttyReader
+	if r == nil {
+		tty, restore, err := openRawTTY()
+		if err != nil {
+			return StepQuit, err
+		}
+		defer restore()
+		r = tty
+	}
+
+	buf := make([]byte, 1)
+	for {
+		if _, err := r.Read(buf); err != nil {
+			return StepQuit, fmt.Errorf("failed to read keypress: %w", err)
+		}
+		b := buf[0]
+		switch {
+		case b == 0x1b:
+			drainEscapeSequence(r)
+			if keymap.MatchesByte(keys.Cancel, b) {
+				return StepQuit, nil
+			}
+		case b == 0x03, keymap.MatchesByte(keys.Cancel, b), keymap.MatchesByte(keys.Stop, b):
+			return StepQuit, nil
+		case keymap.MatchesByte(keys.Run, b):
+			return StepRun, nil
+		case keymap.MatchesByte(keys.Skip, b):
+			return StepSkip, nil
+		}
+	}
 }
 
 // dispatchAction executes the chosen action on the command.
@@ -165,6 +357,8 @@ func dispatchAction(act Action, command string) error {
 		return nil
 	case ActionRevise:
 		return &ReviseRequestedError{}
+	case ActionExplain:
+		return &ExplainRequestedError{}
 	case ActionQuit:
 		fmt.Println(command)
 		return nil
@@ -5,9 +5,12 @@ import (
 	"errors"
 	"io"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/atotto/clipboard"
+
+	"github.com/evgfitil/qx/internal/keymap"
 )
 
 func TestShouldPrompt_WithPipe(t *testing.T) {
@@ -49,7 +52,7 @@ func TestShouldPromptStderr_WithPipe(t *testing.T) {
 func TestReadKeypress_Execute(t *testing.T) {
 	for _, key := range []byte{'e', 'E'} {
 		r := bytes.NewReader([]byte{key})
-		act, err := readKeypress(r)
+		act, err := readKeypress(r, keymap.Default())
 		if err != nil {
 			t.Errorf("readKeypress(%q) returned error: %v", key, err)
 		}
@@ -62,7 +65,7 @@ func TestReadKeypress_Execute(t *testing.T) {
 func TestReadKeypress_Copy(t *testing.T) {
 	for _, key := range []byte{'c', 'C'} {
 		r := bytes.NewReader([]byte{key})
-		act, err := readKeypress(r)
+		act, err := readKeypress(r, keymap.Default())
 		if err != nil {
 			t.Errorf("readKeypress(%q) returned error: %v", key, err)
 		}
@@ -75,7 +78,7 @@ func TestReadKeypress_Copy(t *testing.T) {
 func TestReadKeypress_Quit(t *testing.T) {
 	for _, key := range []byte{'q', 'Q', '\r', '\n'} {
 		r := bytes.NewReader([]byte{key})
-		act, err := readKeypress(r)
+		act, err := readKeypress(r, keymap.Default())
 		if err != nil {
 			t.Errorf("readKeypress(%q) returned error: %v", key, err)
 		}
@@ -88,7 +91,7 @@ func TestReadKeypress_Quit(t *testing.T) {
 func TestReadKeypress_UnknownKeyRetries(t *testing.T) {
 	// Unknown key 'x' is ignored; readKeypress retries and reads 'q'.
 	r := bytes.NewReader([]byte{'x', 'q'})
-	act, err := readKeypress(r)
+	act, err := readKeypress(r, keymap.Default())
 	if err != nil {
 		t.Errorf("readKeypress('x','q') returned error: %v", err)
 	}
@@ -100,7 +103,7 @@ func TestReadKeypress_UnknownKeyRetries(t *testing.T) {
 func TestReadKeypress_UnknownKeyOnlyReturnsError(t *testing.T) {
 	// When only unknown keys are available, readKeypress eventually hits EOF.
 	r := bytes.NewReader([]byte{'x'})
-	_, err := readKeypress(r)
+	_, err := readKeypress(r, keymap.Default())
 	if err == nil {
 		t.Error("readKeypress('x' only) expected error on retry EOF, got nil")
 	}
@@ -108,7 +111,7 @@ func TestReadKeypress_UnknownKeyOnlyReturnsError(t *testing.T) {
 
 func TestReadKeypress_EmptyReader(t *testing.T) {
 	r := bytes.NewReader(nil)
-	_, err := readKeypress(r)
+	_, err := readKeypress(r, keymap.Default())
 	if err == nil {
 		t.Error("readKeypress(empty) expected error, got nil")
 	}
@@ -183,7 +186,7 @@ func TestPromptActionWith_Quit(t *testing.T) {
 	t.Cleanup(func() { os.Stderr = origStderr })
 
 	input := bytes.NewReader([]byte{'q'})
-	promptErr := promptActionWith("echo hello", input)
+	promptErr := promptActionWith("echo hello", Options{Keys: keymap.Default()}, input)
 	_ = w.Close()
 	_ = stderrW.Close()
 
@@ -212,7 +215,7 @@ func TestPromptActionWith_Execute(t *testing.T) {
 	t.Cleanup(func() { os.Stderr = origStderr })
 
 	input := bytes.NewReader([]byte{'e'})
-	promptErr := promptActionWith("true", input)
+	promptErr := promptActionWith("true", Options{Keys: keymap.Default()}, input)
 	_ = stderrW.Close()
 
 	if promptErr != nil {
@@ -223,7 +226,7 @@ func TestPromptActionWith_Execute(t *testing.T) {
 func TestReadKeypress_Cancel(t *testing.T) {
 	for _, key := range []byte{0x03, 0x1b} {
 		r := bytes.NewReader([]byte{key})
-		act, err := readKeypress(r)
+		act, err := readKeypress(r, keymap.Default())
 		if err != nil {
 			t.Errorf("readKeypress(0x%02x) returned error: %v", key, err)
 		}
@@ -237,7 +240,7 @@ func TestReadKeypress_EscapeSequence(t *testing.T) {
 	// Arrow key sends \x1b[A (3 bytes). readKeypress should return ActionCancel
 	// and drain the trailing bytes.
 	r := bytes.NewReader([]byte{0x1b, '[', 'A'})
-	act, err := readKeypress(r)
+	act, err := readKeypress(r, keymap.Default())
 	if err != nil {
 		t.Fatalf("readKeypress(escape sequence) returned error: %v", err)
 	}
@@ -298,7 +301,7 @@ func TestDispatchAction_Cancel(t *testing.T) {
 func TestReadKeypress_Revise(t *testing.T) {
 	for _, key := range []byte{'r', 'R'} {
 		r := bytes.NewReader([]byte{key})
-		act, err := readKeypress(r)
+		act, err := readKeypress(r, keymap.Default())
 		if err != nil {
 			t.Errorf("readKeypress(%q) returned error: %v", key, err)
 		}
@@ -320,6 +323,23 @@ func TestDispatchAction_Revise(t *testing.T) {
 	}
 }
 
+func TestReadKeypress_Explain(t *testing.T) {
+	act, err := readKeypress(bytes.NewReader([]byte{'i'}), keymap.Default())
+	if err != nil {
+		t.Fatalf("readKeypress('i') returned error: %v", err)
+	}
+	if act != ActionExplain {
+		t.Errorf("readKeypress('i') = %d, want ActionExplain(%d)", act, ActionExplain)
+	}
+}
+
+func TestDispatchAction_Explain(t *testing.T) {
+	var explainErr *ExplainRequestedError
+	if err := dispatchAction(ActionExplain, "echo hello"); !errors.As(err, &explainErr) {
+		t.Fatalf("expected ExplainRequestedError, got %T: %v", err, err)
+	}
+}
+
 func TestPromptActionWith_ShellIntegration_ClearsMenu(t *testing.T) {
 	orig := inShellIntegration
 	inShellIntegration = func() bool { return true }
@@ -344,7 +364,7 @@ func TestPromptActionWith_ShellIntegration_ClearsMenu(t *testing.T) {
 	t.Cleanup(func() { os.Stderr = origStderr })
 
 	input := bytes.NewReader([]byte{'q'})
-	promptErr := promptActionWith("echo hello", input)
+	promptErr := promptActionWith("echo hello", Options{Keys: keymap.Default()}, input)
 	_ = stdoutW.Close()
 	_ = stderrW.Close()
 
@@ -390,7 +410,7 @@ func TestPromptActionWith_NormalMode_NoANSIClear(t *testing.T) {
 	t.Cleanup(func() { os.Stderr = origStderr })
 
 	input := bytes.NewReader([]byte{'q'})
-	promptErr := promptActionWith("echo hello", input)
+	promptErr := promptActionWith("echo hello", Options{Keys: keymap.Default()}, input)
 	_ = stdoutW.Close()
 	_ = stderrW.Close()
 
@@ -424,7 +444,7 @@ func TestPromptActionWith_Revise(t *testing.T) {
 	t.Cleanup(func() { os.Stderr = origStderr })
 
 	input := bytes.NewReader([]byte{'r'})
-	promptErr := promptActionWith("echo hello", input)
+	promptErr := promptActionWith("echo hello", Options{Keys: keymap.Default()}, input)
 	_ = stderrW.Close()
 
 	if promptErr == nil {
@@ -436,3 +456,191 @@ func TestPromptActionWith_Revise(t *testing.T) {
 		t.Fatalf("expected ReviseRequestedError, got %T: %v", promptErr, promptErr)
 	}
 }
+
+func TestReadKeypress_CustomKeys(t *testing.T) {
+	keys := keymap.Default()
+	keys.Execute = []string{"x"}
+	keys.Quit = []string{"ctrl+q"}
+
+	tests := []struct {
+		name  string
+		input []byte
+		want  Action
+	}{
+		{"rebound execute", []byte{'x'}, ActionExecute},
+		{"old execute key ignored", []byte{'e', 'x'}, ActionExecute},
+		{"ctrl key quit", []byte{0x11}, ActionQuit},
+		{"help", []byte{'?'}, ActionHelp},
+		{"ctrl+c always cancels", []byte{0x03}, ActionCancel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			act, err := readKeypress(bytes.NewReader(tt.input), keys)
+			if err != nil {
+				t.Fatalf("readKeypress(%q) returned error: %v", tt.input, err)
+			}
+			if act != tt.want {
+				t.Errorf("readKeypress(%q) = %d, want %d", tt.input, act, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadKeypress_EscapeNotBoundToCancel(t *testing.T) {
+	keys := keymap.Default()
+	keys.Cancel = []string{"ctrl+g"}
+
+	// Esc arrives alone, the drain read finds nothing, then 'q' is pressed.
+	act, err := readKeypress(&chunkReader{chunks: [][]byte{{0x1b}, {}, {'q'}}}, keys)
+	if err != nil {
+		t.Fatalf("readKeypress returned error: %v", err)
+	}
+	if act != ActionQuit {
+		t.Errorf("readKeypress = %d, want ActionQuit (%d): esc is not bound to cancel", act, ActionQuit)
+	}
+}
+
+func TestReadAction_HelpThenAction(t *testing.T) {
+	helpShown := 0
+	act, err := readAction(bytes.NewReader([]byte{'?', 'c'}), keymap.Default(), func() { helpShown++ })
+	if err != nil {
+		t.Fatalf("readAction returned error: %v", err)
+	}
+	if act != ActionCopy {
+		t.Errorf("readAction = %d, want ActionCopy (%d)", act, ActionCopy)
+	}
+	if helpShown != 1 {
+		t.Errorf("help shown %d times, want 1", helpShown)
+	}
+}
+
+func TestMenuLine(t *testing.T) {
+	keys := keymap.Default()
+	keys.Execute = []string{"x"}
+
+	line := menuLine(keys)
+	if !strings.Contains(line, "x\033[0m] execute") {
+		t.Errorf("menuLine() = %q, want rebound execute shown as [x] execute", line)
+	}
+	if !strings.Contains(line, "c\033[0m]opy") {
+		t.Errorf("menuLine() = %q, want default copy shown as [c]opy", line)
+	}
+}
+
+// chunkReader returns one chunk per Read call, simulating separate keypresses.
+type chunkReader struct {
+	chunks [][]byte
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.chunks[0])
+	r.chunks = r.chunks[1:]
+	return n, nil
+}
+
+func TestPromptFixWith(t *testing.T) {
+	origStderr := os.Stderr
+	stderrR, stderrW, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create stderr pipe: %v", err)
+	}
+	defer func() { _ = stderrR.Close() }()
+	os.Stderr = stderrW
+	t.Cleanup(func() { os.Stderr = origStderr })
+	go func() { _, _ = io.Copy(io.Discard, stderrR) }()
+
+	tests := []struct {
+		name  string
+		input []byte
+		want  bool
+	}{
+		{"fix", []byte{'f'}, true},
+		{"unknown key then fix", []byte{'z', 'F'}, true},
+		{"quit", []byte{'q'}, false},
+		{"enter", []byte{'\r'}, false},
+		{"ctrl+c", []byte{0x03}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := promptFixWith(1, keymap.Default(), bytes.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("promptFixWith() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("promptFixWith(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfirmStepWith(t *testing.T) {
+	origStderr := os.Stderr
+	stderrR, stderrW, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create stderr pipe: %v", err)
+	}
+	defer func() { _ = stderrR.Close() }()
+	os.Stderr = stderrW
+	t.Cleanup(func() { os.Stderr = origStderr })
+	go func() { _, _ = io.Copy(io.Discard, stderrR) }()
+
+	tests := []struct {
+		name  string
+		input []byte
+		want  StepChoice
+	}{
+		{"y", []byte{'y'}, StepRun},
+		{"enter", []byte{'\r'}, StepRun},
+		{"unknown key then skip", []byte{'z', 'S'}, StepSkip},
+		{"quit", []byte{'q'}, StepQuit},
+		{"esc", []byte{0x1b}, StepQuit},
+		{"ctrl+c", []byte{0x03}, StepQuit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := confirmStepWith(1, 3, "List files", "ls -la", keymap.Default(), bytes.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("confirmStepWith() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("confirmStepWith(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfirmStepWith_CustomKeys(t *testing.T) {
+	origStderr := os.Stderr
+	stderrR, stderrW, _ := os.Pipe()
+	defer func() { _ = stderrR.Close() }()
+	os.Stderr = stderrW
+	t.Cleanup(func() { os.Stderr = origStderr })
+	go func() { _, _ = io.Copy(io.Discard, stderrR) }()
+
+	keys := keymap.KeyMap{Run: []string{"r"}, Skip: []string{"n"}, Stop: []string{"x"}}
+	tests := []struct {
+		name  string
+		input []byte
+		want  StepChoice
+	}{
+		{"run", []byte{'r'}, StepRun},
+		{"default run key unbound", []byte{'y', 'n'}, StepSkip},
+		{"stop", []byte{'X'}, StepQuit},
+		{"default stop key unbound", []byte{'q', 'r'}, StepRun},
+		{"cancel keeps its default", []byte{0x1b}, StepQuit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := confirmStepWith(1, 1, "List files", "ls", keys, bytes.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("confirmStepWith() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("confirmStepWith(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package action
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// previewWaitDelay bounds how long Preview waits for output pipes to close
+// after the command has been killed (e.g. when a grandchild keeps them open).
+const previewWaitDelay = 500 * time.Millisecond
+
+// ErrPreviewTimeout indicates the preview command did not finish in time.
+var ErrPreviewTimeout = errors.New("preview timed out")
+
+// lineLimitWriter collects output up to a number of lines and calls stop
+// once the limit is reached, so long-running commands are not kept alive
+// only to have their output discarded.
+type lineLimitWriter struct {
+	buf      bytes.Buffer
+	maxLines int
+	lines    int
+	stop     func()
+}
+
+func (w *lineLimitWriter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		if w.lines >= w.maxLines {
+			break
+		}
+		w.buf.WriteByte(b)
+		if b == '\n' {
+			w.lines++
+		}
+	}
+	if w.lines >= w.maxLines {
+		w.stop()
+	}
+	return len(p), nil
+}
+
+func (w *lineLimitWriter) full() bool {
+	return w.lines >= w.maxLines
+}
+
+// previewShell runs previews. It is always /bin/sh, whatever $SHELL is,
+// since guard.IsReadOnly reads commands with the sh grammar.
+const previewShell = "/bin/sh"
+
+// Preview runs command with /bin/sh without a terminal and returns
+// up to maxLines lines of its combined stdout and stderr. It is meant for
+// commands already classified as read-only: stdin is /dev/null, the process
+// runs in its own session so it cannot open the controlling tty, and the whole
+// process group is killed when ctx is done or enough output was collected.
+// A non-zero exit is reported as *ExitError alongside the captured output.
+func Preview(ctx context.Context, command string, maxLines int) (string, error) {
+	if maxLines < 1 {
+		return "", nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, previewShell, "-c", command)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = previewWaitDelay
+
+	out := &lineLimitWriter{maxLines: maxLines, stop: cancel}
+	cmd.Stdout = out
+	cmd.Stderr = out
+
+	err := cmd.Run()
+	output := strings.TrimRight(out.buf.String(), "\n")
+	if out.full() {
+		return output, nil
+	}
+	if ctx.Err() != nil {
+		return output, ErrPreviewTimeout
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return output, &ExitError{Code: exitErr.ExitCode()}
+		}
+		return output, fmt.Errorf("preview failed: %w", err)
+	}
+	return output, nil
+}
@@ -0,0 +1,109 @@
+package action
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPreview_CapturesOutput(t *testing.T) {
+	got, err := Preview(context.Background(), "echo hello; echo world", 10)
+	if err != nil {
+		t.Fatalf("Preview() returned error: %v", err)
+	}
+	if got != "hello\nworld" {
+		t.Errorf("Preview() = %q, want %q", got, "hello\nworld")
+	}
+}
+
+func TestPreview_IgnoresUserShell(t *testing.T) {
+	t.Setenv("SHELL", "/bin/false")
+
+	got, err := Preview(context.Background(), "echo hello", 10)
+	if err != nil || got != "hello" {
+		t.Errorf("Preview() = %q, %v, want it run by /bin/sh", got, err)
+	}
+}
+
+func TestPreview_CapturesStderr(t *testing.T) {
+	got, err := Preview(context.Background(), "echo oops >&2", 10)
+	if err != nil {
+		t.Fatalf("Preview() returned error: %v", err)
+	}
+	if got != "oops" {
+		t.Errorf("Preview() = %q, want %q", got, "oops")
+	}
+}
+
+func TestPreview_LimitsLines(t *testing.T) {
+	got, err := Preview(context.Background(), "for i in 1 2 3 4 5; do echo $i; done", 3)
+	if err != nil {
+		t.Fatalf("Preview() returned error: %v", err)
+	}
+	if got != "1\n2\n3" {
+		t.Errorf("Preview() = %q, want %q", got, "1\n2\n3")
+	}
+}
+
+func TestPreview_StopsEndlessOutputAtLimit(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	got, err := Preview(ctx, "while :; do echo y; done", 2)
+	if err != nil {
+		t.Fatalf("Preview() returned error: %v", err)
+	}
+	if got != "y\ny" {
+		t.Errorf("Preview() = %q, want %q", got, "y\ny")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Preview() took %v, expected it to stop once the limit was reached", elapsed)
+	}
+}
+
+func TestPreview_Timeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	got, err := Preview(ctx, "echo started; sleep 10", 10)
+	if !errors.Is(err, ErrPreviewTimeout) {
+		t.Fatalf("Preview() error = %v, want ErrPreviewTimeout", err)
+	}
+	if got != "started" {
+		t.Errorf("Preview() = %q, want output collected before the timeout", got)
+	}
+}
+
+func TestPreview_NonZeroExit(t *testing.T) {
+	got, err := Preview(context.Background(), "echo partial; exit 3", 10)
+
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("Preview() error = %v, want *ExitError", err)
+	}
+	if exitErr.Code != 3 {
+		t.Errorf("exit code = %d, want 3", exitErr.Code)
+	}
+	if got != "partial" {
+		t.Errorf("Preview() = %q, want %q", got, "partial")
+	}
+}
+
+func TestPreview_NoStdin(t *testing.T) {
+	got, err := Preview(context.Background(), "cat; echo done", 10)
+	if err != nil {
+		t.Fatalf("Preview() returned error: %v", err)
+	}
+	if got != "done" {
+		t.Errorf("Preview() = %q, want %q (stdin should be empty)", got, "done")
+	}
+}
+
+func TestPreview_ZeroLines(t *testing.T) {
+	got, err := Preview(context.Background(), "echo hello", 0)
+	if err != nil || got != "" {
+		t.Errorf("Preview() = (%q, %v), want empty output and no error", got, err)
+	}
+}
@@ -8,8 +8,13 @@ import (
 
 	"github.com/spf13/viper"
 
-	"github.com/evgfitil/qx/internal/llm"
+	"github.com/evgfitil/qx/internal/contextfile"
+	"github.com/evgfitil/qx/internal/environment"
+	"github.com/evgfitil/qx/internal/gitcontext"
+	"github.com/evgfitil/qx/internal/keymap"
+	"github.com/evgfitil/qx/internal/pipeinput"
 	"github.com/evgfitil/qx/internal/tui"
+	"github.com/evgfitil/qx/pkg/qx"
 )
 
 const (
@@ -19,13 +24,156 @@ const (
 	DefaultModel   = "gpt-4o-mini"
 	DefaultCount   = 5
 	DefaultTimeout = 60 * time.Second
+
+	// DefaultContextWindow is the context window of the default model in tokens.
+	DefaultContextWindow = 128000
+
+	DefaultPreviewLines   = 10
+	DefaultPreviewTimeout = 2 * time.Second
+
+	// DefaultMaxIterations caps fix-and-retry rounds after a failed execution.
+	DefaultMaxIterations = 3
+
+	// CacheSubdir is the response cache directory inside Dir.
+	CacheSubdir            = "cache"
+	DefaultCacheTTL        = 24 * time.Hour
+	DefaultCacheMaxEntries = 200
 )
 
 // Config represents the application configuration
 type Config struct {
-	LLM        LLMConfig   `mapstructure:"llm"`
-	Theme      ThemeConfig `mapstructure:"theme"`
-	ActionMenu bool        `mapstructure:"action_menu"`
+	LLM        LLMConfig     `mapstructure:"llm"`
+	Theme      ThemeConfig   `mapstructure:"theme"`
+	ActionMenu bool          `mapstructure:"action_menu"`
+	Preview    PreviewConfig `mapstructure:"preview"`
+	Keys       KeysConfig    `mapstructure:"keys"`
+	Input      InputConfig   `mapstructure:"input"`
+	Context    ContextConfig `mapstructure:"context"`
+	Stdin      StdinConfig   `mapstructure:"stdin"`
+	Iterate    IterateConfig `mapstructure:"iterate"`
+}
+
+// IterateConfig controls the fix-and-retry loop offered when a command
+// executed from the action menu fails
+type IterateConfig struct {
+	Enabled       bool `mapstructure:"enabled"`
+	MaxIterations int  `mapstructure:"max_iterations"`
+}
+
+// StdinConfig controls how piped input larger than its budget is reduced
+type StdinConfig struct {
+	Strategy  string `mapstructure:"strategy"`
+	MaxTokens int    `mapstructure:"max_tokens"`
+}
+
+// StdinBudget returns how many bytes of piped input are sent: max_tokens
+// when set, otherwise a quarter of the model's context window.
+func (c *Config) StdinBudget() int {
+	tokens := c.Stdin.MaxTokens
+	if tokens == 0 {
+		tokens = c.LLM.ContextWindow / 4
+	}
+	return tokens * pipeinput.BytesPerToken
+}
+
+// ContextConfig controls the extra context sent along with queries
+type ContextConfig struct {
+	Environment bool     `mapstructure:"environment"`
+	Tools       []string `mapstructure:"tools"`
+	Discover    bool     `mapstructure:"discover"`
+	FilesBudget int      `mapstructure:"files_budget"`
+	Git         bool     `mapstructure:"git"`
+	GitCommits  int      `mapstructure:"git_commits"`
+}
+
+// InputConfig contains settings for the TUI query input
+type InputConfig struct {
+	CharLimit int `mapstructure:"char_limit"`
+}
+
+// KeysConfig contains key bindings for the TUI and the action menu.
+// Actions listed here replace the bindings of the selected preset.
+type KeysConfig struct {
+	Preset  string   `mapstructure:"preset"`
+	Up      []string `mapstructure:"up"`
+	Down    []string `mapstructure:"down"`
+	Accept  []string `mapstructure:"accept"`
+	Newline []string `mapstructure:"newline"`
+	Search  []string `mapstructure:"search"`
+	Score   []string `mapstructure:"score"`
+	Cancel  []string `mapstructure:"cancel"`
+	Help    []string `mapstructure:"help"`
+	Execute []string `mapstructure:"execute"`
+	Copy    []string `mapstructure:"copy"`
+	Revise  []string `mapstructure:"revise"`
+	Explain []string `mapstructure:"explain"`
+	Fix     []string `mapstructure:"fix"`
+	Quit    []string `mapstructure:"quit"`
+	Run     []string `mapstructure:"run"`
+	Skip    []string `mapstructure:"skip"`
+	Stop    []string `mapstructure:"stop"`
+}
+
+// keyMap applies the configured overrides on top of the preset and
+// validates the result.
+func (c KeysConfig) keyMap() (keymap.KeyMap, error) {
+	km, err := keymap.Preset(c.Preset)
+	if err != nil {
+		return keymap.KeyMap{}, fmt.Errorf("keys.preset: %w", err)
+	}
+	override := func(dst *[]string, keys []string) {
+		if len(keys) > 0 {
+			*dst = keys
+		}
+	}
+	override(&km.Up, c.Up)
+	override(&km.Down, c.Down)
+	override(&km.Accept, c.Accept)
+	override(&km.Newline, c.Newline)
+	override(&km.Search, c.Search)
+	override(&km.Score, c.Score)
+	override(&km.Cancel, c.Cancel)
+	override(&km.Help, c.Help)
+	override(&km.Execute, c.Execute)
+	override(&km.Copy, c.Copy)
+	override(&km.Revise, c.Revise)
+	override(&km.Explain, c.Explain)
+	override(&km.Fix, c.Fix)
+	override(&km.Quit, c.Quit)
+	override(&km.Run, c.Run)
+	override(&km.Skip, c.Skip)
+	override(&km.Stop, c.Stop)
+
+	if err := km.Validate(); err != nil {
+		return keymap.KeyMap{}, err
+	}
+	return km, nil
+}
+
+// ToKeyMap converts KeysConfig to keymap.KeyMap. Load has already
+// validated the bindings; an invalid config falls back to the defaults.
+func (c KeysConfig) ToKeyMap() keymap.KeyMap {
+	km, err := c.keyMap()
+	if err != nil {
+		return keymap.Default()
+	}
+	return km
+}
+
+// PreviewConfig contains settings for the live preview of read-only commands
+type PreviewConfig struct {
+	Enabled bool          `mapstructure:"enabled"`
+	Lines   int           `mapstructure:"lines"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// ToPreviewOptions converts PreviewConfig to tui.PreviewOptions.
+func (c PreviewConfig) ToPreviewOptions() tui.PreviewOptions {
+	return tui.PreviewOptions{
+		Enabled: c.Enabled,
+		Lines:   c.Lines,
+		Timeout: c.Timeout,
+	}
 }
 
 // ThemeConfig contains TUI theme configuration
@@ -61,17 +209,39 @@ type LLMConfig struct {
 	Count    int    `mapstructure:"count"`
 	Provider string `mapstructure:"provider"`
 	APIKey   string `mapstructure:"apikey"`
+	// ContextWindow is the model's context window in tokens.
+	ContextWindow int `mapstructure:"context_window"`
+	// Prewarm opens the API connection as soon as the TUI starts.
+	Prewarm bool           `mapstructure:"prewarm"`
+	Cache   LLMCacheConfig `mapstructure:"cache"`
+}
+
+// LLMCacheConfig configures the on-disk cache of generated commands
+type LLMCacheConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// TTL is how long a cached response is served.
+	TTL time.Duration `mapstructure:"ttl"`
+	// MaxEntries caps the cached responses; the oldest are removed first.
+	MaxEntries int `mapstructure:"max_entries"`
 }
 
-// ToLLMConfig converts LLMConfig to llm.Config for provider creation
-func (c LLMConfig) ToLLMConfig() llm.Config {
-	return llm.Config{
+// ToProviderConfig converts LLMConfig to qx.ProviderConfig for provider creation
+func (c LLMConfig) ToProviderConfig() qx.ProviderConfig {
+	cfg := qx.ProviderConfig{
 		BaseURL:  c.BaseURL,
 		APIKey:   c.APIKey,
 		Model:    c.Model,
 		Provider: c.Provider,
 		Count:    c.Count,
 	}
+	if c.Cache.Enabled {
+		if dir, err := CacheDir(); err == nil {
+			cfg.CacheDir = dir
+			cfg.CacheTTL = c.Cache.TTL
+			cfg.CacheMaxEntries = c.Cache.MaxEntries
+		}
+	}
+	return cfg
 }
 
 // configPath returns the full path to the config file
@@ -83,11 +253,25 @@ func configPath() (string, error) {
 	return filepath.Join(home, Dir, File), nil
 }
 
+// CacheDir returns the directory of the response cache
+func CacheDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, Dir, CacheSubdir), nil
+}
+
 // Load reads configuration from ~/.config/qx/config.yaml and environment variables
 func Load() (*Config, error) {
 	viper.SetDefault("llm.base_url", DefaultBaseURL)
 	viper.SetDefault("llm.model", DefaultModel)
 	viper.SetDefault("llm.count", DefaultCount)
+	viper.SetDefault("llm.context_window", DefaultContextWindow)
+	viper.SetDefault("llm.prewarm", false)
+	viper.SetDefault("llm.cache.enabled", false)
+	viper.SetDefault("llm.cache.ttl", DefaultCacheTTL)
+	viper.SetDefault("llm.cache.max_entries", DefaultCacheMaxEntries)
 
 	defaults := tui.DefaultTheme()
 	viper.SetDefault("theme.prompt", defaults.Prompt)
@@ -99,6 +283,21 @@ func Load() (*Config, error) {
 	viper.SetDefault("theme.border", defaults.Border)
 	viper.SetDefault("theme.border_fg", defaults.BorderFg)
 	viper.SetDefault("action_menu", false)
+	viper.SetDefault("preview.enabled", false)
+	viper.SetDefault("preview.lines", DefaultPreviewLines)
+	viper.SetDefault("preview.timeout", DefaultPreviewTimeout)
+	viper.SetDefault("keys.preset", keymap.PresetDefault)
+	viper.SetDefault("input.char_limit", tui.DefaultCharLimit)
+	viper.SetDefault("context.environment", false)
+	viper.SetDefault("context.tools", environment.DefaultTools)
+	viper.SetDefault("context.discover", true)
+	viper.SetDefault("context.files_budget", contextfile.DefaultBudget)
+	viper.SetDefault("context.git", false)
+	viper.SetDefault("context.git_commits", gitcontext.DefaultCommits)
+	viper.SetDefault("stdin.strategy", string(pipeinput.DefaultStrategy))
+	viper.SetDefault("stdin.max_tokens", 0)
+	viper.SetDefault("iterate.enabled", false)
+	viper.SetDefault("iterate.max_iterations", DefaultMaxIterations)
 
 	viper.MustBindEnv("llm.apikey", "OPENAI_API_KEY")
 
@@ -128,6 +327,51 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("llm.count must be at least 1, got %d (in %s)", cfg.LLM.Count, path)
 	}
 
+	if cfg.LLM.ContextWindow < 1 {
+		return nil, fmt.Errorf("llm.context_window must be at least 1, got %d (in %s)", cfg.LLM.ContextWindow, path)
+	}
+
+	if cfg.LLM.Cache.TTL <= 0 {
+		return nil, fmt.Errorf("llm.cache.ttl must be positive, got %s (in %s)", cfg.LLM.Cache.TTL, path)
+	}
+	if cfg.LLM.Cache.MaxEntries < 1 {
+		return nil, fmt.Errorf("llm.cache.max_entries must be at least 1, got %d (in %s)", cfg.LLM.Cache.MaxEntries, path)
+	}
+
+	if _, err := pipeinput.ParseStrategy(cfg.Stdin.Strategy); err != nil {
+		return nil, fmt.Errorf("stdin.strategy: %w (in %s)", err, path)
+	}
+	if cfg.Stdin.MaxTokens < 0 {
+		return nil, fmt.Errorf("stdin.max_tokens must not be negative, got %d (in %s)", cfg.Stdin.MaxTokens, path)
+	}
+
+	if cfg.Preview.Lines < 1 {
+		return nil, fmt.Errorf("preview.lines must be at least 1, got %d (in %s)", cfg.Preview.Lines, path)
+	}
+	if cfg.Preview.Timeout <= 0 {
+		return nil, fmt.Errorf("preview.timeout must be positive, got %s (in %s)", cfg.Preview.Timeout, path)
+	}
+
+	if cfg.Context.FilesBudget < 1 {
+		return nil, fmt.Errorf("context.files_budget must be at least 1, got %d (in %s)", cfg.Context.FilesBudget, path)
+	}
+
+	if cfg.Context.GitCommits < 1 {
+		return nil, fmt.Errorf("context.git_commits must be at least 1, got %d (in %s)", cfg.Context.GitCommits, path)
+	}
+
+	if cfg.Iterate.MaxIterations < 1 {
+		return nil, fmt.Errorf("iterate.max_iterations must be at least 1, got %d (in %s)", cfg.Iterate.MaxIterations, path)
+	}
+
+	if cfg.Input.CharLimit < 1 {
+		return nil, fmt.Errorf("input.char_limit must be at least 1, got %d (in %s)", cfg.Input.CharLimit, path)
+	}
+
+	if _, err := cfg.Keys.keyMap(); err != nil {
+		return nil, fmt.Errorf("%w (in %s)", err, path)
+	}
+
 	if cfg.LLM.APIKey == "" {
 		return nil, fmt.Errorf("OPENAI_API_KEY environment variable or llm.apikey in %s are required", path)
 	}
@@ -3,10 +3,13 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/spf13/viper"
 
+	"github.com/evgfitil/qx/internal/gitcontext"
 	"github.com/evgfitil/qx/internal/tui"
 )
 
@@ -198,3 +201,417 @@ func TestThemeConfigToTheme(t *testing.T) {
 		t.Errorf("BorderFg = %q, want %q", theme.BorderFg, tc.BorderFg)
 	}
 }
+
+func TestLoadConfigPreviewDefaults(t *testing.T) {
+	resetViper()
+
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	t.Setenv("OPENAI_API_KEY", "test-key")
+
+	writeConfig(t, tmpDir, "llm:\n  model: \"gpt-4o-mini\"\n")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+
+	if cfg.Preview.Enabled {
+		t.Error("Preview.Enabled = true, want false (default)")
+	}
+	if cfg.Preview.Lines != DefaultPreviewLines {
+		t.Errorf("Preview.Lines = %d, want %d", cfg.Preview.Lines, DefaultPreviewLines)
+	}
+	if cfg.Preview.Timeout != DefaultPreviewTimeout {
+		t.Errorf("Preview.Timeout = %v, want %v", cfg.Preview.Timeout, DefaultPreviewTimeout)
+	}
+}
+
+func TestLoadConfigPreview(t *testing.T) {
+	resetViper()
+
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	t.Setenv("OPENAI_API_KEY", "test-key")
+
+	cfgContent := `
+llm:
+  model: "gpt-4o-mini"
+preview:
+  enabled: true
+  lines: 5
+  timeout: 500ms
+`
+	writeConfig(t, tmpDir, cfgContent)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+
+	opts := cfg.Preview.ToPreviewOptions()
+	if !opts.Enabled {
+		t.Error("Enabled = false, want true")
+	}
+	if opts.Lines != 5 {
+		t.Errorf("Lines = %d, want 5", opts.Lines)
+	}
+	if opts.Timeout != 500*time.Millisecond {
+		t.Errorf("Timeout = %v, want 500ms", opts.Timeout)
+	}
+}
+
+func TestLoadConfigPreviewInvalidLines(t *testing.T) {
+	resetViper()
+
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	t.Setenv("OPENAI_API_KEY", "test-key")
+
+	writeConfig(t, tmpDir, "llm:\n  model: \"gpt-4o-mini\"\npreview:\n  lines: 0\n")
+
+	if _, err := Load(); err == nil {
+		t.Fatal("Load() expected error for preview.lines: 0")
+	}
+}
+
+func TestLoadConfigKeys(t *testing.T) {
+	resetViper()
+
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	t.Setenv("OPENAI_API_KEY", "test-key")
+
+	cfgContent := `
+llm:
+  model: "gpt-4o-mini"
+keys:
+  preset: vi
+  execute: ["x"]
+  explain: ["?"]
+  help: ["h"]
+  skip: ["n"]
+`
+	writeConfig(t, tmpDir, cfgContent)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+
+	km := cfg.Keys.ToKeyMap()
+	if strings.Join(km.Down, ",") != "down,ctrl+j" {
+		t.Errorf("Down = %v, want vi preset [down ctrl+j]", km.Down)
+	}
+	if strings.Join(km.Execute, ",") != "x" {
+		t.Errorf("Execute = %v, want override [x]", km.Execute)
+	}
+	if strings.Join(km.Copy, ",") != "c" {
+		t.Errorf("Copy = %v, want default [c]", km.Copy)
+	}
+	if strings.Join(km.Explain, ",") != "?" {
+		t.Errorf("Explain = %v, want override [?]", km.Explain)
+	}
+	if strings.Join(km.Skip, ",") != "n" || strings.Join(km.Run, ",") != "y,enter" {
+		t.Errorf("Skip = %v, Run = %v, want override [n] and default [y enter]", km.Skip, km.Run)
+	}
+}
+
+func TestLoadConfigKeysConflict(t *testing.T) {
+	resetViper()
+
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	t.Setenv("OPENAI_API_KEY", "test-key")
+
+	cfgContent := `
+llm:
+  model: "gpt-4o-mini"
+keys:
+  up: ["ctrl+k"]
+  down: ["ctrl+k"]
+`
+	writeConfig(t, tmpDir, cfgContent)
+
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), "bound to both") {
+		t.Fatalf("Load() error = %v, want conflicting bindings error", err)
+	}
+}
+
+func TestLoadConfigKeysUnknownPreset(t *testing.T) {
+	resetViper()
+
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	t.Setenv("OPENAI_API_KEY", "test-key")
+
+	writeConfig(t, tmpDir, "llm:\n  model: \"gpt-4o-mini\"\nkeys:\n  preset: nano\n")
+
+	if _, err := Load(); err == nil {
+		t.Fatal("Load() expected error for unknown preset")
+	}
+}
+
+func TestLoadConfigInputCharLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    int
+		wantErr bool
+	}{
+		{"default", "", tui.DefaultCharLimit, false},
+		{"custom", "input:\n  char_limit: 4000\n", 4000, false},
+		{"zero", "input:\n  char_limit: 0\n", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetViper()
+
+			tmpDir := t.TempDir()
+			t.Setenv("HOME", tmpDir)
+			t.Setenv("OPENAI_API_KEY", "test-key")
+
+			writeConfig(t, tmpDir, "llm:\n  model: \"gpt-4o-mini\"\n"+tt.content)
+
+			cfg, err := Load()
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "input.char_limit") {
+					t.Fatalf("Load() error = %v, want input.char_limit error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if cfg.Input.CharLimit != tt.want {
+				t.Errorf("Input.CharLimit = %d, want %d", cfg.Input.CharLimit, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigContext(t *testing.T) {
+	resetViper()
+
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	t.Setenv("OPENAI_API_KEY", "test-key")
+
+	writeConfig(t, tmpDir, "llm:\n  model: \"gpt-4o-mini\"\n")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.Context.Environment {
+		t.Error("Context.Environment = true, want opt-in (false by default)")
+	}
+	if len(cfg.Context.Tools) == 0 {
+		t.Error("Context.Tools should default to the built-in tool list")
+	}
+
+	resetViper()
+	writeConfig(t, tmpDir, "llm:\n  model: \"gpt-4o-mini\"\ncontext:\n  environment: true\n  tools: [\"jq\", \"rg\"]\n")
+
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if !cfg.Context.Environment {
+		t.Error("Context.Environment = false, want true")
+	}
+	if strings.Join(cfg.Context.Tools, ",") != "jq,rg" {
+		t.Errorf("Context.Tools = %v, want [jq rg]", cfg.Context.Tools)
+	}
+}
+
+func TestLoadConfigContextGit(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		wantGit     bool
+		wantCommits int
+		wantErr     bool
+	}{
+		{"default", "", false, gitcontext.DefaultCommits, false},
+		{"custom", "context:\n  git: true\n  git_commits: 3\n", true, 3, false},
+		{"zero commits", "context:\n  git_commits: 0\n", false, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetViper()
+
+			tmpDir := t.TempDir()
+			t.Setenv("HOME", tmpDir)
+			t.Setenv("OPENAI_API_KEY", "test-key")
+
+			writeConfig(t, tmpDir, "llm:\n  model: \"gpt-4o-mini\"\n"+tt.content)
+
+			cfg, err := Load()
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "context.git_commits") {
+					t.Fatalf("Load() error = %v, want context.git_commits error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if cfg.Context.Git != tt.wantGit || cfg.Context.GitCommits != tt.wantCommits {
+				t.Errorf("Context = %+v, want Git %v, GitCommits %d", cfg.Context, tt.wantGit, tt.wantCommits)
+			}
+		})
+	}
+}
+
+func TestLoadConfigPrewarm(t *testing.T) {
+	for _, tt := range []struct {
+		content string
+		want    bool
+	}{
+		{"", false},
+		{"llm:\n  prewarm: true\n", true},
+	} {
+		resetViper()
+
+		tmpDir := t.TempDir()
+		t.Setenv("HOME", tmpDir)
+		t.Setenv("OPENAI_API_KEY", "test-key")
+		writeConfig(t, tmpDir, tt.content)
+
+		cfg, err := Load()
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if cfg.LLM.Prewarm != tt.want {
+			t.Errorf("LLM.Prewarm = %v for %q, want %v", cfg.LLM.Prewarm, tt.content, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigCache(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantDir bool
+		wantTTL time.Duration
+		wantMax int
+		wantErr string
+	}{
+		{"disabled by default", "", false, 0, 0, ""},
+		{"defaults", "llm:\n  cache:\n    enabled: true\n", true, DefaultCacheTTL, DefaultCacheMaxEntries, ""},
+		{"custom", "llm:\n  cache:\n    enabled: true\n    ttl: 1h\n    max_entries: 10\n", true, time.Hour, 10, ""},
+		{"zero ttl", "llm:\n  cache:\n    ttl: 0s\n", false, 0, 0, "llm.cache.ttl"},
+		{"zero max entries", "llm:\n  cache:\n    max_entries: 0\n", false, 0, 0, "llm.cache.max_entries"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetViper()
+
+			tmpDir := t.TempDir()
+			t.Setenv("HOME", tmpDir)
+			t.Setenv("OPENAI_API_KEY", "test-key")
+			writeConfig(t, tmpDir, tt.content)
+
+			cfg, err := Load()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want %s error", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+
+			got := cfg.LLM.ToProviderConfig()
+			wantDir := ""
+			if tt.wantDir {
+				wantDir = filepath.Join(tmpDir, Dir, CacheSubdir)
+			}
+			if got.CacheDir != wantDir || got.CacheTTL != tt.wantTTL || got.CacheMaxEntries != tt.wantMax {
+				t.Errorf("ToProviderConfig() cache = %q, %s, %d, want %q, %s, %d",
+					got.CacheDir, got.CacheTTL, got.CacheMaxEntries, wantDir, tt.wantTTL, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestLoadConfigStdin(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		wantBudget int
+		wantErr    string
+	}{
+		{"default", "", DefaultContextWindow, ""},
+		{"context window", "llm:\n  context_window: 8000\n", 8000, ""},
+		{"max tokens", "stdin:\n  strategy: sample\n  max_tokens: 500\n", 2000, ""},
+		{"unknown strategy", "stdin:\n  strategy: summarize\n", 0, "stdin.strategy"},
+		{"negative max tokens", "stdin:\n  max_tokens: -1\n", 0, "stdin.max_tokens"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetViper()
+
+			tmpDir := t.TempDir()
+			t.Setenv("HOME", tmpDir)
+			t.Setenv("OPENAI_API_KEY", "test-key")
+
+			writeConfig(t, tmpDir, tt.content)
+
+			cfg, err := Load()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want %s error", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if got := cfg.StdinBudget(); got != tt.wantBudget {
+				t.Errorf("StdinBudget() = %d, want %d", got, tt.wantBudget)
+			}
+		})
+	}
+}
+
+func TestLoadConfigIterate(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    IterateConfig
+		wantErr string
+	}{
+		{"default", "", IterateConfig{MaxIterations: DefaultMaxIterations}, ""},
+		{"enabled", "iterate:\n  enabled: true\n  max_iterations: 5\n", IterateConfig{Enabled: true, MaxIterations: 5}, ""},
+		{"zero iterations", "iterate:\n  max_iterations: 0\n", IterateConfig{}, "iterate.max_iterations"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetViper()
+
+			tmpDir := t.TempDir()
+			t.Setenv("HOME", tmpDir)
+			t.Setenv("OPENAI_API_KEY", "test-key")
+
+			writeConfig(t, tmpDir, tt.content)
+
+			cfg, err := Load()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want %s error", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if cfg.Iterate != tt.want {
+				t.Errorf("Iterate = %+v, want %+v", cfg.Iterate, tt.want)
+			}
+		})
+	}
+}
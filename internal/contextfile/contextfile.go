@@ -0,0 +1,212 @@
+package contextfile
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/evgfitil/qx/internal/guard"
+	"github.com/evgfitil/qx/pkg/qx"
+)
+
+// ProjectFile is discovered automatically in the working directory or
+// the nearest parent up to the repository root.
+const ProjectFile = ".qx/context.md"
+
+// DefaultBudget is the default total size of context files in bytes.
+const DefaultBudget = 16 * 1024
+
+const truncatedMarker = "\n[truncated]"
+
+// blockRule tells the model how to use the file blocks.
+const blockRule = "<file> blocks contain files from the user's project (build files, scripts, notes): prefer the targets, scripts, service names and conventions they define"
+
+// Options configures Load.
+type Options struct {
+	// Patterns are file paths or globs given with --context-file.
+	Patterns []string
+	// Discover enables lookup of ProjectFile.
+	Discover bool
+	// Dir is where discovery starts and relative patterns are resolved
+	// from; the working directory when empty.
+	Dir string
+	// Budget caps the total bytes of file content sent.
+	Budget int
+	// ForceSend sends files even if the secret guard flags them.
+	ForceSend bool
+}
+
+// Load reads the context files, checks them for secrets and returns them
+// as context blocks. Files beyond the budget are truncated or skipped;
+// the returned warnings describe what was left out.
+func Load(opts Options) ([]qx.ContextBlock, []string, error) {
+	dir := opts.Dir
+	if dir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get working directory: %w", err)
+		}
+		dir = wd
+	}
+
+	paths, err := expand(opts.Patterns, dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if opts.Discover {
+		if found := discover(dir); found != "" && !containsFile(paths, found) {
+			paths = append([]string{found}, paths...)
+		}
+	}
+
+	var (
+		blocks   []qx.ContextBlock
+		warnings []string
+	)
+	remaining := opts.Budget
+	for _, path := range paths {
+		display := displayPath(path, dir)
+		if remaining <= 0 {
+			warnings = append(warnings, fmt.Sprintf("%s skipped: context file budget of %d bytes exhausted", display, opts.Budget))
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read context file: %w", err)
+		}
+		if bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data) {
+			warnings = append(warnings, fmt.Sprintf("%s skipped: not a text file", display))
+			continue
+		}
+
+		content := strings.TrimSpace(string(data))
+		if err := guard.CheckQuery(content, opts.ForceSend); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", display, err)
+		}
+
+		if len(content) > remaining {
+			content = truncate(content, remaining) + truncatedMarker
+			warnings = append(warnings, fmt.Sprintf("%s truncated to fit the context file budget of %d bytes", display, opts.Budget))
+			remaining = 0
+		} else {
+			remaining -= len(content)
+		}
+
+		blocks = append(blocks, qx.ContextBlock{Name: "file", Source: display, Content: content, Rule: blockRule})
+	}
+	return blocks, warnings, nil
+}
+
+// Provider attaches context files to requests.
+type Provider struct {
+	opts Options
+	warn func(string)
+}
+
+// NewProvider creates a context provider that loads files with opts and
+// reports truncated or skipped files to warn.
+func NewProvider(opts Options, warn func(string)) *Provider {
+	return &Provider{opts: opts, warn: warn}
+}
+
+// Name implements qx.ContextProvider.
+func (p *Provider) Name() string { return "files" }
+
+// Collect implements qx.ContextProvider.
+func (p *Provider) Collect(context.Context) ([]qx.ContextBlock, error) {
+	blocks, warnings, err := Load(p.opts)
+	if err != nil {
+		return nil, err
+	}
+	if p.warn != nil {
+		for _, w := range warnings {
+			p.warn(w)
+		}
+	}
+	return blocks, nil
+}
+
+// expand resolves patterns relative to dir. A pattern without glob
+// characters must name an existing file; a glob must match at least one.
+func expand(patterns []string, dir string) ([]string, error) {
+	var paths []string
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid context file pattern %q: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			if strings.ContainsAny(pattern, "*?[") {
+				return nil, fmt.Errorf("no context files match %q", displayPath(pattern, dir))
+			}
+			return nil, fmt.Errorf("context file not found: %s", displayPath(pattern, dir))
+		}
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read context file: %w", err)
+			}
+			if info.IsDir() || containsFile(paths, match) {
+				continue
+			}
+			paths = append(paths, match)
+		}
+	}
+	return paths, nil
+}
+
+// discover looks for ProjectFile in dir and its parents, stopping at the
+// first directory that contains .git.
+func discover(dir string) string {
+	for {
+		candidate := filepath.Join(dir, ProjectFile)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil || !errors.Is(err, os.ErrNotExist) {
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func containsFile(paths []string, path string) bool {
+	for _, p := range paths {
+		if filepath.Clean(p) == filepath.Clean(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// displayPath shows path relative to dir when it is inside it.
+func displayPath(path, dir string) string {
+	if rel, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 sequence.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,131 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/evgfitil/qx/pkg/qx"
+)
+
+// dialTimeout bounds connecting to the socket; a daemon that does not
+// accept by then is treated as not running.
+const dialTimeout = 200 * time.Millisecond
+
+// errUnavailable is returned when no daemon listens on the socket.
+var errUnavailable = errors.New("daemon not running")
+
+// NewProviderFunc returns a constructor of providers that send requests to
+// the daemon on path. Whenever the daemon is not running, the request is
+// generated in-process by a provider from fallback.
+func NewProviderFunc(path string, fallback func(qx.ProviderConfig) (qx.Provider, error)) func(qx.ProviderConfig) (qx.Provider, error) {
+	return func(cfg qx.ProviderConfig) (qx.Provider, error) {
+		return &provider{path: path, cfg: cfg, newFallback: fallback}, nil
+	}
+}
+
+// provider forwards requests to the daemon or, without one, to a
+// provider created in-process on first use.
+type provider struct {
+	path        string
+	cfg         qx.ProviderConfig
+	newFallback func(qx.ProviderConfig) (qx.Provider, error)
+
+	once        sync.Once
+	fallback    qx.Provider
+	fallbackErr error
+}
+
+func (p *provider) local() (qx.Provider, error) {
+	p.once.Do(func() {
+		p.fallback, p.fallbackErr = p.newFallback(p.cfg)
+	})
+	return p.fallback, p.fallbackErr
+}
+
+// Generate implements qx.Provider.
+func (p *provider) Generate(ctx context.Context, r qx.ProviderRequest) ([]qx.Variant, error) {
+	resp, err := p.call(ctx, request{Method: methodGenerate, Config: p.cfg, Request: r})
+	if errors.Is(err, errUnavailable) {
+		local, err := p.local()
+		if err != nil {
+			return nil, err
+		}
+		return local.Generate(ctx, r)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.Cached {
+		for i := range resp.Variants {
+			resp.Variants[i].Cached = true
+		}
+	}
+	return resp.Variants, nil
+}
+
+// Explain implements qx.Provider.
+func (p *provider) Explain(ctx context.Context, r qx.ExplainRequest) (*qx.Explanation, error) {
+	resp, err := p.call(ctx, request{Method: methodExplain, Config: p.cfg, Explain: r})
+	if errors.Is(err, errUnavailable) {
+		local, err := p.local()
+		if err != nil {
+			return nil, err
+		}
+		return local.Explain(ctx, r)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.Explanation, nil
+}
+
+// GenerateScript implements qx.Provider.
+func (p *provider) GenerateScript(ctx context.Context, r qx.ProviderRequest) ([]qx.ScriptStep, error) {
+	resp, err := p.call(ctx, request{Method: methodScript, Config: p.cfg, Request: r})
+	if errors.Is(err, errUnavailable) {
+		local, err := p.local()
+		if err != nil {
+			return nil, err
+		}
+		return local.GenerateScript(ctx, r)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.Steps, nil
+}
+
+// call sends req to the daemon and reads its response. It returns
+// errUnavailable only when the daemon could not be reached, so a request
+// the daemon has started on is never sent twice.
+func (p *provider) call(ctx context.Context, req request) (response, error) {
+	dialer := net.Dialer{Timeout: dialTimeout}
+	conn, err := dialer.DialContext(ctx, "unix", p.path)
+	if err != nil {
+		return response{}, errUnavailable
+	}
+	defer func() { _ = conn.Close() }()
+
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	var resp response
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return response{}, fmt.Errorf("sending request to daemon: %w", err)
+	}
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		if ctx.Err() != nil {
+			return response{}, ctx.Err()
+		}
+		return response{}, fmt.Errorf("reading response from daemon: %w", err)
+	}
+	if resp.Error != "" {
+		return response{}, errors.New(resp.Error)
+	}
+	return resp, nil
+}
@@ -0,0 +1,199 @@
+// Package daemon keeps LLM providers, their connections and caches warm in
+// a long-running process. The CLI sends requests to it over a per-user
+// Unix socket and generates in-process when no daemon is running.
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/evgfitil/qx/pkg/qx"
+)
+
+// EnvSocket names the environment variable that overrides the socket path.
+const EnvSocket = "QX_DAEMON_SOCKET"
+
+// socketFile is the name of the socket in its directory.
+const socketFile = "daemon.sock"
+
+// Request methods.
+const (
+	methodGenerate = "generate"
+	methodExplain  = "explain"
+	methodScript   = "script"
+)
+
+// request is sent by the client, one per connection. The client's
+// configuration travels with it, so the daemon never serves a stale one.
+type request struct {
+	Method  string             `json:"method"`
+	Config  qx.ProviderConfig  `json:"config"`
+	Request qx.ProviderRequest `json:"request"`
+	Explain qx.ExplainRequest  `json:"explain"`
+}
+
+// response answers a request; Error is set when it failed.
+type response struct {
+	Variants    []qx.Variant    `json:"variants,omitempty"`
+	Cached      bool            `json:"cached,omitempty"`
+	Explanation *qx.Explanation `json:"explanation,omitempty"`
+	Steps       []qx.ScriptStep `json:"steps,omitempty"`
+	Error       string          `json:"error,omitempty"`
+}
+
+// SocketPath returns the socket of the current user's daemon:
+// $QX_DAEMON_SOCKET, else qx/daemon.sock in $XDG_RUNTIME_DIR, else
+// daemon.sock in ~/.config/qx.
+func SocketPath() (string, error) {
+	if path := os.Getenv(EnvSocket); path != "" {
+		return path, nil
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "qx", socketFile), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".config", "qx", socketFile), nil
+}
+
+// Listen creates the socket at path, readable and writable by the current
+// user only. A socket left behind by a process that exited is replaced;
+// one that still answers is an error.
+func Listen(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("creating socket directory: %w", err)
+	}
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.DialTimeout("unix", path, dialTimeout); err == nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("another process is already listening on %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("removing stale socket: %w", err)
+		}
+	}
+
+	// The umask keeps other users out from the moment the socket exists.
+	old := syscall.Umask(0o077)
+	ln, err := net.Listen("unix", path)
+	syscall.Umask(old)
+	if err != nil {
+		return nil, fmt.Errorf("listening on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		_ = ln.Close()
+		return nil, fmt.Errorf("restricting socket permissions: %w", err)
+	}
+	return ln, nil
+}
+
+// Server answers requests with providers from NewProvider, which keeps
+// one per configuration for the lifetime of the process.
+type Server struct {
+	// NewProvider creates the provider for a configuration;
+	// qx.NewProvider when nil.
+	NewProvider func(qx.ProviderConfig) (qx.Provider, error)
+	// Timeout bounds each request.
+	Timeout time.Duration
+}
+
+// Serve answers connections on ln until ctx is done, then closes ln and
+// waits for requests in flight.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	stop := context.AfterFunc(ctx, func() { _ = ln.Close() })
+	defer stop()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("accepting connection: %w", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.handle(ctx, conn)
+		}()
+	}
+}
+
+// handle answers the single request of conn. The request is cancelled
+// when the client closes the connection, e.g. because the user pressed
+// Esc, so the LLM request does not run on until the timeout.
+func (s *Server) handle(ctx context.Context, conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	var req request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		_ = json.NewEncoder(conn).Encode(response{Error: fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		// The client sends nothing after the request, so reads only
+		// return once it has gone or the answer was sent.
+		defer cancel()
+		buf := make([]byte, 64)
+		for {
+			if _, err := conn.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+	_ = json.NewEncoder(conn).Encode(s.answer(ctx, req))
+}
+
+// answer runs req on the provider for its configuration.
+func (s *Server) answer(ctx context.Context, req request) response {
+	newProvider := s.NewProvider
+	if newProvider == nil {
+		newProvider = qx.NewProvider
+	}
+	provider, err := newProvider(req.Config)
+	if err != nil {
+		return response{Error: err.Error()}
+	}
+
+	var resp response
+	switch req.Method {
+	case methodGenerate:
+		resp.Variants, err = provider.Generate(ctx, req.Request)
+		resp.Cached = len(resp.Variants) > 0 && resp.Variants[0].Cached
+	case methodExplain:
+		resp.Explanation, err = provider.Explain(ctx, req.Explain)
+	case methodScript:
+		resp.Steps, err = provider.GenerateScript(ctx, req.Request)
+	default:
+		err = errors.New("unknown method " + req.Method)
+	}
+	if err != nil {
+		return response{Error: err.Error()}
+	}
+	return resp
+}
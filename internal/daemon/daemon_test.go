@@ -0,0 +1,215 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evgfitil/qx/pkg/qx"
+	"github.com/evgfitil/qx/pkg/qx/qxtest"
+)
+
+// stub is a qxtest.Provider that also records the configuration it was
+// created with.
+type stub struct {
+	*qxtest.Provider
+	cfg qx.ProviderConfig
+}
+
+// newProviderOf returns a constructor that hands out f, recording the
+// configuration.
+func newProviderOf(f *stub) func(qx.ProviderConfig) (qx.Provider, error) {
+	return func(cfg qx.ProviderConfig) (qx.Provider, error) {
+		f.cfg = cfg
+		return f, nil
+	}
+}
+
+// startServer runs a Server with providers from newProvider on a socket
+// in a temp directory and returns the socket path.
+func startServer(t *testing.T, newProvider func(qx.ProviderConfig) (qx.Provider, error)) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), socketFile)
+	ln, err := Listen(path)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- (&Server{NewProvider: newProvider}).Serve(ctx, ln) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Serve() error = %v", err)
+		}
+	})
+	return path
+}
+
+// failingFallback fails the test if the in-process provider is used.
+func failingFallback(t *testing.T) func(qx.ProviderConfig) (qx.Provider, error) {
+	return func(qx.ProviderConfig) (qx.Provider, error) {
+		t.Error("the in-process provider should not be used while the daemon runs")
+		return nil, errors.New("unexpected fallback")
+	}
+}
+
+func TestProvider_Daemon(t *testing.T) {
+	served := &stub{Provider: &qxtest.Provider{
+		Variants:    []qx.Variant{{Command: "ls -la", Confidence: 0.9, Cached: true}},
+		Explanation: &qx.Explanation{Summary: "Lists files"},
+		Steps:       []qx.ScriptStep{{Description: "List", Command: "ls"}},
+	}}
+	path := startServer(t, newProviderOf(served))
+	cfg := qx.ProviderConfig{APIKey: "test-key", Model: "test-model"}
+	p, _ := NewProviderFunc(path, failingFallback(t))(cfg)
+	ctx := context.Background()
+
+	req := qx.ProviderRequest{Query: "list files", Count: 1, Shell: qx.ShellZsh, PipeContext: "a\nb"}
+	variants, err := p.Generate(ctx, req)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if !reflect.DeepEqual(variants, served.Variants) {
+		t.Errorf("Generate() = %+v, want %+v", variants, served.Variants)
+	}
+	if served.Got == nil || !reflect.DeepEqual(*served.Got, req) || served.cfg != cfg {
+		t.Errorf("daemon got %+v with %+v, want %+v with %+v", served.Got, served.cfg, req, cfg)
+	}
+
+	explanation, err := p.Explain(ctx, qx.ExplainRequest{Command: "ls"})
+	if err != nil || explanation.Summary != "Lists files" {
+		t.Errorf("Explain() = %+v, %v", explanation, err)
+	}
+	steps, err := p.GenerateScript(ctx, req)
+	if err != nil || !reflect.DeepEqual(steps, served.Steps) {
+		t.Errorf("GenerateScript() = %+v, %v", steps, err)
+	}
+}
+
+func TestProvider_DaemonError(t *testing.T) {
+	path := startServer(t, newProviderOf(&stub{Provider: &qxtest.Provider{Err: errors.New("rate limited")}}))
+	p, _ := NewProviderFunc(path, failingFallback(t))(qx.ProviderConfig{})
+
+	_, err := p.Generate(context.Background(), qx.ProviderRequest{Query: "list files"})
+	if err == nil || err.Error() != "rate limited" {
+		t.Errorf("Generate() error = %v, want the daemon's error", err)
+	}
+}
+
+// blockingProvider generates until its context is cancelled.
+type blockingProvider struct {
+	qx.Provider
+	started, cancelled chan struct{}
+}
+
+func (p *blockingProvider) Generate(ctx context.Context, _ qx.ProviderRequest) ([]qx.Variant, error) {
+	close(p.started)
+	<-ctx.Done()
+	close(p.cancelled)
+	return nil, ctx.Err()
+}
+
+func TestProvider_CancelStopsDaemonRequest(t *testing.T) {
+	blocking := &blockingProvider{started: make(chan struct{}), cancelled: make(chan struct{})}
+	path := startServer(t, func(qx.ProviderConfig) (qx.Provider, error) { return blocking, nil })
+	p, _ := NewProviderFunc(path, failingFallback(t))(qx.ProviderConfig{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.Generate(ctx, qx.ProviderRequest{Query: "list files"})
+		done <- err
+	}()
+	<-blocking.started
+	cancel()
+
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Generate() error = %v, want context.Canceled", err)
+	}
+	select {
+	case <-blocking.cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the daemon kept generating after the client cancelled")
+	}
+}
+
+func TestProvider_FallsBackWithoutDaemon(t *testing.T) {
+	local := &stub{Provider: &qxtest.Provider{Variants: []qx.Variant{{Command: "pwd"}}}}
+	created := 0
+	p, _ := NewProviderFunc(filepath.Join(t.TempDir(), socketFile), func(cfg qx.ProviderConfig) (qx.Provider, error) {
+		created++
+		return newProviderOf(local)(cfg)
+	})(qx.ProviderConfig{Model: "test-model"})
+
+	for range 2 {
+		variants, err := p.Generate(context.Background(), qx.ProviderRequest{Query: "where am I"})
+		if err != nil || len(variants) != 1 || variants[0].Command != "pwd" {
+			t.Fatalf("Generate() = %+v, %v, want the in-process result", variants, err)
+		}
+	}
+	if created != 1 || local.cfg.Model != "test-model" {
+		t.Errorf("in-process provider created %d times with %+v, want once with the config", created, local.cfg)
+	}
+}
+
+func TestListen(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "qx")
+	path := filepath.Join(dir, socketFile)
+
+	ln, err := Listen(path)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	for file, want := range map[string]os.FileMode{dir: 0o700, path: 0o600} {
+		info, err := os.Stat(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("permissions of %s = %o, want %o", filepath.Base(file), got, want)
+		}
+	}
+
+	if _, err := Listen(path); err == nil || !strings.Contains(err.Error(), "already listening") {
+		t.Errorf("Listen() on a live socket error = %v, want already listening", err)
+	}
+
+	// A socket left behind without a listener is replaced.
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = ln.Close()
+	ln, err = Listen(path)
+	if err != nil {
+		t.Fatalf("Listen() on a stale socket error = %v", err)
+	}
+	_ = ln.Close()
+
+	file := filepath.Join(t.TempDir(), "file")
+	_ = os.WriteFile(file, nil, 0o600)
+	if _, err := Listen(file); err == nil {
+		t.Error("Listen() should not replace a regular file")
+	}
+}
+
+func TestSocketPath(t *testing.T) {
+	t.Setenv("HOME", "/home/user")
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	t.Setenv(EnvSocket, "")
+	if got, _ := SocketPath(); got != "/home/user/.config/qx/daemon.sock" {
+		t.Errorf("SocketPath() = %q without XDG_RUNTIME_DIR", got)
+	}
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+	if got, _ := SocketPath(); got != "/run/user/1000/qx/daemon.sock" {
+		t.Errorf("SocketPath() = %q with XDG_RUNTIME_DIR", got)
+	}
+	t.Setenv(EnvSocket, "/tmp/qx.sock")
+	if got, _ := SocketPath(); got != "/tmp/qx.sock" {
+		t.Errorf("SocketPath() = %q with %s", got, EnvSocket)
+	}
+}
@@ -0,0 +1,189 @@
+package environment
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/evgfitil/qx/pkg/qx"
+)
+
+// DefaultTools lists the tools looked up on $PATH when none are configured.
+var DefaultTools = []string{
+	"git", "docker", "kubectl", "jq", "yq", "rg", "fd", "fzf",
+	"gsed", "gawk", "curl", "wget", "python3",
+}
+
+// blockRule tells the model how to use the environment block.
+const blockRule = "An <environment> block describes the user's machine: use syntax and flags supported by its OS and userland, and prefer the installed tools over ones listed as not installed"
+
+const probeTimeout = time.Second
+
+// Overridable for tests.
+var (
+	goos          = runtime.GOOS
+	goarch        = runtime.GOARCH
+	osReleasePath = "/etc/os-release"
+	lookPath      = exec.LookPath
+	runProbe      = func(name string, args ...string) (string, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+		defer cancel()
+		out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+		return string(out), err
+	}
+)
+
+// Options configures Collect.
+type Options struct {
+	// Shell is the shell qx was invoked from. When empty it is taken
+	// from $SHELL.
+	Shell string
+	// Tools are looked up on $PATH; DefaultTools when empty.
+	Tools []string
+}
+
+// Info describes the machine commands are generated for.
+type Info struct {
+	OS       string
+	Distro   string
+	Arch     string
+	Shell    string
+	Cwd      string
+	Userland string
+	Tools    []string // found on $PATH
+	Missing  []string // not found on $PATH
+}
+
+// Collect gathers the environment description. Anything that cannot be
+// determined is left empty.
+func Collect(opts Options) Info {
+	info := Info{
+		OS:       goos,
+		Distro:   distro(),
+		Arch:     goarch,
+		Shell:    opts.Shell,
+		Userland: userland(),
+	}
+	if info.Shell == "" {
+		if sh := os.Getenv("SHELL"); sh != "" {
+			info.Shell = filepath.Base(sh)
+		}
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		info.Cwd = cwd
+	}
+
+	tools := opts.Tools
+	if len(tools) == 0 {
+		tools = DefaultTools
+	}
+	for _, tool := range tools {
+		if _, err := lookPath(tool); err == nil {
+			info.Tools = append(info.Tools, tool)
+		} else {
+			info.Missing = append(info.Missing, tool)
+		}
+	}
+	return info
+}
+
+// String renders the environment as "key: value" lines, exactly as they
+// are sent to the LLM.
+func (i Info) String() string {
+	var b strings.Builder
+	system := i.OS
+	if i.Distro != "" {
+		system += " (" + i.Distro + ")"
+	}
+	fmt.Fprintf(&b, "os: %s\n", system)
+	fmt.Fprintf(&b, "arch: %s\n", i.Arch)
+	writeField(&b, "shell", i.Shell)
+	writeField(&b, "cwd", i.Cwd)
+	writeField(&b, "userland", i.Userland)
+	writeField(&b, "installed tools", strings.Join(i.Tools, ", "))
+	writeField(&b, "not installed", strings.Join(i.Missing, ", "))
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func writeField(b *strings.Builder, name, value string) {
+	if value != "" {
+		fmt.Fprintf(b, "%s: %s\n", name, value)
+	}
+}
+
+// Block returns the environment as a context block for the LLM request.
+func (i Info) Block() qx.ContextBlock {
+	return qx.ContextBlock{Name: "environment", Content: i.String(), Rule: blockRule}
+}
+
+// Provider attaches the environment description to requests.
+type Provider struct {
+	opts Options
+}
+
+// NewProvider creates a context provider that collects the environment
+// with opts.
+func NewProvider(opts Options) *Provider {
+	return &Provider{opts: opts}
+}
+
+// Name implements qx.ContextProvider.
+func (p *Provider) Name() string { return "environment" }
+
+// Collect implements qx.ContextProvider.
+func (p *Provider) Collect(context.Context) ([]qx.ContextBlock, error) {
+	return []qx.ContextBlock{Collect(p.opts).Block()}, nil
+}
+
+// distro returns the OS release name, e.g. "Ubuntu 24.04.1 LTS" or "macOS 14.5".
+func distro() string {
+	switch goos {
+	case "linux":
+		return osRelease(osReleasePath)
+	case "darwin":
+		out, err := runProbe("sw_vers", "-productVersion")
+		if err != nil {
+			return ""
+		}
+		return "macOS " + strings.TrimSpace(out)
+	}
+	return ""
+}
+
+// osRelease reads PRETTY_NAME from an os-release file.
+func osRelease(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if v, ok := strings.CutPrefix(scanner.Text(), "PRETTY_NAME="); ok {
+			return strings.Trim(v, `"'`)
+		}
+	}
+	return ""
+}
+
+// userland reports which flavour of the core utilities is first on $PATH,
+// since GNU and BSD tools differ in flags (e.g. sed -i).
+func userland() string {
+	out, err := runProbe("ls", "--version")
+	switch {
+	case err == nil && strings.Contains(out, "GNU"):
+		return "GNU coreutils"
+	case strings.Contains(out, "BusyBox"):
+		return "BusyBox"
+	case goos == "darwin" || strings.HasSuffix(goos, "bsd"):
+		return "BSD"
+	}
+	return ""
+}
@@ -0,0 +1,161 @@
+package environment
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// withMocks replaces the system probes for the duration of the test.
+func withMocks(t *testing.T, system string, installed []string, lsVersion string, lsErr error) {
+	t.Helper()
+	origGOOS, origLookPath, origRunProbe, origRelease := goos, lookPath, runProbe, osReleasePath
+	t.Cleanup(func() {
+		goos, lookPath, runProbe, osReleasePath = origGOOS, origLookPath, origRunProbe, origRelease
+	})
+
+	goos = system
+	lookPath = func(file string) (string, error) {
+		if slices.Contains(installed, file) {
+			return "/usr/bin/" + file, nil
+		}
+		return "", errors.New("not found")
+	}
+	runProbe = func(name string, args ...string) (string, error) {
+		switch name {
+		case "ls":
+			return lsVersion, lsErr
+		case "sw_vers":
+			return "14.5\n", nil
+		}
+		return "", errors.New("unexpected probe " + name)
+	}
+}
+
+func TestCollect_Linux(t *testing.T) {
+	withMocks(t, "linux", []string{"git", "jq"}, "ls (GNU coreutils) 9.1\n", nil)
+	release := filepath.Join(t.TempDir(), "os-release")
+	if err := os.WriteFile(release, []byte("NAME=\"Ubuntu\"\nPRETTY_NAME=\"Ubuntu 24.04 LTS\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	osReleasePath = release
+	t.Setenv("SHELL", "/usr/bin/zsh")
+
+	info := Collect(Options{Tools: []string{"git", "kubectl", "jq"}})
+
+	if info.Distro != "Ubuntu 24.04 LTS" {
+		t.Errorf("Distro = %q, want %q", info.Distro, "Ubuntu 24.04 LTS")
+	}
+	if info.Shell != "zsh" {
+		t.Errorf("Shell = %q, want %q", info.Shell, "zsh")
+	}
+	if info.Userland != "GNU coreutils" {
+		t.Errorf("Userland = %q, want %q", info.Userland, "GNU coreutils")
+	}
+	if strings.Join(info.Tools, ",") != "git,jq" {
+		t.Errorf("Tools = %v, want [git jq]", info.Tools)
+	}
+	if strings.Join(info.Missing, ",") != "kubectl" {
+		t.Errorf("Missing = %v, want [kubectl]", info.Missing)
+	}
+	if info.Cwd == "" {
+		t.Error("Cwd should be set")
+	}
+}
+
+func TestCollect_Darwin(t *testing.T) {
+	withMocks(t, "darwin", nil, "ls: unrecognized option `--version'\n", errors.New("exit status 1"))
+
+	info := Collect(Options{Shell: "fish", Tools: []string{"gsed"}})
+
+	if info.Distro != "macOS 14.5" {
+		t.Errorf("Distro = %q, want %q", info.Distro, "macOS 14.5")
+	}
+	if info.Userland != "BSD" {
+		t.Errorf("Userland = %q, want %q", info.Userland, "BSD")
+	}
+	if info.Shell != "fish" {
+		t.Errorf("Shell = %q, explicit shell should win over $SHELL", info.Shell)
+	}
+}
+
+func TestCollect_DefaultTools(t *testing.T) {
+	withMocks(t, "linux", nil, "", nil)
+	osReleasePath = filepath.Join(t.TempDir(), "missing")
+
+	info := Collect(Options{})
+
+	if len(info.Missing) != len(DefaultTools) {
+		t.Errorf("Missing = %v, want all default tools", info.Missing)
+	}
+	if info.Distro != "" {
+		t.Errorf("Distro = %q, want empty without os-release", info.Distro)
+	}
+}
+
+func TestInfo_String(t *testing.T) {
+	info := Info{
+		OS:       "linux",
+		Distro:   "Debian GNU/Linux 12",
+		Arch:     "amd64",
+		Shell:    "bash",
+		Cwd:      "/srv/app",
+		Userland: "GNU coreutils",
+		Tools:    []string{"git", "jq"},
+		Missing:  []string{"kubectl"},
+	}
+
+	want := `os: linux (Debian GNU/Linux 12)
+arch: amd64
+shell: bash
+cwd: /srv/app
+userland: GNU coreutils
+installed tools: git, jq
+not installed: kubectl`
+	if got := info.String(); got != want {
+		t.Errorf("String() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestInfo_StringSkipsUnknownFields(t *testing.T) {
+	got := Info{OS: "linux", Arch: "arm64"}.String()
+
+	if got != "os: linux\narch: arm64" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestInfo_Block(t *testing.T) {
+	block := Info{OS: "linux", Arch: "amd64"}.Block()
+
+	if block.Name != "environment" {
+		t.Errorf("Name = %q, want %q", block.Name, "environment")
+	}
+	if block.Content != "os: linux\narch: amd64" {
+		t.Errorf("Content = %q", block.Content)
+	}
+	if block.Rule == "" {
+		t.Error("Rule should tell the model how to use the block")
+	}
+}
+
+func TestProvider(t *testing.T) {
+	withMocks(t, "linux", []string{"jq"}, "ls (GNU coreutils) 9.1\n", nil)
+
+	p := NewProvider(Options{Shell: "bash", Tools: []string{"jq"}})
+	if p.Name() != "environment" {
+		t.Errorf("Name() = %q, want %q", p.Name(), "environment")
+	}
+
+	blocks, err := p.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if len(blocks) != 1 || !strings.Contains(blocks[0].Content, "shell: bash") {
+		t.Errorf("Collect() = %v, want environment block", blocks)
+	}
+}
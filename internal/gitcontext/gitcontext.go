@@ -0,0 +1,193 @@
+package gitcontext
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/evgfitil/qx/internal/guard"
+	"github.com/evgfitil/qx/pkg/qx"
+)
+
+// DefaultCommits is the default number of recent commit subjects sent.
+const DefaultCommits = 10
+
+// blockRule tells the model how to use the git block.
+const blockRule = "A <git> block describes the git repository in the working directory: use its actual branch, upstream and remote names instead of placeholders, and take uncommitted changes and the recent commits into account"
+
+// Overridable for tests.
+var runGit = func(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// Provider attaches the state of the git repository to requests.
+type Provider struct {
+	// Dir is the directory git runs in; the working directory when empty.
+	Dir string
+	// Commits is the number of recent commit subjects to include.
+	Commits int
+	// ForceSend sends the block even if the secret guard flags it.
+	ForceSend bool
+}
+
+// Name implements qx.ContextProvider.
+func (p *Provider) Name() string { return "git" }
+
+// Collect implements qx.ContextProvider. Outside a repository, or when
+// git is not installed, it returns no blocks.
+func (p *Provider) Collect(ctx context.Context) ([]qx.ContextBlock, error) {
+	out, err := runGit(ctx, p.Dir, "status", "--porcelain=v2", "--branch")
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, nil
+	}
+	info := parseStatus(out)
+
+	if out, err := runGit(ctx, p.Dir, "remote"); err == nil {
+		info.Remotes = strings.Fields(out)
+	}
+	if info.Head != "" && p.Commits > 0 {
+		// Fails on a branch without commits; the log is simply left empty.
+		if out, err := runGit(ctx, p.Dir, "log", "-n", fmt.Sprint(p.Commits), "--format=%h %s"); err == nil {
+			info.Commits = nonEmptyLines(out)
+		}
+	}
+
+	content := info.String()
+	if err := guard.CheckQuery(content, p.ForceSend); err != nil {
+		return nil, err
+	}
+	return []qx.ContextBlock{{Name: "git", Content: content, Rule: blockRule}}, nil
+}
+
+// Info describes the state of a repository.
+type Info struct {
+	Branch   string // empty when HEAD is detached
+	Head     string // abbreviated commit hash; empty before the first commit
+	Upstream string
+	Ahead    int
+	Behind   int
+
+	Staged     int
+	Modified   int
+	Untracked  int
+	Conflicted int
+
+	Remotes []string
+	Commits []string // "<hash> <subject>", newest first
+}
+
+// parseStatus reads the output of git status --porcelain=v2 --branch.
+func parseStatus(out string) Info {
+	var info Info
+	for _, line := range nonEmptyLines(out) {
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case "#":
+			if len(fields) < 3 {
+				continue
+			}
+			switch fields[1] {
+			case "branch.oid":
+				if fields[2] != "(initial)" {
+					info.Head = fields[2][:min(len(fields[2]), 7)]
+				}
+			case "branch.head":
+				if fields[2] != "(detached)" {
+					info.Branch = fields[2]
+				}
+			case "branch.upstream":
+				info.Upstream = fields[2]
+			case "branch.ab":
+				_, _ = fmt.Sscanf(strings.Join(fields[2:], " "), "+%d -%d", &info.Ahead, &info.Behind)
+			}
+		case "1", "2":
+			if len(fields) < 2 || len(fields[1]) != 2 {
+				continue
+			}
+			if fields[1][0] != '.' {
+				info.Staged++
+			}
+			if fields[1][1] != '.' {
+				info.Modified++
+			}
+		case "u":
+			info.Conflicted++
+		case "?":
+			info.Untracked++
+		}
+	}
+	return info
+}
+
+// String renders the repository state as "key: value" lines, exactly as
+// they are sent to the LLM.
+func (i Info) String() string {
+	var b strings.Builder
+	switch {
+	case i.Branch != "":
+		fmt.Fprintf(&b, "branch: %s\n", i.Branch)
+	case i.Head != "":
+		fmt.Fprintf(&b, "branch: detached HEAD at %s\n", i.Head)
+	}
+	if i.Upstream != "" {
+		upstream := i.Upstream
+		if i.Ahead > 0 || i.Behind > 0 {
+			upstream += fmt.Sprintf(" (ahead %d, behind %d)", i.Ahead, i.Behind)
+		}
+		fmt.Fprintf(&b, "upstream: %s\n", upstream)
+	} else if i.Branch != "" {
+		b.WriteString("upstream: none\n")
+	}
+	fmt.Fprintf(&b, "status: %s\n", i.status())
+	if len(i.Remotes) > 0 {
+		fmt.Fprintf(&b, "remotes: %s\n", strings.Join(i.Remotes, ", "))
+	}
+	if len(i.Commits) > 0 {
+		b.WriteString("recent commits:\n")
+		for _, c := range i.Commits {
+			fmt.Fprintf(&b, "  %s\n", c)
+		}
+	} else if i.Head == "" {
+		b.WriteString("recent commits: none\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// status summarizes the working tree, e.g. "2 staged, 1 untracked".
+func (i Info) status() string {
+	var parts []string
+	for _, c := range []struct {
+		n    int
+		name string
+	}{
+		{i.Staged, "staged"},
+		{i.Modified, "modified"},
+		{i.Untracked, "untracked"},
+		{i.Conflicted, "conflicted"},
+	} {
+		if c.n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", c.n, c.name))
+		}
+	}
+	if len(parts) == 0 {
+		return "clean"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func nonEmptyLines(s string) []string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimRight(line, "\r"); strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -307,3 +307,106 @@ func TestSanitizer_MultipleSecrets(t *testing.T) {
 		t.Errorf("expected at least 2 detections, got %d", len(result.Detected))
 	}
 }
+
+func TestIsReadOnly(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		want    bool
+	}{
+		{name: "ls", command: "ls -la", want: true},
+		{name: "find without delete", command: "find . -name '*.go' -mtime -1", want: true},
+		{name: "find with delete", command: "find . -name '*.tmp' -delete", want: false},
+		{name: "find with exec", command: `find . -name '*.go' -exec rm {} \;`, want: false},
+		{name: "git log", command: "git log --oneline -20", want: true},
+		{name: "git -C log", command: "git -C /tmp/repo log", want: true},
+		{name: "git -c override", command: "git -c core.pager=rm log", want: false},
+		{name: "git push", command: "git push origin main", want: false},
+		{name: "git branch list", command: "git branch -a --merged main", want: true},
+		{name: "git branch create", command: "git branch feature", want: false},
+		{name: "git branch delete", command: "git branch -D feature", want: false},
+		{name: "git stash list", command: "git stash list", want: true},
+		{name: "git stash pop", command: "git stash pop", want: false},
+		{name: "kubectl get", command: "kubectl get pods -n kube-system", want: true},
+		{name: "kubectl namespace before verb", command: "kubectl -n default get pods", want: true},
+		{name: "kubectl delete", command: "kubectl delete pod web-0", want: false},
+		{name: "kubectl logs follow", command: "kubectl logs -f web-0", want: false},
+		{name: "docker ps", command: "docker ps --filter status=running", want: true},
+		{name: "docker rm", command: "docker rm -f web", want: false},
+		{name: "pipeline of read-only commands", command: "ps aux | grep nginx | sort -k3 -rn | head -5", want: true},
+		{name: "formatted pipeline", command: "ps aux \\\n\t| grep nginx \\\n\t| wc -l", want: true},
+		{name: "pipeline ending in write", command: "ls | xargs rm", want: false},
+		{name: "and list", command: "pwd && ls", want: true},
+		{name: "and list with write", command: "ls && rm -rf build", want: false},
+		{name: "redirect to file", command: "ls > files.txt", want: false},
+		{name: "append to file", command: "ls >> files.txt", want: false},
+		{name: "redirect to dev null", command: "grep -r TODO . 2>/dev/null", want: true},
+		{name: "fd duplication", command: "ls missing 2>&1 | head", want: true},
+		{name: "command substitution", command: "cat $(ls)", want: false},
+		{name: "backticks", command: "cat `ls`", want: false},
+		{name: "substitution in double quotes", command: `echo "$(rm -rf /)"`, want: false},
+		{name: "quoted operators", command: `grep 'a|b > c' file.txt`, want: true},
+		{name: "background job", command: "ls &", want: false},
+		{name: "subshell", command: "(cd /tmp && ls)", want: false},
+		{name: "sort with output file", command: "sort -o out.txt in.txt", want: false},
+		{name: "tail follow", command: "tail -f /var/log/syslog", want: false},
+		{name: "tail follow in cluster", command: "tail -fn 100 app.log", want: false},
+		{name: "yq in place", command: "yq -i '.a = 1' file.yaml", want: false},
+		{name: "awk print", command: "awk '{print $1}' file", want: true},
+		{name: "awk system", command: `awk '{system("rm " $1)}' file`, want: false},
+		{name: "safe assignment", command: "LC_ALL=C sort file", want: true},
+		{name: "unsafe assignment", command: "GIT_PAGER=rm git log", want: false},
+		{name: "env runs command", command: "env rm file", want: false},
+		{name: "unknown tool", command: "terraform apply", want: false},
+		{name: "sudo", command: "sudo ls /root", want: false},
+		{name: "empty", command: "", want: false},
+		{name: "unterminated quote", command: "echo 'oops", want: false},
+		{name: "dangling pipe", command: "| ls", want: false},
+		{name: "journalctl", command: "journalctl -u nginx --since today", want: true},
+		{name: "journalctl vacuum", command: "journalctl --vacuum-time=2d", want: false},
+		{name: "rg", command: "rg -n TODO src", want: true},
+		{name: "rg preprocessor", command: "rg --pre=sh foo", want: false},
+		{name: "rg preprocessor glob", command: "rg --pre-glob '*.gz' --pre zcat foo", want: false},
+		{name: "sort compress program", command: "sort --compress-program=sh a", want: false},
+		{name: "env print", command: "env -0", want: true},
+		{name: "env split string", command: "env -S'touch /tmp/x'", want: false},
+		{name: "env split string long", command: "env --split-string='touch /tmp/x'", want: false},
+		{name: "env assignment", command: "env FOO=bar", want: false},
+		{name: "env end of flags", command: "env -- -x", want: false},
+		{name: "git log output", command: "git log --output=/tmp/x", want: false},
+		{name: "git show output", command: "git show --output= HEAD", want: false},
+		{name: "git log ext diff", command: "git log -p --ext-diff", want: false},
+		{name: "git grep", command: "git grep -n foo", want: true},
+		{name: "git grep pager", command: "git grep -O foo", want: false},
+		{name: "git grep pager long", command: "git grep --open-files-in-pager=vim foo", want: false},
+		{name: "tree", command: "tree -L 2", want: true},
+		{name: "tree output file", command: "tree -o /tmp/x", want: false},
+		{name: "hostname", command: "hostname -f", want: true},
+		{name: "hostname set", command: "hostname evil", want: false},
+		{name: "hostname from file", command: "hostname -F /etc/hostname", want: false},
+		{name: "helm template", command: "helm template web ./chart", want: true},
+		{name: "helm template output dir", command: "helm template web ./chart --output-dir out", want: false},
+		{name: "helm template post renderer", command: "helm template web ./chart --post-renderer sh --post-renderer-args=-c --post-renderer-args='touch /tmp/x'", want: false},
+		{name: "parameter expansion", command: "find /tmp/fx -name a ${u:--delete}", want: false},
+		{name: "expansion in double quotes", command: `find /tmp/fx -name a "${u:--delete}"`, want: false},
+		{name: "variable", command: "find . -print$IFS-delete", want: false},
+		{name: "brace expansion", command: "find /tmp/fx -name b -{delete,print}", want: false},
+		{name: "parentheses in word", command: "ls x(touch /tmp/pwn)", want: false},
+		{name: "fish substitution", command: "echo a(rm -rf ~/x)", want: false},
+		{name: "quoted expansion", command: "grep '${HOME}' file", want: true},
+		{name: "awk exec file", command: "awk -E prog.awk file", want: false},
+		{name: "awk load", command: "awk -l ordchr '{print ord($1)}' file", want: false},
+		{name: "awk attached program file", command: "awk -fprog.awk file", want: false},
+		{name: "awk include", command: `awk '@include "x"' file`, want: false},
+		{name: "awk load directive", command: `awk '@load "filefuncs"' file`, want: false},
+		{name: "git config env", command: "git --config-env=core.pager=PAGER log", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsReadOnly(tt.command); got != tt.want {
+				t.Errorf("IsReadOnly(%q) = %v, want %v", tt.command, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,496 @@
+package guard
+
+import (
+	"slices"
+	"strings"
+)
+
+// readOnlyCommands lists tools that only read state regardless of arguments,
+// mapped to flags that would make them write, follow forever or run other programs.
+var readOnlyCommands = map[string][]string{
+	"basename": nil,
+	"cat":      nil,
+	"column":   nil,
+	"comm":     nil,
+	"cmp":      nil,
+	"cut":      nil,
+	"date":     {"-s", "--set"},
+	"df":       nil,
+	"diff":     nil,
+	"dirname":  nil,
+	"du":       nil,
+	"echo":     nil,
+	"egrep":    nil,
+	"fgrep":    nil,
+	"file":     nil,
+	"free":     nil,
+	"grep":     nil,
+	"head":     nil,
+	"id":       nil,
+	"jq":       nil,
+	"ls":       nil,
+	"lsof":     nil,
+	"netstat":  nil,
+	"nl":       nil,
+	"pgrep":    nil,
+	"printenv": nil,
+	"printf":   nil,
+	"ps":       nil,
+	"pwd":      nil,
+	"readlink": nil,
+	"realpath": nil,
+	"rg":       {"--pre", "--pre-glob"},
+	"sort":     {"-o", "--output", "--compress-program"},
+	"ss":       {"-K", "--kill"},
+	"stat":     nil,
+	"tail":     {"-f", "-F", "--follow"},
+	"tr":       nil,
+	"tree":     {"-o"},
+	"uname":    nil,
+	"uniq":     nil,
+	"uptime":   nil,
+	"wc":       nil,
+	"which":    nil,
+	"whoami":   nil,
+	"yq":       {"-i", "--inplace"},
+}
+
+// findUnsafeFlags are find primaries that delete files, write files or run commands.
+var findUnsafeFlags = []string{
+	"-delete", "-exec", "-execdir", "-ok", "-okdir",
+	"-fls", "-fprint", "-fprint0", "-fprintf",
+}
+
+// readOnlySubcommands lists read-only subcommands of multi-command tools.
+var readOnlySubcommands = map[string][]string{
+	"docker": {"ps", "images", "inspect", "logs", "version", "info", "top", "port", "history", "diff"},
+	"git": {
+		"log", "status", "diff", "show", "rev-parse", "ls-files", "ls-tree", "blame",
+		"shortlog", "describe", "cat-file", "grep", "rev-list", "name-rev", "merge-base",
+		"for-each-ref", "show-ref", "count-objects", "whatchanged", "branch", "remote",
+		"tag", "stash", "reflog",
+	},
+	"helm": {"list", "ls", "status", "get", "history", "show", "search", "version", "template"},
+	"kubectl": {
+		"get", "describe", "logs", "top", "explain", "version", "api-resources",
+		"api-versions", "cluster-info", "events",
+	},
+	"systemctl": {"status", "list-units", "list-unit-files", "list-timers", "is-active", "is-enabled", "is-failed", "show", "cat"},
+}
+
+// subcommandValueFlags lists global flags that consume the next argument,
+// so the argument is not mistaken for the subcommand.
+var subcommandValueFlags = map[string][]string{
+	"docker":    {"-H", "--host", "--context", "-c", "--config", "-l", "--log-level"},
+	"git":       {"-C", "--git-dir", "--work-tree", "--namespace"},
+	"helm":      {"-n", "--namespace", "--kube-context", "--kubeconfig"},
+	"kubectl":   {"-n", "--namespace", "--context", "--cluster", "--user", "--kubeconfig", "-s", "--server", "-l", "--selector", "-o", "--output"},
+	"systemctl": {"-H", "--host", "-M", "--machine", "-t", "--type", "--state"},
+}
+
+// subcommandUnsafeFlags lists flags that make an otherwise read-only
+// subcommand write files or run other programs, keyed by "tool subcommand".
+var subcommandUnsafeFlags = map[string][]string{
+	"git grep":      {"-O", "--open-files-in-pager"},
+	"helm template": {"--output-dir", "--post-renderer", "--post-renderer-args"},
+}
+
+// gitUnsafeFlags write a file or run an external diff program in every
+// git subcommand that accepts diff options.
+var gitUnsafeFlags = []string{"--output", "--ext-diff"}
+
+// envUnsafeFlags make env split a string into a command and run it.
+var envUnsafeFlags = []string{"-S", "--split-string"}
+
+// followFlags stream output forever; a preview would only ever show a timeout.
+var followFlags = []string{"-f", "--follow"}
+
+// safeAssignments are environment variable prefixes allowed before a command.
+var safeAssignments = []string{"LC_ALL", "LC_COLLATE", "LC_CTYPE", "LANG", "TZ", "COLUMNS"}
+
+// IsReadOnly reports whether command only reads state and is safe to run
+// without confirmation, e.g. for a preview. The classification is deliberately
+// conservative: expansions, background jobs, redirections other than to
+// /dev/null or file descriptors, and any tool outside the allowlist make the
+// whole command non-read-only. Words are classified as POSIX sh reads them, so
+// the command must be run with /bin/sh.
+func IsReadOnly(command string) bool {
+	segments, ok := splitSimpleCommands(command)
+	if !ok || len(segments) == 0 {
+		return false
+	}
+	for _, words := range segments {
+		if !isReadOnlySimpleCommand(words) {
+			return false
+		}
+	}
+	return true
+}
+
+// isReadOnlySimpleCommand classifies a single command of a pipeline or list.
+func isReadOnlySimpleCommand(words []string) bool {
+	for len(words) > 0 && isAssignment(words[0]) {
+		name, _, _ := strings.Cut(words[0], "=")
+		if !slices.Contains(safeAssignments, name) {
+			return false
+		}
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		return false
+	}
+
+	name, args := words[0], words[1:]
+	if unsafe, ok := readOnlyCommands[name]; ok {
+		return !hasAnyFlag(args, unsafe)
+	}
+
+	switch name {
+	case "find":
+		return !hasAnyFlag(args, findUnsafeFlags)
+	case "env":
+		// env runs its first non-flag argument as a command, and with -S
+		// the split string. Assignments are rejected too; they are only
+		// useful in front of a command.
+		return !hasAnyFlag(args, envUnsafeFlags) &&
+			!slices.ContainsFunc(args, func(a string) bool { return !strings.HasPrefix(a, "-") || a == "--" })
+	case "hostname":
+		// Any operand, or a file to read it from, sets the hostname.
+		return !hasAnyFlag(args, []string{"-F", "--file", "-b", "--boot"}) &&
+			!slices.ContainsFunc(args, func(a string) bool { return !strings.HasPrefix(a, "-") })
+	case "awk":
+		return isReadOnlyAwk(args)
+	case "journalctl":
+		return !hasAnyFlag(args, append([]string{"--rotate", "--flush", "--sync", "--relinquish-var", "--setup-keys"}, followFlags...)) &&
+			!slices.ContainsFunc(args, func(a string) bool { return strings.HasPrefix(a, "--vacuum") })
+	}
+
+	allowed, ok := readOnlySubcommands[name]
+	if !ok {
+		return false
+	}
+	sub, rest := subcommand(args, subcommandValueFlags[name])
+	if !slices.Contains(allowed, sub) || hasAnyFlag(rest, subcommandUnsafeFlags[name+" "+sub]) {
+		return false
+	}
+	if name == "git" {
+		return isReadOnlyGit(args, sub, rest)
+	}
+	if sub == "logs" && hasAnyFlag(rest, followFlags) {
+		return false
+	}
+	return true
+}
+
+// isReadOnlyGit checks git global options and subcommands that are
+// read-only only in their listing form.
+func isReadOnlyGit(args []string, sub string, rest []string) bool {
+	// -c and --config-env can override config such as core.pager or aliases.
+	for _, a := range args {
+		if a == sub {
+			break
+		}
+		if a == "-c" || strings.HasPrefix(a, "--config-env") || strings.HasPrefix(a, "--exec-path") {
+			return false
+		}
+	}
+
+	if hasAnyFlag(rest, gitUnsafeFlags) {
+		return false
+	}
+
+	switch sub {
+	case "branch":
+		if hasAnyFlag(rest, []string{
+			"-d", "-D", "--delete", "-m", "-M", "--move", "-c", "-C", "--copy",
+			"-u", "--set-upstream-to", "--unset-upstream", "--edit-description", "-f", "--force",
+		}) {
+			return false
+		}
+		return onlyFlagValues(rest, []string{"--merged", "--no-merged", "--contains", "--no-contains", "--points-at", "--sort", "--format"}) ||
+			slices.Contains(rest, "--list") || slices.Contains(rest, "-l")
+	case "tag":
+		return len(rest) == 0 || slices.Contains(rest, "-l") || slices.Contains(rest, "--list")
+	case "remote":
+		return len(rest) == 0 || rest[0] == "-v" || rest[0] == "--verbose" || rest[0] == "show" || rest[0] == "get-url"
+	case "stash":
+		return len(rest) > 0 && (rest[0] == "list" || rest[0] == "show")
+	case "reflog":
+		return len(rest) == 0 || rest[0] == "show" || strings.HasPrefix(rest[0], "-")
+	}
+	return true
+}
+
+// awkUnsafeFlags read the program from a file or load extensions, which
+// could do anything.
+var awkUnsafeFlags = []string{"-f", "--file", "-i", "--include", "-E", "--exec", "-l", "--load"}
+
+// isReadOnlyAwk rejects awk programs that can run commands, write files or
+// load other code. Comparisons using '>' are rejected too; telling them apart
+// from output redirection would need a full awk parser.
+func isReadOnlyAwk(args []string) bool {
+	for _, a := range args {
+		for _, f := range awkUnsafeFlags {
+			// short flags also take their value attached, as in -fprog.awk
+			if a == f || strings.HasPrefix(a, f+"=") || (len(f) == 2 && strings.HasPrefix(a, f)) {
+				return false
+			}
+		}
+		if strings.Contains(a, "system") || strings.Contains(a, "getline") ||
+			strings.Contains(a, "@include") || strings.Contains(a, "@load") ||
+			strings.ContainsAny(a, ">|") {
+			return false
+		}
+	}
+	return true
+}
+
+// subcommand returns the first positional argument and everything after it,
+// skipping global flags and the values of flags listed in valueFlags.
+func subcommand(args []string, valueFlags []string) (string, []string) {
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if !strings.HasPrefix(a, "-") {
+			return a, args[i+1:]
+		}
+		if !strings.Contains(a, "=") && slices.Contains(valueFlags, a) {
+			i++
+		}
+	}
+	return "", nil
+}
+
+// onlyFlagValues reports whether every positional argument in args is the
+// value of one of the given flags.
+func onlyFlagValues(args []string, valueFlags []string) bool {
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if strings.HasPrefix(a, "-") {
+			if !strings.Contains(a, "=") && slices.Contains(valueFlags, a) {
+				i++
+			}
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// hasAnyFlag reports whether args contain one of flags, either as a separate
+// word, in --flag=value form, or (for single-letter flags) inside a short
+// flag cluster such as -rf.
+func hasAnyFlag(args []string, flags []string) bool {
+	for _, a := range args {
+		if a == "--" {
+			return false
+		}
+		for _, f := range flags {
+			if a == f || strings.HasPrefix(a, f+"=") {
+				return true
+			}
+			if len(f) == 2 && f[0] == '-' && len(a) > 2 && a[0] == '-' && a[1] != '-' &&
+				strings.ContainsRune(a[1:], rune(f[1])) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isAssignment(word string) bool {
+	name, _, ok := strings.Cut(word, "=")
+	if !ok || name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r != '_' && (r < 'A' || r > 'Z') && (r < 'a' || r > 'z') && (i == 0 || r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// splitSimpleCommands tokenizes command into the words of each simple command,
+// splitting at |, ||, &&, ; and newlines outside of quotes. It returns false
+// when the command uses constructs the classifier does not reason about:
+// parameter expansion, command or process substitution, brace expansion,
+// background jobs, subshells, or redirections to anything but /dev/null and
+// file descriptors. Expansions are rejected because they turn into words only
+// when the shell runs the command, e.g. ${u:--delete} or -{delete,print}.
+func splitSimpleCommands(command string) ([][]string, bool) {
+	var (
+		segments [][]string
+		words    []string
+		word     strings.Builder
+		inWord   bool
+		inSingle bool
+		inDouble bool
+	)
+
+	flushWord := func() {
+		if inWord {
+			words = append(words, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+	flushSegment := func() bool {
+		flushWord()
+		if len(words) == 0 {
+			return false
+		}
+		segments = append(segments, words)
+		words = nil
+		return true
+	}
+
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+
+		if inSingle {
+			if c == '\'' {
+				inSingle = false
+			} else {
+				word.WriteByte(c)
+			}
+			continue
+		}
+
+		if c == '`' || c == '$' {
+			return nil, false
+		}
+
+		if inDouble {
+			switch {
+			case c == '"':
+				inDouble = false
+			case c == '\\' && i+1 < len(command):
+				i++
+				word.WriteByte(command[i])
+			default:
+				word.WriteByte(c)
+			}
+			continue
+		}
+
+		switch c {
+		case '\'':
+			inSingle, inWord = true, true
+		case '"':
+			inDouble, inWord = true, true
+		case '\\':
+			if i+1 < len(command) {
+				i++
+				if command[i] != '\n' {
+					word.WriteByte(command[i])
+					inWord = true
+				}
+			}
+		case ' ', '\t':
+			flushWord()
+		case '#':
+			if inWord {
+				word.WriteByte(c)
+				continue
+			}
+			for i < len(command) && command[i] != '\n' {
+				i++
+			}
+			i--
+		case '|', ';', '\n':
+			if c == '|' && i+1 < len(command) && command[i+1] == '|' {
+				i++
+			}
+			if !flushSegment() && c == '|' {
+				return nil, false
+			}
+		case '&':
+			if i+1 < len(command) && command[i+1] == '&' {
+				i++
+				if !flushSegment() {
+					return nil, false
+				}
+				continue
+			}
+			return nil, false
+		case '(', ')', '{', '}':
+			// Inside a word too: fish runs word(cmd) as a command
+			// substitution and sh expands a{b,c} into several words.
+			return nil, false
+		case '<':
+			if i+1 < len(command) && command[i+1] == '(' {
+				return nil, false
+			}
+			word.WriteByte(c)
+			inWord = true
+		case '>':
+			n, ok := redirectTarget(command[i+1:])
+			if !ok {
+				return nil, false
+			}
+			// Drop the redirection (and a leading fd number) from the words.
+			if inWord && isDigits(word.String()) {
+				word.Reset()
+				inWord = false
+			}
+			flushWord()
+			i += n
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+
+	if inSingle || inDouble {
+		return nil, false
+	}
+	flushWord()
+	if len(words) > 0 {
+		segments = append(segments, words)
+	}
+	return segments, true
+}
+
+// redirectTarget validates the target of an output redirection that starts
+// right after '>'. Only /dev/null and file descriptor duplication (>&N) are
+// accepted. It returns the number of bytes consumed.
+func redirectTarget(s string) (int, bool) {
+	n := 0
+	if strings.HasPrefix(s, ">") {
+		n++
+	}
+	if strings.HasPrefix(s[n:], "&") {
+		j := n + 1
+		for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+			j++
+		}
+		if j == n+1 {
+			return 0, false
+		}
+		return j, true
+	}
+	for n < len(s) && (s[n] == ' ' || s[n] == '\t') {
+		n++
+	}
+	const devNull = "/dev/null"
+	if !strings.HasPrefix(s[n:], devNull) {
+		return 0, false
+	}
+	end := n + len(devNull)
+	if end < len(s) && !strings.ContainsRune(" \t\n;|&", rune(s[end])) {
+		return 0, false
+	}
+	return end, true
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
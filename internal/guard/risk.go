@@ -0,0 +1,118 @@
+package guard
+
+import (
+	"regexp"
+	"slices"
+)
+
+// RiskLevel ranks how much damage running a command could do.
+type RiskLevel int
+
+const (
+	// RiskLow commands only read state.
+	RiskLow RiskLevel = iota
+	// RiskMedium commands change state in ways that are usually recoverable,
+	// or could not be verified as read-only.
+	RiskMedium
+	// RiskHigh commands destroy data, rewrite history or affect the whole
+	// system and are hard or impossible to undo.
+	RiskHigh
+)
+
+func (l RiskLevel) String() string {
+	switch l {
+	case RiskLow:
+		return "low"
+	case RiskMedium:
+		return "medium"
+	}
+	return "high"
+}
+
+// Risk is the classification of a command with the reasons behind it.
+type Risk struct {
+	Level   RiskLevel
+	Reasons []string
+}
+
+// riskRule flags commands matching re with level and reason. Of several
+// matching rules with the same tool only the first counts.
+type riskRule struct {
+	re     *regexp.Regexp
+	level  RiskLevel
+	reason string
+	tool   string
+}
+
+// cmdStart matches the start of a simple command: the beginning of the
+// line or a separator, optionally followed by sudo, env (including the
+// string env -S splits into a command) or env assignments.
+const cmdStart = `(?:^|[;&|(\x60]|\$\()\s*(?:sudo\s+(?:-\S+\s+)*)?` +
+	`(?:env\s+(?:-\S+\s+)*(?:(?:-\w*S|--split-string=?)\s*['"]?)?)?(?:\w+=\S*\s+)*`
+
+var riskRules = []riskRule{
+	{regexp.MustCompile(cmdStart + `rm\s+(?:-\S*\s+)*-\S*[rR]`), RiskHigh, "recursively deletes files (rm -r)", "rm"},
+	{regexp.MustCompile(cmdStart + `rm\s`), RiskMedium, "deletes files (rm)", "rm"},
+	{regexp.MustCompile(`\bfind\b.*\s-(?:delete|exec\s+rm)\b`), RiskHigh, "deletes every file find matches", ""},
+	{regexp.MustCompile(cmdStart + `(?:shred|wipefs)\b`), RiskHigh, "irrecoverably erases data", ""},
+	{regexp.MustCompile(cmdStart + `(?:dd\s.*\bof=|mkfs(?:\.\w+)?\b|fdisk\b|parted\b|sfdisk\b)`), RiskHigh, "writes to a disk or filesystem directly", ""},
+	{regexp.MustCompile(`>\s*/dev/(?:sd|nvme|hd|disk|mmcblk)`), RiskHigh, "overwrites a block device", ""},
+	{regexp.MustCompile(cmdStart + `(?:shutdown|reboot|halt|poweroff)\b|\binit\s+[06]\b`), RiskHigh, "shuts down or reboots the machine", ""},
+	{regexp.MustCompile(`:\(\)\s*\{\s*:\s*\|\s*:&\s*\}`), RiskHigh, "fork bomb", ""},
+	{regexp.MustCompile(`\b(?:curl|wget)\b[^|]*\|\s*(?:sudo\s+)?(?:ba|z|da|fi)?sh\b`), RiskHigh, "runs a script downloaded from the internet", ""},
+	{regexp.MustCompile(`\bchmod\s+(?:-\S+\s+)*-\S*R.*\b777\b|\bchmod\s+(?:-\S+\s+)*777\s+/`), RiskHigh, "makes files writable by everyone", ""},
+	{regexp.MustCompile(`\bchown\s+(?:-\S+\s+)*-\S*R\S*\s+\S+\s+/(?:\s|$)`), RiskHigh, "changes ownership of the whole filesystem", ""},
+	{regexp.MustCompile(`\bgit\s+(?:\S+\s+)*push\b.*(?:\s--force\b|\s-f\b|\s--force-with-lease\b|\s\+\S)`), RiskHigh, "rewrites remote history (git push --force)", ""},
+	{regexp.MustCompile(`\bgit\s+(?:\S+\s+)*reset\s+(?:\S+\s+)*--hard\b`), RiskHigh, "discards uncommitted changes (git reset --hard)", ""},
+	{regexp.MustCompile(`\bgit\s+(?:\S+\s+)*clean\s+(?:\S+\s+)*-\S*f`), RiskHigh, "deletes untracked files (git clean -f)", ""},
+	{regexp.MustCompile(`\bgit\s+(?:\S+\s+)*(?:checkout|restore)\s+(?:--\s+)?\.(?:\s|$)`), RiskHigh, "discards uncommitted changes", ""},
+	{regexp.MustCompile(`\bgit\s+(?:\S+\s+)*branch\s+(?:\S+\s+)*-D\b`), RiskMedium, "force-deletes branches", ""},
+	{regexp.MustCompile(`\bkubectl\s+(?:\S+\s+)*delete\b`), RiskHigh, "deletes Kubernetes resources", ""},
+	{regexp.MustCompile(`\bhelm\s+(?:\S+\s+)*(?:uninstall|delete)\b`), RiskHigh, "uninstalls a Helm release", ""},
+	{regexp.MustCompile(`\bdocker\s+(?:\S+\s+)*(?:system|volume|image|container|network)\s+prune\b`), RiskHigh, "removes Docker data in bulk", ""},
+	{regexp.MustCompile(`\bdocker\s+(?:\S+\s+)*(?:rm|rmi|volume\s+rm)\b`), RiskMedium, "removes Docker containers, images or volumes", ""},
+	{regexp.MustCompile(`\bterraform\s+(?:\S+\s+)*destroy\b`), RiskHigh, "destroys infrastructure", ""},
+	{regexp.MustCompile(`(?i)\b(?:drop\s+(?:table|database|schema)|truncate\s+table)\b`), RiskHigh, "drops database objects", ""},
+	{regexp.MustCompile(cmdStart + `(?:kill|pkill|killall)\b`), RiskMedium, "terminates processes", ""},
+	{regexp.MustCompile(cmdStart + `systemctl\s+(?:\S+\s+)*(?:stop|restart|disable|mask)\b`), RiskMedium, "stops or restarts services", ""},
+	{regexp.MustCompile(`(?:^|\s)sudo\s`), RiskMedium, "runs with root privileges", ""},
+	{regexp.MustCompile(`>`), RiskMedium, "writes to files through redirection", ""},
+	{regexp.MustCompile(cmdStart + `(?:sort|tree)\s+(?:\S+\s+)*-\w*o`), RiskMedium, "writes output to a file", "output"},
+	{regexp.MustCompile(`\bgit\s.*\s--output(?:=|\s|$)|\s--output-dir\b`), RiskMedium, "writes output to a file", "output"},
+	{regexp.MustCompile(`\s--(?:pre|pre-glob|compress-program|ext-diff)(?:=|\s|$)|\bgit\s+(?:\S+\s+)*grep\s+(?:\S+\s+)*(?:-\w*O|--open-files-in-pager)`), RiskMedium, "runs another program", ""},
+	{regexp.MustCompile(cmdStart + `env\s+(?:-\S+\s+)*(?:-\w*S|--split-string)`), RiskMedium, "runs a command through env -S", ""},
+	{regexp.MustCompile(cmdStart + `hostname\s+(?:-\S+\s+)*[^-\s;&|)]`), RiskMedium, "changes the hostname", ""},
+	{regexp.MustCompile(`\bsed\s+(?:\S+\s+)*-i`), RiskMedium, "edits files in place", ""},
+}
+
+var harmlessRedirectRe = regexp.MustCompile(`\d*>>?\s*/dev/null\b|\d*>&\d`)
+
+// ClassifyRisk rates command by the most dangerous thing it does. The risk
+// rules run first, so a rule can never be overridden by the read-only
+// fallback: only commands that no rule matches and IsReadOnly accepts are
+// low risk, and anything else is medium risk because it could not be
+// verified as read-only.
+func ClassifyRisk(command string) Risk {
+	// Discarding output or duplicating descriptors writes nothing.
+	stripped := harmlessRedirectRe.ReplaceAllString(command, " ")
+
+	risk := Risk{Level: RiskLow}
+	var tools []string
+	for _, rule := range riskRules {
+		if !rule.re.MatchString(stripped) || (rule.tool != "" && slices.Contains(tools, rule.tool)) {
+			continue
+		}
+		if rule.tool != "" {
+			tools = append(tools, rule.tool)
+		}
+		risk.Level = max(risk.Level, rule.level)
+		risk.Reasons = append(risk.Reasons, rule.reason)
+	}
+	if len(risk.Reasons) > 0 {
+		return risk
+	}
+	if IsReadOnly(command) {
+		return Risk{Level: RiskLow, Reasons: []string{"only reads state"}}
+	}
+	return Risk{Level: RiskMedium, Reasons: []string{"may change state; not verified as read-only"}}
+}
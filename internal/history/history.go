@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"slices"
+	"strings"
 	"time"
 )
 
@@ -33,6 +34,11 @@ func NewStore(dir string) *Store {
 	return &Store{filePath: filepath.Join(dir, fileName)}
 }
 
+// Dir returns the directory the history is persisted in.
+func (s *Store) Dir() string {
+	return filepath.Dir(s.filePath)
+}
+
 // Add appends an entry, rotates to the last maxEntries, and writes atomically.
 func (s *Store) Add(entry Entry) error {
 	entries, err := s.readAll()
@@ -71,6 +77,59 @@ func (s *Store) List() ([]Entry, error) {
 	return entries, nil
 }
 
+// Queries returns the distinct queries, newest first.
+func (s *Store) Queries() ([]string, error) {
+	entries, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(entries))
+	queries := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.Query == "" || seen[e.Query] {
+			continue
+		}
+		seen[e.Query] = true
+		queries = append(queries, e.Query)
+	}
+	return queries, nil
+}
+
+// Selected returns the distinct selected commands, newest first.
+func (s *Store) Selected() ([]string, error) {
+	entries, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(entries))
+	commands := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.Selected == "" || seen[e.Selected] {
+			continue
+		}
+		seen[e.Selected] = true
+		commands = append(commands, e.Selected)
+	}
+	return commands, nil
+}
+
+// Search returns the entries whose query or selected command contains
+// term, ignoring case, newest first.
+func (s *Store) Search(term string) ([]Entry, error) {
+	entries, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	term = strings.ToLower(term)
+	matches := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		if strings.Contains(strings.ToLower(e.Query), term) || strings.Contains(strings.ToLower(e.Selected), term) {
+			matches = append(matches, e)
+		}
+	}
+	return matches, nil
+}
+
 // ErrEmpty is returned when history has no entries.
 var ErrEmpty = errors.New("history is empty")
 
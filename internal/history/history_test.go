@@ -3,6 +3,7 @@ package history
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 	"time"
 )
@@ -203,6 +204,89 @@ func TestList_Ordering(t *testing.T) {
 	}
 }
 
+func TestQueries_DeduplicatedNewestFirst(t *testing.T) {
+	s := tempStore(t)
+
+	for _, q := range []string{"list pods", "disk usage", "list pods", ""} {
+		if err := s.Add(sampleEntry(q)); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+
+	queries, err := s.Queries()
+	if err != nil {
+		t.Fatalf("Queries() error = %v", err)
+	}
+
+	want := []string{"list pods", "disk usage"}
+	if len(queries) != len(want) {
+		t.Fatalf("Queries() = %v, want %v", queries, want)
+	}
+	for i := range want {
+		if queries[i] != want[i] {
+			t.Errorf("queries[%d] = %q, want %q", i, queries[i], want[i])
+		}
+	}
+}
+
+func TestSelected_DeduplicatedNewestFirst(t *testing.T) {
+	s := tempStore(t)
+
+	for _, cmd := range []string{"kubectl get pods", "du -sh *", "kubectl get pods", ""} {
+		e := sampleEntry("query")
+		e.Selected = cmd
+		if err := s.Add(e); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+
+	got, err := s.Selected()
+	if err != nil {
+		t.Fatalf("Selected() error = %v", err)
+	}
+	want := []string{"kubectl get pods", "du -sh *"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Selected() = %v, want %v", got, want)
+	}
+}
+
+func TestSearch_MatchesQueryOrCommand(t *testing.T) {
+	s := tempStore(t)
+
+	for _, e := range []Entry{
+		{Query: "list pods", Selected: "kubectl get pods"},
+		{Query: "disk usage", Selected: "du -sh *"},
+		{Query: "restart Pods", Selected: "kubectl rollout restart deploy/web"},
+	} {
+		if err := s.Add(e); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		term string
+		want []string
+	}{
+		{"pods", []string{"restart Pods", "list pods"}},
+		{"KUBECTL", []string{"restart Pods", "list pods"}},
+		{"du -sh", []string{"disk usage"}},
+		{"docker", []string{}},
+	}
+	for _, tt := range tests {
+		entries, err := s.Search(tt.term)
+		if err != nil {
+			t.Fatalf("Search() error = %v", err)
+		}
+		got := make([]string, len(entries))
+		for i, e := range entries {
+			got[i] = e.Query
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Search(%q) = %v, want %v", tt.term, got, tt.want)
+		}
+	}
+}
+
 func TestReadAll_CorruptedFile(t *testing.T) {
 	s := tempStore(t)
 	if err := os.WriteFile(s.filePath, []byte("not json"), 0o644); err != nil {
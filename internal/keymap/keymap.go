@@ -0,0 +1,330 @@
+package keymap
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Preset names accepted by Preset.
+const (
+	PresetDefault = "default"
+	PresetEmacs   = "emacs"
+	PresetVi      = "vi"
+)
+
+// reservedKey always quits and cannot be rebound.
+const reservedKey = "ctrl+c"
+
+// KeyMap holds key bindings shared by the TUI and the action menu.
+// Keys use bubbletea notation, e.g. "up", "ctrl+j", "enter", "esc" or "?".
+type KeyMap struct {
+	// TUI navigation
+	Up      []string
+	Down    []string
+	Accept  []string
+	Newline []string
+	Search  []string
+	Score   []string // show the ranking score of the variants
+
+	// shared by the TUI and the action menu
+	Cancel []string
+	Help   []string
+
+	// action menu
+	Execute []string
+	Copy    []string
+	Revise  []string
+	Explain []string
+	Fix     []string // offered after an executed command fails
+	Quit    []string
+
+	// step confirmation in script mode
+	Run  []string
+	Skip []string
+	Stop []string
+}
+
+// Default returns the built-in bindings: arrow keys and ctrl+r in the TUI
+// and single letters in the action menu.
+func Default() KeyMap {
+	return KeyMap{
+		Up:      []string{"up"},
+		Down:    []string{"down"},
+		Accept:  []string{"enter"},
+		Newline: []string{"alt+enter"},
+		Search:  []string{"ctrl+r"},
+		Score:   []string{"tab"},
+		Cancel:  []string{"esc"},
+		Help:    []string{"?"},
+		Execute: []string{"e"},
+		Copy:    []string{"c"},
+		Revise:  []string{"r"},
+		Explain: []string{"i"},
+		Fix:     []string{"f"},
+		Quit:    []string{"q", "enter"},
+		Run:     []string{"y", "enter"},
+		Skip:    []string{"s"},
+		Stop:    []string{"q"},
+	}
+}
+
+// Preset returns the bindings for a named preset.
+func Preset(name string) (KeyMap, error) {
+	km := Default()
+	switch name {
+	case "", PresetDefault:
+	case PresetEmacs:
+		km.Up = []string{"up", "ctrl+p"}
+		km.Down = []string{"down", "ctrl+n"}
+		km.Cancel = []string{"esc", "ctrl+g"}
+	case PresetVi:
+		km.Up = []string{"up", "ctrl+k"}
+		km.Down = []string{"down", "ctrl+j"}
+	default:
+		return KeyMap{}, fmt.Errorf("unknown key preset %q (supported: %s, %s, %s)", name, PresetDefault, PresetEmacs, PresetVi)
+	}
+	return km, nil
+}
+
+// WithDefaults returns a copy of k where every unbound action uses the
+// default bindings.
+func (k KeyMap) WithDefaults() KeyMap {
+	d := Default()
+	fill := func(dst *[]string, def []string) {
+		if len(*dst) == 0 {
+			*dst = def
+		}
+	}
+	fill(&k.Up, d.Up)
+	fill(&k.Down, d.Down)
+	fill(&k.Accept, d.Accept)
+	fill(&k.Newline, d.Newline)
+	fill(&k.Search, d.Search)
+	fill(&k.Score, d.Score)
+	fill(&k.Cancel, d.Cancel)
+	fill(&k.Help, d.Help)
+	fill(&k.Execute, d.Execute)
+	fill(&k.Copy, d.Copy)
+	fill(&k.Revise, d.Revise)
+	fill(&k.Explain, d.Explain)
+	fill(&k.Fix, d.Fix)
+	fill(&k.Quit, d.Quit)
+	fill(&k.Run, d.Run)
+	fill(&k.Skip, d.Skip)
+	fill(&k.Stop, d.Stop)
+	return k
+}
+
+// binding pairs an action name with its keys for validation and help output.
+type binding struct {
+	action string
+	keys   []string
+}
+
+// tuiBindings returns the bindings active in the TUI, in display order.
+func (k KeyMap) tuiBindings() []binding {
+	return []binding{
+		{"up", k.Up},
+		{"down", k.Down},
+		{"accept", k.Accept},
+		{"newline", k.Newline},
+		{"search", k.Search},
+		{"score", k.Score},
+		{"cancel", k.Cancel},
+		{"help", k.Help},
+	}
+}
+
+// menuBindings returns the bindings active in the action menu and the fix
+// prompt that follows a failed execution, in display order.
+func (k KeyMap) menuBindings() []binding {
+	return []binding{
+		{"execute", k.Execute},
+		{"copy", k.Copy},
+		{"revise", k.Revise},
+		{"explain", k.Explain},
+		{"fix", k.Fix},
+		{"quit", k.Quit},
+		{"cancel", k.Cancel},
+		{"help", k.Help},
+	}
+}
+
+// stepBindings returns the bindings active when confirming a script step,
+// in display order.
+func (k KeyMap) stepBindings() []binding {
+	return []binding{
+		{"run", k.Run},
+		{"skip", k.Skip},
+		{"stop", k.Stop},
+		{"cancel", k.Cancel},
+	}
+}
+
+// Validate reports unknown key names, keys the action menu cannot read,
+// printable characters that would block typing in the TUI input, and keys
+// bound to more than one action in the same context.
+func (k KeyMap) Validate() error {
+	for _, b := range k.tuiBindings() {
+		for _, key := range b.keys {
+			if !validKeyName(key) {
+				return fmt.Errorf("keys.%s: unknown key %q", b.action, key)
+			}
+			if b.action != "help" && isPrintable(key) {
+				return fmt.Errorf("keys.%s: %q is a printable character and would block typing", b.action, key)
+			}
+		}
+	}
+	for _, b := range append(k.menuBindings(), k.stepBindings()...) {
+		for _, key := range b.keys {
+			if _, ok := menuBytes(key); !ok {
+				return fmt.Errorf("keys.%s: %q cannot be used in the action menu", b.action, key)
+			}
+		}
+	}
+
+	if err := checkConflicts(k.tuiBindings(), normalizeKey); err != nil {
+		return err
+	}
+	if err := checkConflicts(k.menuBindings(), normalizeMenuKey); err != nil {
+		return err
+	}
+	return checkConflicts(k.stepBindings(), normalizeMenuKey)
+}
+
+// normalizeMenuKey maps a key to the byte the action menu compares:
+// letters case-insensitively, and a line feed (ctrl+j) the same as enter.
+func normalizeMenuKey(key string) string {
+	b, _ := menuBytes(key)
+	if isLetter(b) {
+		b |= 0x20
+	}
+	if b == '\n' {
+		b = '\r'
+	}
+	return string(b)
+}
+
+func checkConflicts(bindings []binding, norm func(string) string) error {
+	owner := map[string]string{norm(reservedKey): "quit (reserved)"}
+	for _, b := range bindings {
+		for _, key := range b.keys {
+			n := norm(key)
+			if prev, ok := owner[n]; ok && prev != b.action {
+				return fmt.Errorf("keys: %q is bound to both %s and %s", key, prev, b.action)
+			}
+			owner[n] = b.action
+		}
+	}
+	return nil
+}
+
+// normalizeKey maps aliases to a canonical key name.
+func normalizeKey(key string) string {
+	switch key {
+	case "escape":
+		return "esc"
+	case "return":
+		return "enter"
+	case " ":
+		return "space"
+	case "ctrl+m":
+		return "enter"
+	case "ctrl+i":
+		return "tab"
+	}
+	return key
+}
+
+var namedKeys = []string{
+	"up", "down", "left", "right", "enter", "return", "esc", "escape", "tab",
+	"shift+tab", "space", "backspace", "delete", "home", "end", "pgup", "pgdown",
+}
+
+func validKeyName(key string) bool {
+	if isPrintable(key) || slices.Contains(namedKeys, key) {
+		return true
+	}
+	if c, ok := strings.CutPrefix(key, "ctrl+"); ok {
+		return len(c) == 1 && c[0] >= 'a' && c[0] <= 'z'
+	}
+	if c, ok := strings.CutPrefix(key, "alt+"); ok {
+		return isPrintable(c) || slices.Contains(namedKeys, c)
+	}
+	if f, ok := strings.CutPrefix(key, "f"); ok {
+		return slices.Contains([]string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12"}, f)
+	}
+	return false
+}
+
+func isPrintable(key string) bool {
+	return len(key) == 1 && key[0] > ' ' && key[0] < 0x7f
+}
+
+// menuBytes returns the byte a raw-mode terminal sends for key, for keys
+// that produce a single byte.
+func menuBytes(key string) (byte, bool) {
+	switch normalizeKey(key) {
+	case "enter":
+		return '\r', true
+	case "esc":
+		return 0x1b, true
+	case "tab":
+		return '\t', true
+	case "space":
+		return ' ', true
+	}
+	if isPrintable(key) {
+		return key[0], true
+	}
+	if c, ok := strings.CutPrefix(key, "ctrl+"); ok && len(c) == 1 && c[0] >= 'a' && c[0] <= 'z' {
+		return c[0] & 0x1f, true
+	}
+	return 0, false
+}
+
+// MatchesByte reports whether b, read from a raw-mode terminal, is one of
+// keys. Letters match case-insensitively and "enter" also matches a bare
+// line feed.
+func MatchesByte(keys []string, b byte) bool {
+	for _, key := range keys {
+		kb, ok := menuBytes(key)
+		if !ok {
+			continue
+		}
+		if kb == b || (isLetter(kb) && kb|0x20 == b|0x20) {
+			return true
+		}
+		if kb == '\r' && b == '\n' {
+			return true
+		}
+	}
+	return false
+}
+
+func isLetter(b byte) bool {
+	return (b|0x20) >= 'a' && (b|0x20) <= 'z'
+}
+
+// MenuHelp returns a single-line summary of the action menu bindings.
+func (k KeyMap) MenuHelp() string {
+	return helpLine(k.menuBindings())
+}
+
+// HelpLines returns "action: keys" lines for every TUI binding.
+func (k KeyMap) HelpLines() []string {
+	lines := make([]string, 0, len(k.tuiBindings()))
+	for _, b := range k.tuiBindings() {
+		lines = append(lines, fmt.Sprintf("%-7s %s", b.action, strings.Join(b.keys, ", ")))
+	}
+	return lines
+}
+
+func helpLine(bindings []binding) string {
+	parts := make([]string, 0, len(bindings))
+	for _, b := range bindings {
+		parts = append(parts, b.action+": "+strings.Join(b.keys, "/"))
+	}
+	return strings.Join(parts, "  ")
+}
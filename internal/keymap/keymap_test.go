@@ -0,0 +1,184 @@
+package keymap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPresets_AreValid(t *testing.T) {
+	for _, name := range []string{"", PresetDefault, PresetEmacs, PresetVi} {
+		km, err := Preset(name)
+		if err != nil {
+			t.Fatalf("Preset(%q) returned error: %v", name, err)
+		}
+		if err := km.Validate(); err != nil {
+			t.Errorf("Preset(%q).Validate() = %v, want nil", name, err)
+		}
+	}
+}
+
+func TestPreset_Vi(t *testing.T) {
+	km, err := Preset(PresetVi)
+	if err != nil {
+		t.Fatalf("Preset(vi) returned error: %v", err)
+	}
+	if strings.Join(km.Down, ",") != "down,ctrl+j" {
+		t.Errorf("Down = %v, want [down ctrl+j]", km.Down)
+	}
+	if strings.Join(km.Up, ",") != "up,ctrl+k" {
+		t.Errorf("Up = %v, want [up ctrl+k]", km.Up)
+	}
+}
+
+func TestPreset_Unknown(t *testing.T) {
+	if _, err := Preset("nano"); err == nil {
+		t.Error("Preset(\"nano\") expected error, got nil")
+	}
+}
+
+func TestWithDefaults(t *testing.T) {
+	km := KeyMap{Up: []string{"ctrl+k"}}.WithDefaults()
+
+	if strings.Join(km.Up, ",") != "ctrl+k" {
+		t.Errorf("Up = %v, want override [ctrl+k]", km.Up)
+	}
+	if strings.Join(km.Down, ",") != "down" {
+		t.Errorf("Down = %v, want default [down]", km.Down)
+	}
+	if strings.Join(km.Execute, ",") != "e" {
+		t.Errorf("Execute = %v, want default [e]", km.Execute)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*KeyMap)
+		wantErr string
+	}{
+		{
+			name:    "tui conflict",
+			modify:  func(k *KeyMap) { k.Down = []string{"up"} },
+			wantErr: `"up" is bound to both up and down`,
+		},
+		{
+			name:    "menu conflict",
+			modify:  func(k *KeyMap) { k.Copy = []string{"e"} },
+			wantErr: `"e" is bound to both execute and copy`,
+		},
+		{
+			name:    "menu conflict is case-insensitive",
+			modify:  func(k *KeyMap) { k.Copy = []string{"E"} },
+			wantErr: "bound to both execute and copy",
+		},
+		{
+			name:    "line feed conflicts with enter in menu",
+			modify:  func(k *KeyMap) { k.Execute = []string{"ctrl+j"} },
+			wantErr: "bound to both execute and quit",
+		},
+		{
+			name:    "reserved ctrl+c",
+			modify:  func(k *KeyMap) { k.Up = []string{"ctrl+c"} },
+			wantErr: "quit (reserved)",
+		},
+		{
+			name:    "printable navigation key",
+			modify:  func(k *KeyMap) { k.Down = []string{"j"} },
+			wantErr: "would block typing",
+		},
+		{
+			name:    "unknown key name",
+			modify:  func(k *KeyMap) { k.Up = []string{"hyper+x"} },
+			wantErr: `unknown key "hyper+x"`,
+		},
+		{
+			name:   "alt with named key",
+			modify: func(k *KeyMap) { k.Newline = []string{"alt+enter", "ctrl+o"} },
+		},
+		{
+			name:    "newline conflicts with accept",
+			modify:  func(k *KeyMap) { k.Newline = []string{"enter"} },
+			wantErr: `"enter" is bound to both accept and newline`,
+		},
+		{
+			name:    "multi-byte key in menu",
+			modify:  func(k *KeyMap) { k.Execute = []string{"f5"} },
+			wantErr: "cannot be used in the action menu",
+		},
+		{
+			name:   "same key in different contexts",
+			modify: func(k *KeyMap) { k.Accept = []string{"enter"}; k.Quit = []string{"enter"} },
+		},
+		{
+			name:    "step conflict",
+			modify:  func(k *KeyMap) { k.Skip = []string{"Y"} },
+			wantErr: "bound to both run and skip",
+		},
+		{
+			name:    "multi-byte key in step prompt",
+			modify:  func(k *KeyMap) { k.Stop = []string{"f10"} },
+			wantErr: "cannot be used in the action menu",
+		},
+		{
+			name:   "step and menu keys are separate contexts",
+			modify: func(k *KeyMap) { k.Run = []string{"e"} },
+		},
+		{
+			name:   "rebound menu letter",
+			modify: func(k *KeyMap) { k.Execute = []string{"x"} },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			km := Default()
+			tt.modify(&km)
+			err := km.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMatchesByte(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []string
+		b    byte
+		want bool
+	}{
+		{"letter", []string{"e"}, 'e', true},
+		{"letter uppercase", []string{"e"}, 'E', true},
+		{"other letter", []string{"e"}, 'x', false},
+		{"enter carriage return", []string{"enter"}, '\r', true},
+		{"enter line feed", []string{"enter"}, '\n', true},
+		{"ctrl key", []string{"ctrl+x"}, 0x18, true},
+		{"ctrl key not letter", []string{"ctrl+x"}, 'x', false},
+		{"esc", []string{"esc"}, 0x1b, true},
+		{"symbol", []string{"?"}, '?', true},
+		{"unsupported key ignored", []string{"up"}, 'A', false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchesByte(tt.keys, tt.b); got != tt.want {
+				t.Errorf("MatchesByte(%v, 0x%02x) = %v, want %v", tt.keys, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMenuHelp(t *testing.T) {
+	help := Default().MenuHelp()
+	for _, want := range []string{"execute: e", "copy: c", "revise: r", "explain: i", "quit: q/enter", "cancel: esc"} {
+		if !strings.Contains(help, want) {
+			t.Errorf("MenuHelp() = %q, want it to contain %q", help, want)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Package lastcmd reads the last command run in the shell, as recorded by
+// the hook in the shell integration scripts.
+package lastcmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"unicode/utf8"
+)
+
+// EnvDir names the environment variable the shell integration exports
+// with the directory it records the last command in.
+const EnvDir = "QX_LAST_COMMAND_DIR"
+
+// Files written by the hook in the record directory.
+const (
+	commandFile = "command"
+	statusFile  = "status"
+	stderrFile  = "stderr"
+)
+
+// MaxErrorOutput caps the error output kept from the end of the capture;
+// the cause of a failure is usually reported last.
+const MaxErrorOutput = 8 * 1024
+
+// ErrNotRecorded is returned when the hook has not recorded a command yet.
+var ErrNotRecorded = errors.New("no command recorded yet")
+
+var (
+	// escapeRe matches terminal escape sequences such as colors.
+	escapeRe = regexp.MustCompile(`\x1b(?:\[[0-9;?]*[ -/]*[@-~]|\][^\x07\x1b]*(?:\x07|\x1b\\)|[@-Z\\-_])`)
+	// controlRe matches control characters except \t and \n.
+	controlRe = regexp.MustCompile(`[\x00-\x08\x0b-\x1f\x7f]`)
+)
+
+// Record is a command with its exit code and the error output captured
+// while it ran. ErrorOutput is empty when stderr capture is disabled.
+type Record struct {
+	Command     string
+	ExitCode    int
+	ErrorOutput string
+}
+
+// Load reads the record from dir. The directory must be private to the
+// current user, so nobody else can read the record or plant a fake one.
+func Load(dir string) (Record, error) {
+	info, err := os.Lstat(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return Record{}, ErrNotRecorded
+	}
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to read last command: %w", err)
+	}
+	if !isPrivateDir(info) {
+		return Record{}, fmt.Errorf("refusing to read the last command from %s: it is not a directory only you can access", dir)
+	}
+
+	command, err := os.ReadFile(filepath.Join(dir, commandFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return Record{}, ErrNotRecorded
+	}
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to read last command: %w", err)
+	}
+
+	status, err := os.ReadFile(filepath.Join(dir, statusFile))
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to read exit code of last command: %w", err)
+	}
+	code, err := strconv.Atoi(strings.TrimSpace(string(status)))
+	if err != nil {
+		return Record{}, fmt.Errorf("invalid exit code of last command: %q", strings.TrimSpace(string(status)))
+	}
+
+	var output string
+	if stderr, err := os.ReadFile(filepath.Join(dir, stderrFile)); err == nil {
+		output = Clean(string(stderr))
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return Record{}, fmt.Errorf("failed to read error output of last command: %w", err)
+	}
+
+	return Record{
+		Command:     strings.TrimSpace(string(command)),
+		ExitCode:    code,
+		ErrorOutput: output,
+	}, nil
+}
+
+// isPrivateDir reports whether info is a directory owned by the current
+// user that nobody else can access.
+func isPrivateDir(info os.FileInfo) bool {
+	if !info.IsDir() || info.Mode().Perm()&0o077 != 0 {
+		return false
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(st.Uid) == os.Getuid()
+}
+
+// Clean makes captured terminal output readable as plain text: it drops
+// escape sequences, keeps only the final state of lines redrawn with \r
+// (progress bars), and keeps at most MaxErrorOutput bytes from the end.
+func Clean(output string) string {
+	output = escapeRe.ReplaceAllString(output, "")
+	lines := strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		if j := strings.LastIndex(strings.TrimRight(line, "\r"), "\r"); j >= 0 {
+			line = line[j+1:]
+		}
+		lines[i] = controlRe.ReplaceAllString(line, "")
+	}
+	output = strings.TrimSpace(strings.Join(lines, "\n"))
+
+	if len(output) <= MaxErrorOutput {
+		return output
+	}
+	output = output[len(output)-MaxErrorOutput:]
+	for len(output) > 0 && !utf8.RuneStart(output[0]) {
+		output = output[1:]
+	}
+	if i := strings.IndexByte(output, '\n'); i >= 0 {
+		output = output[i+1:]
+	}
+	return output
+}
@@ -0,0 +1,129 @@
+package lastcmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// privateTempDir returns a temporary directory only the current user can
+// access, like the one the shell hook creates with mktemp -d.
+func privateTempDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func writeRecord(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := privateTempDir(t)
+	writeRecord(t, dir, map[string]string{
+		commandFile: "git pusj origin main\n",
+		statusFile:  "1\n",
+		stderrFile:  "\x1b[31mgit: 'pusj' is not a git command.\x1b[0m\n",
+	})
+
+	got, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := Record{Command: "git pusj origin main", ExitCode: 1, ErrorOutput: "git: 'pusj' is not a git command."}
+	if got != want {
+		t.Errorf("Load() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLoad_WithoutStderr(t *testing.T) {
+	dir := privateTempDir(t)
+	writeRecord(t, dir, map[string]string{commandFile: "make biuld", statusFile: "2"})
+
+	got, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got.ExitCode != 2 || got.ErrorOutput != "" {
+		t.Errorf("Load() = %+v, want exit code 2 without error output", got)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	dir := privateTempDir(t)
+	if _, err := Load(dir); !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("Load() on empty dir error = %v, want ErrNotRecorded", err)
+	}
+
+	writeRecord(t, dir, map[string]string{commandFile: "ls", statusFile: "oops"})
+	if _, err := Load(dir); err == nil || !strings.Contains(err.Error(), "invalid exit code") {
+		t.Errorf("Load() error = %v, want invalid exit code", err)
+	}
+}
+
+func TestLoad_RequiresPrivateDir(t *testing.T) {
+	dir := privateTempDir(t)
+	writeRecord(t, dir, map[string]string{commandFile: "ls", statusFile: "1"})
+	if err := os.Chmod(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(dir); err == nil || !strings.Contains(err.Error(), "refusing") {
+		t.Errorf("Load() error = %v, want a refusal for a shared directory", err)
+	}
+
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Chmod(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(dir, link); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(link); err == nil {
+		t.Error("Load() should refuse a symlink")
+	}
+
+	if _, err := Load(filepath.Join(dir, "missing")); !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("Load() on a missing dir error = %v, want ErrNotRecorded", err)
+	}
+}
+
+func TestClean(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"colors", "\x1b[1;31merror\x1b[0m: build failed", "error: build failed"},
+		{"progress bar", "downloading 10%\rdownloading 50%\rdownloading 100%\nerror: checksum mismatch", "downloading 100%\nerror: checksum mismatch"},
+		{"crlf", "line one\r\nline two\r\n", "line one\nline two"},
+		{"control characters", "bell\x07 here", "bell here"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Clean(tt.input); got != tt.want {
+				t.Errorf("Clean() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClean_KeepsTail(t *testing.T) {
+	input := strings.Repeat("warning: noise\n", MaxErrorOutput/10) + "error: the real cause"
+	got := Clean(input)
+	if len(got) > MaxErrorOutput {
+		t.Errorf("len(Clean()) = %d, want at most %d", len(got), MaxErrorOutput)
+	}
+	if !strings.HasPrefix(got, "warning: noise\n") || !strings.HasSuffix(got, "error: the real cause") {
+		t.Errorf("Clean() should keep whole lines from the end, got %q...%q", got[:20], got[len(got)-30:])
+	}
+}
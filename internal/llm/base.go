@@ -4,10 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/sashabaranov/go-openai"
+
+	"github.com/evgfitil/qx/internal/structured"
 )
 
 const (
@@ -15,8 +18,21 @@ const (
 
 	// DefaultTemperature defines the temperature for command generation
 	DefaultTemperature = 0.7
+
+	// ExplainTemperature keeps explanations close to the documented behavior
+	ExplainTemperature = 0.2
+
+	// schemaMinSize is the size from which structured stdin is sent with
+	// a summary of its schema.
+	schemaMinSize = 1024
 )
 
+// failedRule tells the model how to use the failed command block.
+const failedRule = "A <failed-command> block holds a command that failed with its exit code and error output: generate corrected commands that remove the cause of the error instead of repeating the command unchanged"
+
+// schemaRule tells the model how to use the stdin schema block.
+const schemaRule = "A <stdin-schema> block lists the paths or columns of structured stdin with their types: use these exact paths in jq/yq filters and these column names or positions in awk, cut and csv tools"
+
 // baseProvider contains common logic for all LLM providers
 type baseProvider struct {
 	client *openai.Client
@@ -45,28 +61,62 @@ func categorizeAPIError(err error) error {
 	return err
 }
 
-// Generate creates shell commands based on user query.
-// pipeContext contains optional stdin data piped into qx for additional context.
-// followUp, when non-nil, injects previous query/command as conversation history for refinement.
-func (p *baseProvider) Generate(ctx context.Context, query string, count int, pipeContext string, followUp *FollowUpContext) ([]string, error) {
-	if query == "" {
+// Generate creates shell commands based on the user query in req.
+// req.FollowUp, when non-nil, injects previous query/command as conversation history for refinement.
+func (p *baseProvider) Generate(ctx context.Context, r Request) ([]Variant, error) {
+	if r.Query == "" {
 		return nil, fmt.Errorf("query cannot be empty")
 	}
 
-	userMessage := query
-	if pipeContext != "" {
-		userMessage = fmt.Sprintf("Context:\n<stdin>\n%s\n</stdin>\n\nTask: %s", pipeContext, query)
+	messages := buildMessages(r)
+	content, err := p.complete(ctx, messages, DefaultTemperature)
+	if err != nil {
+		return nil, err
+	}
+
+	variants, err := ParseCommands([]byte(content), r.Shell)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse LLM output: %w", err)
+	}
+
+	variants = DedupeVariants(variants, r.Shell)
+	if missing := r.Count - len(variants); missing > 0 {
+		variants = p.topUp(ctx, messages, content, variants, missing, r.Shell)
+	}
+	return variants, nil
+}
+
+// topUp asks once for missing more variants after reply, whose commands
+// were duplicates or invalid. A failed top-up is not an error: variants
+// are returned as they are.
+func (p *baseProvider) topUp(ctx context.Context, messages []openai.ChatCompletionMessage, reply string, variants []Variant, missing int, shell Shell) []Variant {
+	messages = append(messages[:len(messages):len(messages)],
+		openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: reply},
+		openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: TopUpPrompt(Commands(variants), missing)},
+	)
+	content, err := p.complete(ctx, messages, DefaultTemperature)
+	if err != nil {
+		return variants
+	}
+	more, err := ParseCommands([]byte(content), shell)
+	if err != nil {
+		return variants
 	}
 
-	messages := buildMessages(count, pipeContext, userMessage, followUp)
+	merged := DedupeVariants(append(variants[:len(variants):len(variants)], more...), shell)
+	return merged[:min(len(merged), len(variants)+missing)]
+}
 
+// complete sends messages and returns the content of the first choice,
+// which is requested as a JSON object.
+func (p *baseProvider) complete(ctx context.Context, messages []openai.ChatCompletionMessage, temperature float32) (string, error) {
 	req := openai.ChatCompletionRequest{
 		Model:    p.model,
 		Messages: messages,
 		ResponseFormat: &openai.ChatCompletionResponseFormat{
 			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
 		},
-		Temperature: DefaultTemperature,
+		Temperature: temperature,
 	}
 
 	if _, ok := ctx.Deadline(); !ok {
@@ -77,46 +127,107 @@ func (p *baseProvider) Generate(ctx context.Context, query string, count int, pi
 
 	resp, err := p.client.CreateChatCompletion(ctx, req)
 	if err != nil {
-		return nil, categorizeAPIError(err)
+		return "", categorizeAPIError(err)
 	}
 
 	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("LLM returned no choices")
+		return "", fmt.Errorf("LLM returned no choices")
 	}
 
 	content := resp.Choices[0].Message.Content
 	if strings.TrimSpace(content) == "" {
-		return nil, fmt.Errorf("LLM returned empty response")
-	}
-
-	commands, err := ParseCommands([]byte(content))
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse LLM output: %w", err)
+		return "", fmt.Errorf("LLM returned empty response")
 	}
-
-	return commands, nil
+	return content, nil
 }
 
 // buildMessages constructs the chat message list for the LLM request.
-// When followUp is non-nil, inserts previous query/command as conversation history.
-func buildMessages(count int, pipeContext string, userMessage string, followUp *FollowUpContext) []openai.ChatCompletionMessage {
-	hasFollowUp := followUp != nil
+// When r.FollowUp is non-nil, inserts previous query/command as conversation history.
+func buildMessages(r Request) []openai.ChatCompletionMessage {
+	blocks := requestBlocks(r)
+	rules := blockRules(blocks)
+
 	systemMsg := openai.ChatCompletionMessage{
 		Role:    openai.ChatMessageRoleSystem,
-		Content: SystemPrompt(count, pipeContext != "", hasFollowUp),
+		Content: SystemPrompt(r.Shell, r.Count, r.PipeContext != "", r.FollowUp != nil, rules...),
+	}
+	userMsg := openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: buildUserMessage(r.Query, blocks),
 	}
 
-	if !hasFollowUp {
-		return []openai.ChatCompletionMessage{
-			systemMsg,
-			{Role: openai.ChatMessageRoleUser, Content: userMessage},
-		}
+	if r.FollowUp == nil || r.FollowUp.PreviousQuery == "" {
+		return []openai.ChatCompletionMessage{systemMsg, userMsg}
 	}
 
 	return []openai.ChatCompletionMessage{
 		systemMsg,
-		{Role: openai.ChatMessageRoleUser, Content: followUp.PreviousQuery},
-		{Role: openai.ChatMessageRoleAssistant, Content: followUp.PreviousCommand},
-		{Role: openai.ChatMessageRoleUser, Content: userMessage},
+		{Role: openai.ChatMessageRoleUser, Content: r.FollowUp.PreviousQuery},
+		{Role: openai.ChatMessageRoleAssistant, Content: r.FollowUp.PreviousCommand},
+		userMsg,
+	}
+}
+
+// blockRules returns the distinct rules of blocks in order.
+func blockRules(blocks []ContextBlock) []string {
+	rules := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		if block.Rule != "" && !slices.Contains(rules, block.Rule) {
+			rules = append(rules, block.Rule)
+		}
+	}
+	return rules
+}
+
+// requestBlocks returns the context blocks of r followed by the failed
+// command, if any, and the pipe context. Structured pipe context large
+// enough to be worth it is preceded by a summary of its schema.
+func requestBlocks(r Request) []ContextBlock {
+	blocks := r.Context[:len(r.Context):len(r.Context)]
+	if r.FollowUp != nil && r.FollowUp.Failed() {
+		blocks = append(blocks, failedBlock(r.FollowUp))
+	}
+	if r.PipeContext == "" {
+		return blocks
+	}
+	if len(r.PipeContext) >= schemaMinSize {
+		if schema, ok := structured.Detect(r.PipeContext); ok {
+			blocks = append(blocks, ContextBlock{Name: "stdin-schema", Content: schema.String(), Rule: schemaRule})
+		}
+	}
+	return append(blocks, ContextBlock{Name: "stdin", Content: r.PipeContext})
+}
+
+// failedBlock describes the failed previous command of f.
+func failedBlock(f *FollowUpContext) ContextBlock {
+	var b strings.Builder
+	fmt.Fprintf(&b, "command: %s\nexit code: %d", f.PreviousCommand, f.ExitCode)
+	if f.ErrorOutput != "" {
+		fmt.Fprintf(&b, "\nerror output:\n%s", strings.TrimRight(f.ErrorOutput, "\n"))
+	}
+	return ContextBlock{Name: "failed-command", Content: b.String(), Rule: failedRule}
+}
+
+// buildUserMessage wraps the context blocks in tags ahead of the query.
+// Without any context the query is sent as is.
+func buildUserMessage(query string, blocks []ContextBlock) string {
+	if len(blocks) == 0 {
+		return query
+	}
+	return fmt.Sprintf("Context:\n%s\nTask: %s", FormatContext(blocks), query)
+}
+
+// FormatContext renders blocks exactly as they appear in the request,
+// each wrapped in a tag named after the block.
+func FormatContext(blocks []ContextBlock) string {
+	var b strings.Builder
+	for _, block := range blocks {
+		if block.Source != "" {
+			fmt.Fprintf(&b, "<%s source=%q>\n", block.Name, block.Source)
+		} else {
+			fmt.Fprintf(&b, "<%s>\n", block.Name)
+		}
+		fmt.Fprintf(&b, "%s\n</%s>\n", block.Content, block.Name)
 	}
+	return b.String()
 }
@@ -3,6 +3,7 @@ package llm
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -37,7 +38,7 @@ func TestGenerate_WithPipeContext(t *testing.T) {
 		model:  "test-model",
 	}
 
-	commands, err := provider.Generate(context.Background(), "stop nginx", 1, "CONTAINER ID\nabc123 nginx", nil)
+	commands, err := provider.Generate(context.Background(), Request{Query: "stop nginx", Count: 1, PipeContext: "CONTAINER ID\nabc123 nginx"})
 	if err != nil {
 		t.Fatalf("Generate() unexpected error: %v", err)
 	}
@@ -90,7 +91,7 @@ func TestGenerate_WithoutPipeContext(t *testing.T) {
 		model:  "test-model",
 	}
 
-	commands, err := provider.Generate(context.Background(), "list files", 1, "", nil)
+	commands, err := provider.Generate(context.Background(), Request{Query: "list files", Count: 1})
 	if err != nil {
 		t.Fatalf("Generate() unexpected error: %v", err)
 	}
@@ -117,7 +118,7 @@ func TestGenerate_WithoutPipeContext(t *testing.T) {
 func TestGenerate_EmptyQuery(t *testing.T) {
 	provider := &baseProvider{model: "test"}
 
-	_, err := provider.Generate(context.Background(), "", 1, "", nil)
+	_, err := provider.Generate(context.Background(), Request{Count: 1})
 	if err == nil {
 		t.Fatal("Generate() expected error for empty query")
 	}
@@ -157,7 +158,7 @@ func TestGenerate_WithFollowUpContext(t *testing.T) {
 		PreviousCommand: "find . -size +100M",
 	}
 
-	commands, err := provider.Generate(context.Background(), "only go files", 1, "", followUp)
+	commands, err := provider.Generate(context.Background(), Request{Query: "only go files", Count: 1, FollowUp: followUp})
 	if err != nil {
 		t.Fatalf("Generate() unexpected error: %v", err)
 	}
@@ -200,7 +201,7 @@ func TestGenerate_WithFollowUpContext(t *testing.T) {
 }
 
 func TestBuildMessages_WithoutFollowUp(t *testing.T) {
-	msgs := buildMessages(3, "", "list files", nil)
+	msgs := buildMessages(Request{Query: "list files", Count: 3})
 	if len(msgs) != 2 {
 		t.Fatalf("expected 2 messages, got %d", len(msgs))
 	}
@@ -217,7 +218,7 @@ func TestBuildMessages_WithFollowUp(t *testing.T) {
 		PreviousQuery:   "find files",
 		PreviousCommand: "find . -type f",
 	}
-	msgs := buildMessages(3, "", "make it recursive", followUp)
+	msgs := buildMessages(Request{Query: "make it recursive", Count: 3, FollowUp: followUp})
 	if len(msgs) != 4 {
 		t.Fatalf("expected 4 messages, got %d", len(msgs))
 	}
@@ -238,8 +239,7 @@ func TestBuildMessages_WithFollowUpAndPipeContext(t *testing.T) {
 		PreviousCommand: "docker stop abc123",
 	}
 	pipeContext := "CONTAINER ID\nabc123 nginx"
-	userMessage := "Context:\n<stdin>\n" + pipeContext + "\n</stdin>\n\nTask: also remove the volume"
-	msgs := buildMessages(3, pipeContext, userMessage, followUp)
+	msgs := buildMessages(Request{Query: "also remove the volume", Count: 3, PipeContext: pipeContext, FollowUp: followUp})
 
 	if len(msgs) != 4 {
 		t.Fatalf("expected 4 messages, got %d", len(msgs))
@@ -260,7 +260,205 @@ func TestBuildMessages_WithFollowUpAndPipeContext(t *testing.T) {
 	if msgs[2].Content != "docker stop abc123" {
 		t.Errorf("message[2] = %q, want previous command", msgs[2].Content)
 	}
-	if !strings.Contains(msgs[3].Content, "<stdin>") {
-		t.Error("message[3] should contain stdin wrapper for new query with pipe context")
+	want := "Context:\n<stdin>\n" + pipeContext + "\n</stdin>\n\nTask: also remove the volume"
+	if msgs[3].Content != want {
+		t.Errorf("message[3] = %q, want %q", msgs[3].Content, want)
+	}
+}
+
+func TestBuildMessages_FailedCommand(t *testing.T) {
+	followUp := &FollowUpContext{
+		PreviousQuery:   "push to main",
+		PreviousCommand: "git pusj origin main",
+		ExitCode:        1,
+		ErrorOutput:     "git: 'pusj' is not a git command.\n",
+	}
+	msgs := buildMessages(Request{Query: "fix the failed command", Count: 3, FollowUp: followUp})
+	if len(msgs) != 4 {
+		t.Fatalf("expected 4 messages, got %d", len(msgs))
+	}
+	want := "Context:\n<failed-command>\ncommand: git pusj origin main\nexit code: 1\nerror output:\n" +
+		"git: 'pusj' is not a git command.\n</failed-command>\n\nTask: fix the failed command"
+	if msgs[3].Content != want {
+		t.Errorf("message[3] = %q, want %q", msgs[3].Content, want)
+	}
+	if !strings.Contains(msgs[0].Content, failedRule) {
+		t.Error("system prompt should explain the failed command block")
+	}
+}
+
+func TestBuildMessages_FailedCommandWithoutQuery(t *testing.T) {
+	followUp := &FollowUpContext{PreviousCommand: "make biuld", ExitCode: 2}
+	msgs := buildMessages(Request{Query: "fix the failed command", Count: 3, FollowUp: followUp})
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 messages without a previous query, got %d", len(msgs))
+	}
+	if !strings.Contains(msgs[1].Content, "command: make biuld\nexit code: 2\n</failed-command>") {
+		t.Errorf("message[1] = %q, want failed command block", msgs[1].Content)
+	}
+}
+
+func TestBuildMessages_WithContextBlocks(t *testing.T) {
+	msgs := buildMessages(Request{
+		Query:       "list big files",
+		Count:       3,
+		PipeContext: "a.txt",
+		Context: []ContextBlock{
+			{Name: "environment", Content: "os: linux", Rule: "Use the environment"},
+			{Name: "notes", Content: "no rule"},
+		},
+	})
+
+	want := "Context:\n<environment>\nos: linux\n</environment>\n<notes>\nno rule\n</notes>\n<stdin>\na.txt\n</stdin>\n\nTask: list big files"
+	if msgs[1].Content != want {
+		t.Errorf("user message = %q, want %q", msgs[1].Content, want)
+	}
+	if !strings.Contains(msgs[0].Content, "\n- Use the environment\n") {
+		t.Error("system prompt should contain the context block rule")
+	}
+}
+
+func TestBuildMessages_Shell(t *testing.T) {
+	msgs := buildMessages(Request{Query: "count lines", Count: 3, Shell: ShellFish})
+
+	if !strings.Contains(msgs[0].Content, "fish shell") {
+		t.Error("system prompt should target the requested shell")
+	}
+}
+
+func TestFormatContext_Source(t *testing.T) {
+	got := FormatContext([]ContextBlock{{Name: "file", Source: "Makefile", Content: "build:\n\tgo build"}})
+
+	want := "<file source=\"Makefile\">\nbuild:\n\tgo build\n</file>\n"
+	if got != want {
+		t.Errorf("FormatContext() = %q, want %q", got, want)
+	}
+}
+
+func TestBuildMessages_DeduplicatesRules(t *testing.T) {
+	msgs := buildMessages(Request{
+		Query: "build it",
+		Count: 3,
+		Context: []ContextBlock{
+			{Name: "file", Source: "a", Content: "a", Rule: "Use the project files"},
+			{Name: "file", Source: "b", Content: "b", Rule: "Use the project files"},
+		},
+	})
+
+	if n := strings.Count(msgs[0].Content, "Use the project files"); n != 1 {
+		t.Errorf("rule appears %d times in system prompt, want 1", n)
+	}
+}
+
+func TestBuildMessages_StructuredStdinSchema(t *testing.T) {
+	var rows []string
+	for i := range 40 {
+		rows = append(rows, fmt.Sprintf(`{"metadata": {"name": "pod-%d"}}`, i))
+	}
+	pipe := `{"items": [` + strings.Join(rows, ", ") + `]}`
+
+	msgs := buildMessages(Request{Query: "names of pods", Count: 3, PipeContext: pipe})
+
+	if !strings.Contains(msgs[1].Content, "<stdin-schema>\n[structure of JSON input") {
+		t.Errorf("user message should contain the stdin schema, got %q", msgs[1].Content)
+	}
+	if !strings.Contains(msgs[1].Content, `.items[].metadata.name: string (e.g. "pod-0")`) {
+		t.Error("schema should list jq paths")
+	}
+	if !strings.Contains(msgs[1].Content, "<stdin>\n"+pipe) {
+		t.Error("raw stdin should still be sent along with the schema")
+	}
+	if !strings.Contains(msgs[0].Content, "<stdin-schema>") {
+		t.Error("system prompt should explain the stdin schema block")
+	}
+}
+
+func TestBuildMessages_SmallStructuredStdinWithoutSchema(t *testing.T) {
+	msgs := buildMessages(Request{Query: "get name", Count: 3, PipeContext: `{"name": "web"}`})
+
+	if strings.Contains(msgs[1].Content, "stdin-schema") {
+		t.Error("small stdin should be sent without a schema")
+	}
+}
+
+// newScriptedProvider returns a provider backed by a server that replies
+// with contents in turn and records the requests it receives.
+func newScriptedProvider(t *testing.T, contents ...string) (*baseProvider, *[]openai.ChatCompletionRequest) {
+	t.Helper()
+	var requests []openai.ChatCompletionRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		requests = append(requests, req)
+		if len(requests) > len(contents) {
+			http.Error(w, "unexpected request", http.StatusInternalServerError)
+			return
+		}
+		resp := openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{
+				{Message: openai.ChatCompletionMessage{Content: contents[len(requests)-1]}},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := openai.DefaultConfig("test-key")
+	cfg.BaseURL = server.URL + "/v1"
+	return &baseProvider{client: openai.NewClientWithConfig(cfg), model: "test-model"}, &requests
+}
+
+func TestGenerate_TopsUpDuplicates(t *testing.T) {
+	provider, requests := newScriptedProvider(t,
+		`{"commands": ["ls -la", "ls -al", "ls -l -a"]}`,
+		`{"commands": ["ls -a -l", "find . -maxdepth 1", "stat *", "tree -L 1"]}`,
+	)
+
+	got, err := provider.Generate(context.Background(), Request{Query: "list files", Count: 3, Shell: ShellBash})
+	if err != nil {
+		t.Fatalf("Generate() unexpected error: %v", err)
+	}
+	want := []string{"ls -la", "find . -maxdepth 1", "stat *"}
+	if !equalSlices(Commands(got), want) {
+		t.Errorf("Generate() = %v, want %v", got, want)
+	}
+
+	if len(*requests) != 2 {
+		t.Fatalf("requests = %d, want a single top-up request", len(*requests))
+	}
+	messages := (*requests)[1].Messages
+	if last := messages[len(messages)-1].Content; !strings.Contains(last, "exactly 2 more") || !strings.Contains(last, "- ls -la") {
+		t.Errorf("top-up message = %q, want a request for 2 more variants listing the kept one", last)
+	}
+	if reply := messages[len(messages)-2]; reply.Role != openai.ChatMessageRoleAssistant || !strings.Contains(reply.Content, "ls -al") {
+		t.Errorf("top-up should continue after the first reply, got %+v", reply)
+	}
+}
+
+func TestGenerate_TopUpFailureKeepsVariants(t *testing.T) {
+	provider, requests := newScriptedProvider(t, `{"commands": ["ls -la", "ls -al"]}`)
+
+	got, err := provider.Generate(context.Background(), Request{Query: "list files", Count: 2, Shell: ShellBash})
+	if err != nil {
+		t.Fatalf("Generate() unexpected error: %v", err)
+	}
+	if !equalSlices(Commands(got), []string{"ls -la"}) || len(*requests) != 2 {
+		t.Errorf("Generate() = %v after %d requests, want the deduplicated variant after a failed top-up", got, len(*requests))
+	}
+}
+
+func TestGenerate_NoTopUpWhenEnough(t *testing.T) {
+	provider, requests := newScriptedProvider(t, `{"commands": ["ls -la", "ls -al", "tree"]}`)
+
+	got, err := provider.Generate(context.Background(), Request{Query: "list files", Count: 2, Shell: ShellBash})
+	if err != nil {
+		t.Fatalf("Generate() unexpected error: %v", err)
+	}
+	if !equalSlices(Commands(got), []string{"ls -la", "tree"}) || len(*requests) != 1 {
+		t.Errorf("Generate() = %v after %d requests, want 2 variants from one request", got, len(*requests))
 	}
 }
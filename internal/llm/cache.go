@@ -0,0 +1,176 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// PromptVersion identifies the prompts and the response format. Bump it
+// whenever either changes, so responses cached for the old prompt are not
+// served any more.
+const PromptVersion = 1
+
+// cacheExt is the extension of cache entry files.
+const cacheExt = ".json"
+
+// Cache stores generated variants on disk, one file per request. Entries
+// expire after a TTL and the oldest ones are removed beyond a maximum
+// count.
+type Cache struct {
+	dir        string
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+}
+
+// cacheEntry is the file content of a cached response.
+type cacheEntry struct {
+	Created  time.Time `json:"created"`
+	Variants []Variant `json:"variants"`
+}
+
+// NewCache creates a Cache that keeps at most maxEntries responses in dir
+// for ttl each.
+func NewCache(dir string, ttl time.Duration, maxEntries int) *Cache {
+	return &Cache{dir: dir, ttl: ttl, maxEntries: maxEntries, now: time.Now}
+}
+
+// cacheKey identifies the response to r from the model of cfg. It covers
+// the prompt version, the model and everything sent with the request:
+// query, shell, count, the pipe context, follow-up and context blocks.
+func cacheKey(cfg Config, r Request) string {
+	data, _ := json.Marshal(struct {
+		Version int
+		BaseURL string
+		Model   string
+		Request Request
+	}{PromptVersion, cfg.BaseURL, cfg.Model, r})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key+cacheExt)
+}
+
+// Get returns the variants cached for key, marked as cached. Expired and
+// unreadable entries are removed and reported as missing.
+func (c *Cache) Get(key string) ([]Variant, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || len(entry.Variants) == 0 || c.now().Sub(entry.Created) > c.ttl {
+		_ = os.Remove(c.path(key))
+		return nil, false
+	}
+	for i := range entry.Variants {
+		entry.Variants[i].Cached = true
+	}
+	return entry.Variants, true
+}
+
+// Put stores variants for key and removes the oldest entries beyond the
+// maximum count.
+func (c *Cache) Put(key string, variants []Variant) error {
+	data, err := json.Marshal(cacheEntry{Created: c.now(), Variants: variants})
+	if err != nil {
+		return fmt.Errorf("marshalling cache entry: %w", err)
+	}
+	if err := os.MkdirAll(c.dir, 0o700); err != nil {
+		return fmt.Errorf("creating cache directory: %w", err)
+	}
+
+	path := c.path(key)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("writing cache temp file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("renaming cache temp file: %w", err)
+	}
+	return c.prune()
+}
+
+// prune removes the oldest entries until at most maxEntries are left.
+func (c *Cache) prune() error {
+	entries, err := c.entries()
+	if err != nil || len(entries) <= c.maxEntries {
+		return err
+	}
+	slices.SortFunc(entries, func(a, b os.FileInfo) int {
+		return a.ModTime().Compare(b.ModTime())
+	})
+	for _, e := range entries[:len(entries)-c.maxEntries] {
+		_ = os.Remove(filepath.Join(c.dir, e.Name()))
+	}
+	return nil
+}
+
+// entries lists the entry files in the cache directory.
+func (c *Cache) entries() ([]os.FileInfo, error) {
+	dirEntries, err := os.ReadDir(c.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading cache directory: %w", err)
+	}
+	var infos []os.FileInfo
+	for _, e := range dirEntries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), cacheExt) {
+			continue
+		}
+		if info, err := e.Info(); err == nil {
+			infos = append(infos, info)
+		}
+	}
+	return infos, nil
+}
+
+// Clear removes every cached response and returns how many there were.
+func (c *Cache) Clear() (int, error) {
+	entries, err := c.entries()
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range entries {
+		if err := os.Remove(filepath.Join(c.dir, e.Name())); err != nil && !os.IsNotExist(err) {
+			return 0, fmt.Errorf("removing cache entry: %w", err)
+		}
+	}
+	return len(entries), nil
+}
+
+// cachingProvider serves repeated generations from a Cache. Explanations
+// and scripts are not cached.
+type cachingProvider struct {
+	Provider
+	cfg   Config
+	cache *Cache
+}
+
+// Generate returns the cached variants for r, or generates and caches
+// them. A cache that cannot be written only costs the next request.
+func (p *cachingProvider) Generate(ctx context.Context, r Request) ([]Variant, error) {
+	key := cacheKey(p.cfg, r)
+	if variants, ok := p.cache.Get(key); ok {
+		return variants, nil
+	}
+	variants, err := p.Provider.Generate(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	_ = p.cache.Put(key, variants)
+	return variants, nil
+}
@@ -0,0 +1,160 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// countingProvider returns fixed variants and counts generations.
+type countingProvider struct {
+	Provider
+	variants []Variant
+	calls    int
+}
+
+func (p *countingProvider) Generate(context.Context, Request) ([]Variant, error) {
+	p.calls++
+	if p.variants == nil {
+		return nil, errors.New("generation failed")
+	}
+	return p.variants, nil
+}
+
+func TestCache_PutGet(t *testing.T) {
+	c := NewCache(t.TempDir(), time.Hour, 10)
+	variants := []Variant{{Command: "du -sh * | sort -h", Confidence: 0.9}}
+
+	if _, ok := c.Get("key"); ok {
+		t.Fatal("Get() on an empty cache should miss")
+	}
+	if err := c.Put("key", variants); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	got, ok := c.Get("key")
+	want := []Variant{{Command: "du -sh * | sort -h", Confidence: 0.9, Cached: true}}
+	if !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("Get() = %+v, %v, want %+v", got, ok, want)
+	}
+}
+
+func TestCache_Expires(t *testing.T) {
+	dir := t.TempDir()
+	c := NewCache(dir, time.Hour, 10)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	_ = c.Put("key", []Variant{{Command: "ls"}})
+
+	c.now = func() time.Time { return now.Add(2 * time.Hour) }
+	if _, ok := c.Get("key"); ok {
+		t.Error("Get() should miss an expired entry")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "key.json")); !os.IsNotExist(err) {
+		t.Error("an expired entry should be removed")
+	}
+}
+
+func TestCache_PrunesOldest(t *testing.T) {
+	dir := t.TempDir()
+	c := NewCache(dir, time.Hour, 2)
+	for i, key := range []string{"a", "b", "c"} {
+		if err := c.Put(key, []Variant{{Command: "ls"}}); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+		// make the modification order unambiguous
+		mtime := time.Now().Add(time.Duration(i-3) * time.Minute)
+		_ = os.Chtimes(filepath.Join(dir, key+".json"), mtime, mtime)
+	}
+	_ = c.Put("d", []Variant{{Command: "ls"}})
+
+	for key, want := range map[string]bool{"a": false, "b": false, "c": true, "d": true} {
+		if _, ok := c.Get(key); ok != want {
+			t.Errorf("Get(%q) ok = %v, want %v", key, ok, want)
+		}
+	}
+}
+
+func TestCache_Clear(t *testing.T) {
+	dir := t.TempDir()
+	c := NewCache(dir, time.Hour, 10)
+	_ = c.Put("a", []Variant{{Command: "ls"}})
+	_ = c.Put("b", []Variant{{Command: "pwd"}})
+
+	n, err := c.Clear()
+	if err != nil || n != 2 {
+		t.Fatalf("Clear() = %d, %v, want 2", n, err)
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Error("Get() should miss after Clear()")
+	}
+	if n, err := NewCache(filepath.Join(dir, "missing"), time.Hour, 10).Clear(); err != nil || n != 0 {
+		t.Errorf("Clear() of a missing directory = %d, %v, want 0", n, err)
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	cfg := Config{BaseURL: "https://api.openai.com/v1", Model: "gpt-4o-mini"}
+	base := Request{Query: "show disk usage sorted by size", Count: 3, Shell: ShellBash}
+	key := cacheKey(cfg, base)
+
+	if cacheKey(cfg, base) != key {
+		t.Error("cacheKey() should be stable")
+	}
+	other := cfg
+	other.APIKey = "another-key"
+	if cacheKey(other, base) != key {
+		t.Error("cacheKey() should not depend on the API key")
+	}
+
+	changes := map[string]func(*Config, *Request){
+		"model":        func(c *Config, _ *Request) { c.Model = "gpt-4o" },
+		"query":        func(_ *Config, r *Request) { r.Query = "show disk usage" },
+		"pipe context": func(_ *Config, r *Request) { r.PipeContext = "a\nb" },
+		"follow-up":    func(_ *Config, r *Request) { r.FollowUp = &FollowUpContext{PreviousCommand: "du"} },
+		"shell":        func(_ *Config, r *Request) { r.Shell = ShellFish },
+	}
+	for name, change := range changes {
+		c, r := cfg, base
+		change(&c, &r)
+		if cacheKey(c, r) == key {
+			t.Errorf("cacheKey() should change with the %s", name)
+		}
+	}
+}
+
+func TestCachingProvider(t *testing.T) {
+	inner := &countingProvider{variants: []Variant{{Command: "ls -la", Confidence: 0.8}}}
+	p := &cachingProvider{Provider: inner, cache: NewCache(t.TempDir(), time.Hour, 10)}
+	req := Request{Query: "list files", Count: 1}
+
+	first, err := p.Generate(context.Background(), req)
+	if err != nil || first[0].Cached {
+		t.Fatalf("first Generate() = %+v, %v, want a fresh result", first, err)
+	}
+	second, err := p.Generate(context.Background(), req)
+	if err != nil || !second[0].Cached || second[0].Command != "ls -la" {
+		t.Fatalf("second Generate() = %+v, %v, want the cached result", second, err)
+	}
+	if inner.calls != 1 {
+		t.Errorf("calls = %d, want the second generation served from cache", inner.calls)
+	}
+
+	inner.variants = nil
+	if _, err := p.Generate(context.Background(), Request{Query: "other", Count: 1}); err == nil {
+		t.Error("Generate() should return the error of a failed generation")
+	}
+}
+
+func TestNewProvider_WithCache(t *testing.T) {
+	p, err := NewProvider(Config{APIKey: "test-key", Model: "test-model", CacheDir: t.TempDir(), CacheTTL: time.Hour, CacheMaxEntries: 10})
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	if _, ok := p.(*cachingProvider); !ok {
+		t.Errorf("NewProvider() = %T, want a caching provider", p)
+	}
+}
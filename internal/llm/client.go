@@ -0,0 +1,51 @@
+package llm
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// idleConnTimeout keeps an idle connection open long enough to be reused
+// by a follow-up request while the user reads the variants or types a
+// refinement.
+const idleConnTimeout = 5 * time.Minute
+
+// httpClient is shared by all providers so that requests to the same API
+// reuse warm keep-alive connections instead of setting up TLS again.
+var httpClient = newHTTPClient()
+
+func newHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = 4
+	transport.IdleConnTimeout = idleConnTimeout
+	return &http.Client{Transport: transport}
+}
+
+// Prewarm opens a connection to the API of cfg in the shared client, so
+// the first request skips DNS, TCP and TLS setup. Any HTTP response
+// counts as success; only the connection matters.
+func Prewarm(ctx context.Context, cfg Config) error {
+	return prewarm(ctx, httpClient, cfg)
+}
+
+func prewarm(ctx context.Context, client *http.Client, cfg Config) error {
+	url := cfg.BaseURL
+	if url == "" {
+		url = openai.DefaultConfig("").BaseURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	// Reading the body to the end returns the connection to the pool.
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
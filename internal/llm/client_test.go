@@ -0,0 +1,135 @@
+package llm
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// newStandInServer starts a server that answers HEAD requests with 200 and
+// chat completions with a single command, counting new connections.
+func newStandInServer(tb testing.TB, tlsServer bool) (*httptest.Server, *atomic.Int32) {
+	tb.Helper()
+	var conns atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return
+		}
+		resp := openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{
+				{Message: openai.ChatCompletionMessage{Content: `{"commands": ["ls -la"]}`}},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	if tlsServer {
+		server.StartTLS()
+	} else {
+		server.Start()
+	}
+	tb.Cleanup(server.Close)
+	return server, &conns
+}
+
+// standInClient returns a client tuned like the shared one that trusts
+// the certificate of server.
+func standInClient(server *httptest.Server) *http.Client {
+	client := newHTTPClient()
+	if server.TLS != nil {
+		client.Transport.(*http.Transport).TLSClientConfig = &tls.Config{
+			RootCAs: server.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs,
+		}
+	}
+	return client
+}
+
+func TestNewProvider_ReusesProviderPerConfig(t *testing.T) {
+	cfg := Config{BaseURL: "https://api.example.com/v1", APIKey: "test-key", Model: "test-model", Count: 3}
+
+	first, err := NewProvider(cfg)
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	second, _ := NewProvider(cfg)
+	if first != second {
+		t.Error("NewProvider() should return the same provider for the same config")
+	}
+
+	cfg.Model = "other-model"
+	if other, _ := NewProvider(cfg); other == first {
+		t.Error("NewProvider() should return a new provider for another config")
+	}
+}
+
+func TestPrewarm_ConnectionReused(t *testing.T) {
+	server, conns := newStandInServer(t, false)
+	client := standInClient(server)
+	cfg := Config{BaseURL: server.URL + "/v1", APIKey: "test-key", Model: "test-model", Count: 1}
+
+	if err := prewarm(context.Background(), client, cfg); err != nil {
+		t.Fatalf("prewarm() error = %v", err)
+	}
+	provider, _ := newOpenAIProvider(cfg, client)
+	if _, err := provider.Generate(context.Background(), Request{Query: "list files", Count: 1}); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	if got := conns.Load(); got != 1 {
+		t.Errorf("connections = %d, want the prewarmed one reused", got)
+	}
+}
+
+func TestPrewarm_Unreachable(t *testing.T) {
+	server, _ := newStandInServer(t, false)
+	url := server.URL
+	server.Close()
+
+	if err := prewarm(context.Background(), newHTTPClient(), Config{BaseURL: url}); err == nil {
+		t.Error("prewarm() should fail when the server is unreachable")
+	}
+}
+
+// BenchmarkGenerate_WarmConnection sends every request over the same
+// keep-alive TLS connection, as the shared client does.
+func BenchmarkGenerate_WarmConnection(b *testing.B) {
+	server, _ := newStandInServer(b, true)
+	cfg := Config{BaseURL: server.URL + "/v1", APIKey: "test-key", Model: "test-model", Count: 1}
+	provider, _ := newOpenAIProvider(cfg, standInClient(server))
+	req := Request{Query: "list files", Count: 1}
+
+	for b.Loop() {
+		if _, err := provider.Generate(context.Background(), req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkGenerate_ColdConnection creates a provider with a new client
+// for every request, paying for the TCP and TLS setup each time.
+func BenchmarkGenerate_ColdConnection(b *testing.B) {
+	server, _ := newStandInServer(b, true)
+	cfg := Config{BaseURL: server.URL + "/v1", APIKey: "test-key", Model: "test-model", Count: 1}
+	req := Request{Query: "list files", Count: 1}
+
+	for b.Loop() {
+		client := standInClient(server)
+		provider, _ := newOpenAIProvider(cfg, client)
+		if _, err := provider.Generate(context.Background(), req); err != nil {
+			b.Fatal(err)
+		}
+		client.CloseIdleConnections()
+	}
+}
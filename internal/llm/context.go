@@ -0,0 +1,10 @@
+package llm
+
+// ContextBlock is a named piece of context sent along with the query,
+// such as a description of the user's environment.
+type ContextBlock struct {
+	Name    string // tag the content is wrapped in, e.g. "environment"
+	Source  string // optional origin of the content, e.g. a file path
+	Content string
+	Rule    string // optional instruction added to the system prompt
+}
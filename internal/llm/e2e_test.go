@@ -69,7 +69,7 @@ func TestE2E_SingleToolPreference_NoPipe(t *testing.T) {
 			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 			defer cancel()
 
-			commands, err := provider.Generate(ctx, tt.query, 3, "", nil)
+			commands, err := provider.Generate(ctx, Request{Query: tt.query, Count: 3})
 			if err != nil {
 				t.Fatalf("Generate failed: %v", err)
 			}
@@ -132,7 +132,7 @@ func TestE2E_SingleToolPreference_WithPipe(t *testing.T) {
 			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 			defer cancel()
 
-			commands, err := provider.Generate(ctx, tt.query, 3, tt.pipeContext, nil)
+			commands, err := provider.Generate(ctx, Request{Query: tt.query, Count: 3, PipeContext: tt.pipeContext})
 			if err != nil {
 				t.Fatalf("Generate failed: %v", err)
 			}
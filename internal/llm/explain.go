@@ -0,0 +1,117 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ExplainRequest describes a command to explain.
+type ExplainRequest struct {
+	Command string
+	Shell   Shell // dialect the command is written in
+	Context []ContextBlock
+}
+
+// Explanation is a structured breakdown of a command.
+type Explanation struct {
+	Summary  string   `json:"summary"`
+	Stages   []Stage  `json:"stages"`
+	Warnings []string `json:"warnings"`
+}
+
+// Stage is one command of a pipeline or command list.
+type Stage struct {
+	Command     string `json:"command"`
+	Description string `json:"description"`
+	Flags       []Flag `json:"flags"`
+}
+
+// Flag explains a single flag or argument of a stage.
+type Flag struct {
+	Flag        string `json:"flag"`
+	Description string `json:"description"`
+}
+
+// Explain breaks r.Command down into stages and flags.
+func (p *baseProvider) Explain(ctx context.Context, r ExplainRequest) (*Explanation, error) {
+	if strings.TrimSpace(r.Command) == "" {
+		return nil, fmt.Errorf("command cannot be empty")
+	}
+
+	content, err := p.complete(ctx, buildExplainMessages(r), ExplainTemperature)
+	if err != nil {
+		return nil, err
+	}
+
+	explanation, err := ParseExplanation([]byte(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse LLM output: %w", err)
+	}
+	return explanation, nil
+}
+
+// buildExplainMessages constructs the chat message list for an explanation.
+func buildExplainMessages(r ExplainRequest) []openai.ChatCompletionMessage {
+	userMsg := r.Command
+	if len(r.Context) > 0 {
+		userMsg = fmt.Sprintf("Context:\n%s\nCommand: %s", FormatContext(r.Context), r.Command)
+	}
+	return []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: ExplainPrompt(r.Shell, blockRules(r.Context)...)},
+		{Role: openai.ChatMessageRoleUser, Content: userMsg},
+	}
+}
+
+// ExplainPrompt generates the system prompt for explaining a command
+// written for shell. extraRules are appended to the rule list, one per line.
+func ExplainPrompt(shell Shell, extraRules ...string) string {
+	dialect := "a POSIX shell"
+	if shell != ShellPOSIX {
+		dialect = string(shell)
+	}
+
+	contextRules := ""
+	for _, rule := range extraRules {
+		contextRules += "\n- " + rule
+	}
+
+	return fmt.Sprintf(`You explain shell commands. The user sends a command written for %s.
+
+Rules:
+- Split the command into stages: every command of a pipeline (|), command list (&&, ||, ;) or command substitution is a separate stage, in execution order
+- For each stage, list every flag and argument that changes its behavior with a short description; omit plain operands such as file names only when their meaning is obvious
+- Describe what the command actually does, including side effects such as deleting, overwriting or sending data over the network
+- Put anything surprising or dangerous in warnings, e.g. globs that may match more than intended, missing quotes or irreversible changes
+- Keep every description to one sentence
+- Never suggest alternative commands%s
+
+Response format (JSON):
+{
+  "summary": "what the whole command does",
+  "stages": [
+    {"command": "stage as written", "description": "what it does", "flags": [{"flag": "-r", "description": "what it changes"}]}
+  ],
+  "warnings": ["..."]
+}`, dialect, contextRules)
+}
+
+// ParseExplanation parses JSON response from LLM into an Explanation.
+func ParseExplanation(jsonResponse []byte) (*Explanation, error) {
+	if len(jsonResponse) == 0 {
+		return nil, fmt.Errorf("empty response from LLM")
+	}
+
+	var e Explanation
+	if err := json.Unmarshal(jsonResponse, &e); err != nil {
+		return nil, fmt.Errorf("failed to parse LLM response: %w", err)
+	}
+
+	if e.Summary == "" && len(e.Stages) == 0 {
+		return nil, fmt.Errorf("LLM returned an empty explanation")
+	}
+	return &e, nil
+}
@@ -0,0 +1,105 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestExplain(t *testing.T) {
+	var capturedRequest openai.ChatCompletionRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&capturedRequest); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		resp := openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{
+				{Message: openai.ChatCompletionMessage{Content: `{
+					"summary": "Counts Go files",
+					"stages": [
+						{"command": "find . -name '*.go'", "description": "Lists Go files", "flags": [{"flag": "-name '*.go'", "description": "Matches by name"}]},
+						{"command": "wc -l", "description": "Counts lines", "flags": [{"flag": "-l", "description": "Counts lines only"}]}
+					],
+					"warnings": []
+				}`}},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	cfg := openai.DefaultConfig("test-key")
+	cfg.BaseURL = server.URL + "/v1"
+	provider := &baseProvider{
+		client: openai.NewClientWithConfig(cfg),
+		model:  "test-model",
+	}
+
+	e, err := provider.Explain(context.Background(), ExplainRequest{
+		Command: "find . -name '*.go' | wc -l",
+		Shell:   ShellZsh,
+		Context: []ContextBlock{{Name: "environment", Content: "os: darwin", Rule: "Use BSD flags"}},
+	})
+	if err != nil {
+		t.Fatalf("Explain() unexpected error: %v", err)
+	}
+	if e.Summary != "Counts Go files" || len(e.Stages) != 2 || e.Stages[1].Flags[0].Flag != "-l" {
+		t.Errorf("Explain() = %+v", e)
+	}
+
+	if capturedRequest.Temperature != ExplainTemperature {
+		t.Errorf("Temperature = %v, want %v", capturedRequest.Temperature, ExplainTemperature)
+	}
+	sysMsg := capturedRequest.Messages[0].Content
+	if !strings.Contains(sysMsg, "written for zsh") || !strings.Contains(sysMsg, "- Use BSD flags") {
+		t.Errorf("system prompt should name the shell and include context rules:\n%s", sysMsg)
+	}
+	userMsg := capturedRequest.Messages[1].Content
+	if !strings.Contains(userMsg, "<environment>") || !strings.Contains(userMsg, "Command: find . -name '*.go' | wc -l") {
+		t.Errorf("user message = %q, want context followed by the command", userMsg)
+	}
+}
+
+func TestExplain_EmptyCommand(t *testing.T) {
+	p := &baseProvider{}
+	if _, err := p.Explain(context.Background(), ExplainRequest{Command: "  "}); err == nil {
+		t.Error("Explain() expected error for empty command")
+	}
+}
+
+func TestExplainPrompt_POSIX(t *testing.T) {
+	if got := ExplainPrompt(ShellPOSIX); !strings.Contains(got, "written for a POSIX shell") {
+		t.Errorf("ExplainPrompt() should describe the POSIX dialect:\n%s", got)
+	}
+}
+
+func TestParseExplanation(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{"valid", `{"summary": "Lists files", "stages": [{"command": "ls -la", "description": "Lists files"}]}`, false},
+		{"summary only", `{"summary": "Prints the date"}`, false},
+		{"empty", ``, true},
+		{"invalid json", `{"summary":`, true},
+		{"empty explanation", `{"summary": "", "stages": []}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseExplanation([]byte(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseExplanation() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
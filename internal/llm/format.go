@@ -1,100 +1,133 @@
 package llm
 
 import (
+	"bytes"
 	"regexp"
+	"sort"
 	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
 )
 
-func trimTrailingSpace(b *strings.Builder) {
-	s := b.String()
-	trimmed := strings.TrimRight(s, " ")
-	b.Reset()
-	b.WriteString(trimmed)
+// parseCommand parses cmd with the bash grammar, the closest to the
+// supported dialects that the parser implements.
+func parseCommand(cmd string) (*syntax.File, error) {
+	return syntax.NewParser(syntax.Variant(syntax.LangBash), syntax.KeepComments(true)).
+		Parse(strings.NewReader(cmd), "")
 }
 
-// FormatCommand formats shell command with line breaks at pipe operators.
-// Splits at |, &&, || outside of quotes.
-func FormatCommand(cmd string) string {
-	var result strings.Builder
-	inSingle := false
-	inDouble := false
-	escaped := false
-	i := 0
+// CheckSyntax reports a syntax error in cmd. Only bash and POSIX commands
+// are checked: the parser does not implement zsh or fish syntax, so their
+// commands are never rejected.
+func CheckSyntax(cmd string, shell Shell) error {
+	if shell == ShellZsh || shell == ShellFish {
+		return nil
+	}
+	_, err := parseCommand(cmd)
+	return err
+}
 
-	for i < len(cmd) {
-		c := cmd[i]
+// breakPoint is an operator of a top-level pipeline or && / || list.
+type breakPoint struct {
+	offset int
+	op     string
+}
 
-		if escaped {
-			result.WriteByte(c)
-			escaped = false
-			i++
-			continue
-		}
+// collectBreakPoints appends the operators that join s from its parts,
+// without descending into subshells, blocks or command substitutions.
+func collectBreakPoints(s *syntax.Stmt, points *[]breakPoint) {
+	bc, ok := s.Cmd.(*syntax.BinaryCmd)
+	if !ok {
+		return
+	}
+	collectBreakPoints(bc.X, points)
+	*points = append(*points, breakPoint{offset: int(bc.OpPos.Offset()), op: bc.Op.String()})
+	collectBreakPoints(bc.Y, points)
+}
 
-		if c == '\\' {
-			escaped = true
-			result.WriteByte(c)
-			i++
-			continue
-		}
+// writeBreak writes operator op with a line break in the style of shell:
+// a trailing backslash before the operator for POSIX shells, and a break
+// after the operator for fish, which continues lines ending in |, && or ||.
+func writeBreak(b *strings.Builder, op string, shell Shell) {
+	if shell == ShellFish {
+		b.WriteString(" " + op + "\n\t")
+		return
+	}
+	b.WriteString(" \\\n\t" + op + " ")
+}
 
-		if c == '\'' && !inDouble {
-			inSingle = !inSingle
-			result.WriteByte(c)
-			i++
-			continue
-		}
+// FormatCommand formats a single-line shell command with a line break at
+// every |, && and || that joins its top-level commands, using the
+// continuation style of shell. Operators are found in the syntax tree, so
+// ones inside quotes, command substitutions, subshells or comments are
+// left alone. Commands that do not parse, already span several lines, or
+// would mean something else once broken are returned unchanged.
+func FormatCommand(cmd string, shell Shell) string {
+	cmd = strings.TrimSpace(cmd)
+	if strings.Contains(cmd, "\n") {
+		return cmd
+	}
+	file, err := parseCommand(cmd)
+	if err != nil {
+		return cmd
+	}
 
-		if c == '"' && !inSingle {
-			inDouble = !inDouble
-			result.WriteByte(c)
-			i++
-			continue
-		}
+	var points []breakPoint
+	for _, stmt := range file.Stmts {
+		collectBreakPoints(stmt, &points)
+	}
+	if len(points) == 0 {
+		return cmd
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].offset < points[j].offset })
 
-		if !inSingle && !inDouble {
-			if c == '|' && i+1 < len(cmd) && cmd[i+1] == '|' {
-				trimTrailingSpace(&result)
-				result.WriteString(" \\\n\t|| ")
-				i += 2
-				for i < len(cmd) && cmd[i] == ' ' {
-					i++
-				}
-				continue
-			}
-			if c == '&' && i+1 < len(cmd) && cmd[i+1] == '&' {
-				trimTrailingSpace(&result)
-				result.WriteString(" \\\n\t&& ")
-				i += 2
-				for i < len(cmd) && cmd[i] == ' ' {
-					i++
-				}
-				continue
-			}
-			if c == '|' && (i+1 >= len(cmd) || cmd[i+1] != '|') {
-				trimTrailingSpace(&result)
-				result.WriteString(" \\\n\t| ")
-				i++
-				for i < len(cmd) && cmd[i] == ' ' {
-					i++
-				}
-				continue
-			}
+	var b strings.Builder
+	last := 0
+	for _, p := range points {
+		b.WriteString(strings.TrimRight(cmd[last:p.offset], " \t"))
+		writeBreak(&b, p.op, shell)
+		last = p.offset + len(p.op)
+		for last < len(cmd) && (cmd[last] == ' ' || cmd[last] == '\t') {
+			last++
 		}
+	}
+	b.WriteString(cmd[last:])
+	formatted := b.String()
 
-		result.WriteByte(c)
-		i++
+	if !sameProgram(file, formatted) {
+		return cmd
 	}
+	return formatted
+}
 
-	return strings.TrimSpace(result.String())
+// sameProgram reports whether formatted parses to the same program as
+// orig, comparing both printed on a single line.
+func sameProgram(orig *syntax.File, formatted string) bool {
+	file, err := parseCommand(formatted)
+	if err != nil {
+		return false
+	}
+	printer := syntax.NewPrinter(syntax.SingleLine(true))
+	var want, got bytes.Buffer
+	if printer.Print(&want, orig) != nil || printer.Print(&got, file) != nil {
+		return false
+	}
+	return want.String() == got.String()
 }
 
-var continuationRe = regexp.MustCompile(`[ \t]*\\\n[\t ]*`)
+var (
+	continuationRe     = regexp.MustCompile(`[ \t]*\\\n[\t ]*`)
+	fishContinuationRe = regexp.MustCompile(`[ \t]*(\|\||&&|\|)[ \t]*\n[\t ]*`)
+)
 
 // UnformatCommand reverses FormatCommand by joining line continuations
 // back into a single-line command. Sequences of \<newline><whitespace>
-// are collapsed into a single space.
-func UnformatCommand(cmd string) string {
+// are collapsed into a single space; for fish, so are line breaks after
+// |, && and ||.
+func UnformatCommand(cmd string, shell Shell) string {
+	if shell == ShellFish && strings.Contains(cmd, "\n") {
+		cmd = fishContinuationRe.ReplaceAllString(cmd, " $1 ")
+	}
 	if !strings.Contains(cmd, "\\\n") {
 		return cmd
 	}
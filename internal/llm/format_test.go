@@ -46,11 +46,41 @@ func TestFormatCommand(t *testing.T) {
 			input:    "echo 'unclosed",
 			expected: "echo 'unclosed",
 		},
+		{
+			name:     "pipe in command substitution preserved",
+			input:    "echo $(ls | wc -l) && echo done",
+			expected: "echo $(ls | wc -l) \\\n\t&& echo done",
+		},
+		{
+			name:     "pipe in comment preserved",
+			input:    "ls | grep x # a | b",
+			expected: "ls \\\n\t| grep x # a | b",
+		},
+		{
+			name:     "pipe in subshell preserved",
+			input:    "(cd src && make) || echo failed",
+			expected: "(cd src && make) \\\n\t|| echo failed",
+		},
+		{
+			name:     "pipe in parameter expansion preserved",
+			input:    "echo ${var:-a|b} | wc -c",
+			expected: "echo ${var:-a|b} \\\n\t| wc -c",
+		},
+		{
+			name:     "heredoc left unchanged",
+			input:    "cat <<EOF | grep a\na\nb\nEOF",
+			expected: "cat <<EOF | grep a\na\nb\nEOF",
+		},
+		{
+			name:     "escaped quote",
+			input:    `echo it\'s | tr a-z A-Z`,
+			expected: "echo it\\'s \\\n\t| tr a-z A-Z",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := FormatCommand(tt.input)
+			result := FormatCommand(tt.input, ShellPOSIX)
 			if result != tt.expected {
 				t.Errorf("FormatCommand(%q)\ngot:  %q\nwant: %q", tt.input, result, tt.expected)
 			}
@@ -118,7 +148,7 @@ func TestUnformatCommand(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := UnformatCommand(tt.input)
+			result := UnformatCommand(tt.input, ShellPOSIX)
 			if result != tt.expected {
 				t.Errorf("UnformatCommand(%q)\ngot:  %q\nwant: %q", tt.input, result, tt.expected)
 			}
@@ -138,13 +168,86 @@ func TestFormatUnformatRoundTrip(t *testing.T) {
 		"cmd1 | cmd2 && cmd3 || cmd4",
 	}
 
-	for _, cmd := range commands {
-		t.Run(fmt.Sprintf("roundtrip_%s", cmd), func(t *testing.T) {
-			formatted := FormatCommand(cmd)
-			result := UnformatCommand(formatted)
-			if result != cmd {
-				t.Errorf("round-trip failed for %q\nFormatCommand: %q\nUnformatCommand: %q", cmd, formatted, result)
+	for _, shell := range []Shell{ShellPOSIX, ShellBash, ShellZsh, ShellFish} {
+		for _, cmd := range commands {
+			t.Run(fmt.Sprintf("roundtrip_%s_%s", shell, cmd), func(t *testing.T) {
+				formatted := FormatCommand(cmd, shell)
+				result := UnformatCommand(formatted, shell)
+				if result != cmd {
+					t.Errorf("round-trip failed for %q\nFormatCommand: %q\nUnformatCommand: %q", cmd, formatted, result)
+				}
+			})
+		}
+	}
+}
+
+func TestFormatCommand_Fish(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "pipe breaks after operator",
+			input:    "ps aux | grep nginx",
+			expected: "ps aux |\n\tgrep nginx",
+		},
+		{
+			name:     "logical operators",
+			input:    "mkdir dir && cd dir || echo failed",
+			expected: "mkdir dir &&\n\tcd dir ||\n\techo failed",
+		},
+		{
+			name:     "no backslash continuation",
+			input:    "ls $HOME | wc -l",
+			expected: "ls $HOME |\n\twc -l",
+		},
+		{
+			// the parser implements bash, not fish
+			name:     "fish command substitution left on one line",
+			input:    "ls (pwd) | wc -l",
+			expected: "ls (pwd) | wc -l",
+		},
+		{
+			name:     "pipe in quotes preserved",
+			input:    "echo 'a | b'",
+			expected: "echo 'a | b'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatCommand(tt.input, ShellFish); got != tt.expected {
+				t.Errorf("FormatCommand(%q, fish)\ngot:  %q\nwant: %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestUnformatCommand_Fish(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"trailing pipe", "ps aux |\n\tgrep nginx", "ps aux | grep nginx"},
+		{"trailing and", "make &&\n    make install", "make && make install"},
+		{"backslash continuation", "ps aux \\\n\t| grep nginx", "ps aux | grep nginx"},
+		{"single line", "ls -la", "ls -la"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UnformatCommand(tt.input, ShellFish); got != tt.expected {
+				t.Errorf("UnformatCommand(%q, fish) = %q, want %q", tt.input, got, tt.expected)
 			}
 		})
 	}
 }
+
+func TestUnformatCommand_POSIXKeepsTrailingPipeLines(t *testing.T) {
+	input := "ps aux |\ngrep nginx"
+	if got := UnformatCommand(input, ShellBash); got != input {
+		t.Errorf("UnformatCommand(%q, bash) = %q, want unchanged", input, got)
+	}
+}
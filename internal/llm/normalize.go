@@ -0,0 +1,178 @@
+package llm
+
+import (
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// shortFlagsRe matches bundled short flags such as -la.
+var shortFlagsRe = regexp.MustCompile(`^-[a-zA-Z]{2,}$`)
+
+// shortFlagTools lists commands whose single-dash words are always
+// bundled short flags. Others, such as find's -name or java's -jar, take
+// single-dash long options, so their flags are never split.
+var shortFlagTools = map[string]bool{
+	"cat": true, "chgrp": true, "chmod": true, "chown": true, "cp": true,
+	"curl": true, "cut": true, "df": true, "diff": true, "du": true,
+	"egrep": true, "fgrep": true, "free": true, "grep": true, "gzip": true,
+	"head": true, "id": true, "ln": true, "ls": true, "mkdir": true,
+	"mv": true, "netstat": true, "ps": true, "rg": true, "rm": true,
+	"rmdir": true, "sort": true, "ss": true, "tail": true, "tar": true,
+	"touch": true, "uname": true, "uniq": true, "unzip": true, "wc": true,
+	"xz": true, "zip": true,
+}
+
+// NormalizeCommand returns a canonical form of cmd for comparing variants.
+// Whitespace and quoting come from the syntax tree, bundled short flags
+// of well-known tools are split and every run of flags between two operands is sorted, so
+// "ls -la  ." and "ls -a -l '.'" compare equal. The result is a key, not a
+// command to run: reordering flags that take a value changes what a
+// command does. Commands that do not parse, and fish commands, are only
+// compared with their whitespace collapsed.
+func NormalizeCommand(cmd string, shell Shell) string {
+	cmd = UnformatCommand(cmd, shell)
+	fallback := strings.Join(strings.Fields(cmd), " ")
+	if shell == ShellFish {
+		return fallback
+	}
+	file, err := parseCommand(cmd)
+	if err != nil {
+		return fallback
+	}
+
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if call, ok := node.(*syntax.CallExpr); ok && len(call.Args) > 0 {
+			for _, arg := range call.Args {
+				requote(arg)
+			}
+			split := shortFlagTools[path.Base(call.Args[0].Lit())]
+			call.Args = append(call.Args[:1:1], sortFlags(call.Args[1:], split)...)
+		}
+		return true
+	})
+
+	var b strings.Builder
+	if err := syntax.NewPrinter(syntax.SingleLine(true)).Print(&b, file); err != nil {
+		return fallback
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// DedupeVariants removes variants whose command normalizes to the same
+// form as an earlier one. The first of each is kept with the highest
+// confidence of its duplicates.
+func DedupeVariants(variants []Variant, shell Shell) []Variant {
+	index := make(map[string]int, len(variants))
+	unique := make([]Variant, 0, len(variants))
+	for _, v := range variants {
+		key := NormalizeCommand(v.Command, shell)
+		if i, ok := index[key]; ok {
+			unique[i].Confidence = max(unique[i].Confidence, v.Confidence)
+			continue
+		}
+		index[key] = len(unique)
+		unique = append(unique, v)
+	}
+	return unique
+}
+
+// wordLiteral returns the value of w if it is made of plain text only,
+// quoted or not. Words with expansions, escapes or unquoted glob and
+// tilde characters are not literal.
+func wordLiteral(w *syntax.Word) (string, bool) {
+	var b strings.Builder
+	for _, part := range w.Parts {
+		switch p := part.(type) {
+		case *syntax.Lit:
+			if strings.ContainsAny(p.Value, `\*?[{~`) {
+				return "", false
+			}
+			b.WriteString(p.Value)
+		case *syntax.SglQuoted:
+			if p.Dollar {
+				return "", false
+			}
+			b.WriteString(p.Value)
+		case *syntax.DblQuoted:
+			for _, q := range p.Parts {
+				lit, ok := q.(*syntax.Lit)
+				if !ok || strings.Contains(lit.Value, `\`) {
+					return "", false
+				}
+				b.WriteString(lit.Value)
+			}
+		default:
+			return "", false
+		}
+	}
+	return b.String(), true
+}
+
+// requote replaces the parts of a literal word with its value quoted only
+// as much as needed.
+func requote(w *syntax.Word) {
+	value, ok := wordLiteral(w)
+	if !ok {
+		return
+	}
+	quoted, err := syntax.Quote(value, syntax.LangBash)
+	if err != nil {
+		return
+	}
+	w.Parts = []syntax.WordPart{&syntax.Lit{Value: quoted}}
+}
+
+// sortFlags sorts each run of flags in args up to a "--" that ends them,
+// first splitting bundled short flags if split is set. A flag followed by a word that is
+// not a flag may take that word as its value, so it ends the run and
+// keeps its place: only bare boolean flags are reordered.
+func sortFlags(args []*syntax.Word, split bool) []*syntax.Word {
+	sorted := make([]*syntax.Word, 0, len(args))
+	var run []string
+	flush := func() {
+		slices.Sort(run)
+		for _, flag := range run {
+			sorted = append(sorted, &syntax.Word{Parts: []syntax.WordPart{&syntax.Lit{Value: flag}}})
+		}
+		run = run[:0]
+	}
+
+	for i, arg := range args {
+		lit := arg.Lit()
+		if lit == "--" {
+			flush()
+			return append(sorted, args[i:]...)
+		}
+		if !isFlag(lit) {
+			flush()
+			sorted = append(sorted, arg)
+			continue
+		}
+		flags := []string{lit}
+		if split && shortFlagsRe.MatchString(lit) {
+			flags = flags[:0]
+			for _, c := range lit[1:] {
+				flags = append(flags, "-"+string(c))
+			}
+		}
+		if i+1 < len(args) && !isFlag(args[i+1].Lit()) {
+			last := flags[len(flags)-1]
+			run = append(run, flags[:len(flags)-1]...)
+			flush()
+			sorted = append(sorted, &syntax.Word{Parts: []syntax.WordPart{&syntax.Lit{Value: last}}})
+			continue
+		}
+		run = append(run, flags...)
+	}
+	flush()
+	return sorted
+}
+
+// isFlag reports whether lit is an option word such as -v or --all.
+func isFlag(lit string) bool {
+	return len(lit) >= 2 && lit[0] == '-'
+}
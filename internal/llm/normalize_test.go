@@ -0,0 +1,70 @@
+package llm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeCommand(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  string
+		shell Shell
+		same  bool
+	}{
+		{"whitespace", "ls  -l   /tmp", "ls -l /tmp", ShellBash, true},
+		{"flag order", "grep -r -n -i foo .", "grep -n -r -i foo .", ShellBash, true},
+		{"bundled flag before a value keeps its place", "tar -cf out.tar dir", "tar -c -f out.tar dir", ShellBash, true},
+		{"flag value that looks like a flag", "grep -e -v file", "grep -v -e file", ShellBash, false},
+		{"flag before its value is not moved", "tar -c -f out.tar dir", "tar -f -c out.tar dir", ShellBash, false},
+		{"bundled flag before its value is not moved", "tar -cf out.tar dir", "tar -fc out.tar dir", ShellBash, false},
+		{"bundled short flags", "ls -la", "ls -a -l", ShellPOSIX, true},
+		{"path to a known tool", "/bin/ls -la", "/bin/ls -a -l", ShellBash, true},
+		{"single-dash long option is not split", "find . -name x", "find . -n -a -m -e x", ShellBash, false},
+		{"unknown tool keeps its words", "java -jar app.jar", "java -j -a -r app.jar", ShellBash, false},
+		{"quoting", `echo 'a b' "c"`, `echo "a b" c`, ShellBash, true},
+		{"flags inside command substitution", "echo $(ls -la)", "echo $(ls -al)", ShellBash, true},
+		{"formatted continuation", "ps aux | \\\n\tgrep nginx", "ps aux | grep nginx", ShellBash, true},
+		{"flags are not moved across operands", "grep -i foo -r .", "grep -r foo -i .", ShellBash, false},
+		{"flags after -- are operands", "rm -- -b -a", "rm -- -a -b", ShellBash, false},
+		{"quoted glob is not a glob", "ls '*.go'", "ls *.go", ShellBash, false},
+		{"different commands", "ls -l", "ls -a", ShellBash, false},
+		{"fish collapses whitespace only", "ls  -la |\n\twc -l", "ls -la | wc -l", ShellFish, true},
+		{"fish keeps flag order", "ls -l -a", "ls -a -l", ShellFish, false},
+		{"unparsable collapses whitespace", "echo $(date  ", "echo $(date", ShellBash, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := NormalizeCommand(tt.a, tt.shell), NormalizeCommand(tt.b, tt.shell)
+			if (a == b) != tt.same {
+				t.Errorf("NormalizeCommand(%q) = %q, NormalizeCommand(%q) = %q, want same = %v", tt.a, a, tt.b, b, tt.same)
+			}
+		})
+	}
+}
+
+func TestDedupeVariants(t *testing.T) {
+	got := DedupeVariants([]Variant{
+		{Command: "ls -la", Confidence: 0.6},
+		{Command: "du -sh *", Confidence: 0.5},
+		{Command: "ls -al", Confidence: 0.9},
+		{Command: "ls  -l -a", Confidence: 0.2},
+		{Command: "du -sh *", Confidence: 0.4},
+	}, ShellBash)
+	want := []Variant{{Command: "ls -la", Confidence: 0.9}, {Command: "du -sh *", Confidence: 0.5}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DedupeVariants() = %v, want %v", got, want)
+	}
+}
+
+// find predicates are evaluated in order, so variants that only reorder
+// them are kept apart on purpose.
+func TestDedupeVariants_KeepsFindPredicateOrder(t *testing.T) {
+	variants := []Variant{
+		{Command: "find . -name x -type f", Confidence: 0.9},
+		{Command: "find . -type f -name x", Confidence: 0.8},
+	}
+	if got := DedupeVariants(variants, ShellBash); !reflect.DeepEqual(got, variants) {
+		t.Errorf("DedupeVariants() = %v, want %v", got, variants)
+	}
+}
@@ -1,6 +1,8 @@
 package llm
 
 import (
+	"net/http"
+
 	"github.com/sashabaranov/go-openai"
 )
 
@@ -9,12 +11,14 @@ type OpenAIProvider struct {
 	baseProvider
 }
 
-// newOpenAIProvider creates a new OpenAI-compatible provider.
-func newOpenAIProvider(cfg Config) (*OpenAIProvider, error) {
+// newOpenAIProvider creates a new OpenAI-compatible provider that sends
+// its requests with client.
+func newOpenAIProvider(cfg Config, client *http.Client) (*OpenAIProvider, error) {
 	config := openai.DefaultConfig(cfg.APIKey)
 	if cfg.BaseURL != "" {
 		config.BaseURL = cfg.BaseURL
 	}
+	config.HTTPClient = client
 
 	return &OpenAIProvider{
 		baseProvider: baseProvider{
@@ -26,7 +26,7 @@ func TestNewOpenAIProvider(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			provider, err := newOpenAIProvider(tt.cfg)
+			provider, err := newOpenAIProvider(tt.cfg, httpClient)
 			if err != nil {
 				t.Errorf("newOpenAIProvider() unexpected error = %v", err)
 				return
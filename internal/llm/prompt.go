@@ -3,18 +3,65 @@ package llm
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 )
 
+// DefaultConfidence is the confidence of a variant the model did not rate.
+const DefaultConfidence = 0.5
+
+// Variant is a generated command with the model's confidence, between 0
+// and 1, that it does exactly what was asked.
+type Variant struct {
+	Command    string  `json:"command"`
+	Confidence float64 `json:"confidence"`
+	// Cached reports that the variant was served from the response cache.
+	Cached bool `json:"-"`
+}
+
+// Commands returns the commands of variants in order.
+func Commands(variants []Variant) []string {
+	commands := make([]string, len(variants))
+	for i, v := range variants {
+		commands[i] = v.Command
+	}
+	return commands
+}
+
 // commandsResponse represents the expected JSON structure from LLM
 type commandsResponse struct {
-	Commands []string `json:"commands"`
+	Commands []commandEntry `json:"commands"`
+}
+
+// commandEntry is a command with its confidence. Models that ignore the
+// requested format and return plain strings are accepted too.
+type commandEntry struct {
+	Command    string   `json:"command"`
+	Confidence *float64 `json:"confidence"`
+}
+
+func (e *commandEntry) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &e.Command)
+	}
+	type plain commandEntry
+	return json.Unmarshal(data, (*plain)(e))
+}
+
+// confidence returns the confidence of e clamped to [0, 1].
+func (e commandEntry) confidence() float64 {
+	if e.Confidence == nil {
+		return DefaultConfidence
+	}
+	return min(max(*e.Confidence, 0), 1)
 }
 
 // SystemPrompt generates the system prompt for command generation.
+// shell selects the dialect commands are written in.
 // count specifies how many command variants should be generated.
 // hasPipeContext indicates whether stdin context is provided with the request.
 // hasFollowUp indicates whether this is a follow-up refinement of a previous command.
-func SystemPrompt(count int, hasPipeContext bool, hasFollowUp bool) string {
+// extraRules are appended to the rule list, one per line.
+func SystemPrompt(shell Shell, count int, hasPipeContext bool, hasFollowUp bool, extraRules ...string) string {
 	pipeRules := ""
 	if hasPipeContext {
 		pipeRules = `
@@ -29,10 +76,15 @@ func SystemPrompt(count int, hasPipeContext bool, hasFollowUp bool) string {
 - The user is refining a previous command. Consider the conversation history and generate commands that address the user's refinement request`
 	}
 
+	contextRules := ""
+	for _, rule := range extraRules {
+		contextRules += "\n- " + rule
+	}
+
 	return fmt.Sprintf(`You are a shell command generator. Generate shell commands based on user descriptions.
 
 Rules:
-- Generate POSIX-compatible commands that work in bash, zsh, and fish
+- %s
 - Return exactly %d different command variants
 - Commands should be practical and safe
 - Prefer using a single tool's full capabilities over chaining multiple tools
@@ -40,16 +92,31 @@ Rules:
 - When a tool supports structured output (JSON, YAML, CSV), use its native query capabilities rather than text processing with grep/awk/sed
 - Minimize pipe chains: fewer pipes = better
 - Never include explanations, only raw commands
-- Each command should solve the same task in a different way%s%s
+- Never guess values that are not in the request or the context, such as bucket names, hosts or file paths: write a typed placeholder {{name:type}} instead, where type is string, path or number, e.g. aws s3 cp {{file:path}} s3://{{bucket:string}}/
+- Each command should solve the same task in a different way
+- Rate each command with a confidence between 0 and 1 that it does exactly what was asked on the user's system%s%s%s
 
 Response format (JSON):
 {
-  "commands": ["command1", "command2", ...]
-}`, count, pipeRules, followUpRules)
+  "commands": [{"command": "command1", "confidence": 0.9}, {"command": "command2", "confidence": 0.6}, ...]
+}`, shellRule(shell), count, pipeRules, followUpRules, contextRules)
 }
 
-// ParseCommands parses JSON response from LLM into a list of commands
-func ParseCommands(jsonResponse []byte) ([]string, error) {
+// TopUpPrompt asks for count more variants after some of the previous
+// ones were duplicates or invalid; commands are the variants kept.
+func TopUpPrompt(commands []string, count int) string {
+	var b strings.Builder
+	for _, cmd := range commands {
+		b.WriteString("\n- " + cmd)
+	}
+	return fmt.Sprintf(`Some of those variants were duplicates of each other or had syntax errors. These are kept:%s
+
+Return exactly %d more command variants for the same task that take different approaches from the ones kept, e.g. other tools or other options. Use the same JSON format.`, b.String(), count)
+}
+
+// ParseCommands parses JSON response from LLM into a list of variants
+// formatted for shell. Commands with syntax errors are dropped.
+func ParseCommands(jsonResponse []byte, shell Shell) ([]Variant, error) {
 	if len(jsonResponse) == 0 {
 		return nil, fmt.Errorf("empty response from LLM")
 	}
@@ -63,16 +130,28 @@ func ParseCommands(jsonResponse []byte) ([]string, error) {
 		return nil, fmt.Errorf("LLM returned no commands")
 	}
 
-	validCommands := make([]string, 0, len(response.Commands))
-	for _, cmd := range response.Commands {
-		if cmd != "" {
-			validCommands = append(validCommands, FormatCommand(cmd))
+	variants := make([]Variant, 0, len(response.Commands))
+	var syntaxErr error
+	for _, entry := range response.Commands {
+		cmd := entry.Command
+		if strings.TrimSpace(cmd) == "" {
+			continue
 		}
+		if err := CheckSyntax(cmd, shell); err != nil {
+			if syntaxErr == nil {
+				syntaxErr = fmt.Errorf("%q: %w", cmd, err)
+			}
+			continue
+		}
+		variants = append(variants, Variant{Command: FormatCommand(cmd, shell), Confidence: entry.confidence()})
 	}
 
-	if len(validCommands) == 0 {
+	if len(variants) == 0 {
+		if syntaxErr != nil {
+			return nil, fmt.Errorf("LLM returned only commands with syntax errors: %w", syntaxErr)
+		}
 		return nil, fmt.Errorf("LLM returned only empty commands")
 	}
 
-	return validCommands, nil
+	return variants, nil
 }
@@ -1,6 +1,7 @@
 package llm
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -57,11 +58,25 @@ func TestSystemPrompt(t *testing.T) {
 			want:           "Identify the source tool from the context and prefer using its built-in capabilities",
 			wantAlso:       "over adding separate tools to the pipeline",
 		},
+		{
+			name:           "base prompt asks for a confidence per command",
+			count:          3,
+			hasPipeContext: false,
+			want:           "confidence between 0 and 1",
+			wantAlso:       `{"command": "command1", "confidence": 0.9}`,
+		},
+		{
+			name:           "base prompt asks for placeholders instead of guesses",
+			count:          3,
+			hasPipeContext: false,
+			want:           "typed placeholder {{name:type}}",
+			wantAlso:       "string, path or number",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := SystemPrompt(tt.count, tt.hasPipeContext, false)
+			got := SystemPrompt(ShellPOSIX, tt.count, tt.hasPipeContext, false)
 			if got == "" {
 				t.Error("SystemPrompt returned empty string")
 			}
@@ -80,21 +95,21 @@ func TestSystemPrompt(t *testing.T) {
 
 func TestSystemPrompt_FollowUp(t *testing.T) {
 	t.Run("without follow-up does not contain refinement rules", func(t *testing.T) {
-		got := SystemPrompt(3, false, false)
+		got := SystemPrompt(ShellPOSIX, 3, false, false)
 		if strings.Contains(got, "refining a previous command") {
 			t.Error("SystemPrompt should not contain follow-up rules when hasFollowUp is false")
 		}
 	})
 
 	t.Run("with follow-up includes refinement rules", func(t *testing.T) {
-		got := SystemPrompt(3, false, true)
+		got := SystemPrompt(ShellPOSIX, 3, false, true)
 		if !strings.Contains(got, "refining a previous command") {
 			t.Error("SystemPrompt should contain follow-up refinement rules when hasFollowUp is true")
 		}
 	})
 
 	t.Run("with follow-up and pipe context includes both rules", func(t *testing.T) {
-		got := SystemPrompt(3, true, true)
+		got := SystemPrompt(ShellPOSIX, 3, true, true)
 		if !strings.Contains(got, "refining a previous command") {
 			t.Error("SystemPrompt should contain follow-up rules")
 		}
@@ -104,6 +119,74 @@ func TestSystemPrompt_FollowUp(t *testing.T) {
 	})
 }
 
+func TestSystemPrompt_ExtraRules(t *testing.T) {
+	got := SystemPrompt(ShellPOSIX, 3, false, false, "Prefer installed tools", "Use GNU flags")
+
+	for _, want := range []string{"\n- Prefer installed tools", "\n- Use GNU flags"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("SystemPrompt should contain %q", want)
+		}
+	}
+	if strings.Index(got, "Use GNU flags") > strings.Index(got, "Response format") {
+		t.Error("extra rules should be listed before the response format")
+	}
+}
+
+func TestSystemPrompt_Shell(t *testing.T) {
+	tests := []struct {
+		shell      Shell
+		want       string
+		wantAbsent string
+	}{
+		{ShellPOSIX, "POSIX-compatible commands that work in bash, zsh, and fish", ""},
+		{ShellBash, "Generate commands for bash", "POSIX-compatible"},
+		{ShellZsh, "Generate commands for zsh", "POSIX-compatible"},
+		{ShellFish, "instead of $(command)", "POSIX-compatible"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.shell), func(t *testing.T) {
+			got := SystemPrompt(tt.shell, 3, false, false)
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("SystemPrompt(%q) should contain %q", tt.shell, tt.want)
+			}
+			if tt.wantAbsent != "" && strings.Contains(got, tt.wantAbsent) {
+				t.Errorf("SystemPrompt(%q) should not contain %q", tt.shell, tt.wantAbsent)
+			}
+		})
+	}
+}
+
+func TestParseCommands_FormatsForShell(t *testing.T) {
+	got, err := ParseCommands([]byte(`{"commands": ["ps aux | grep nginx"]}`), ShellFish)
+	if err != nil {
+		t.Fatalf("ParseCommands() error = %v", err)
+	}
+	if got[0].Command != "ps aux |\n\tgrep nginx" {
+		t.Errorf("ParseCommands() = %q, want fish formatting", got[0].Command)
+	}
+}
+
+func TestParseCommands_Confidence(t *testing.T) {
+	got, err := ParseCommands([]byte(`{"commands": [
+		{"command": "ls -la", "confidence": 0.9},
+		{"command": "tree"},
+		{"command": "du -sh .", "confidence": 7},
+		"find . -maxdepth 1"
+	]}`), ShellPOSIX)
+	if err != nil {
+		t.Fatalf("ParseCommands() error = %v", err)
+	}
+	want := []Variant{
+		{Command: "ls -la", Confidence: 0.9},
+		{Command: "tree", Confidence: DefaultConfidence},
+		{Command: "du -sh .", Confidence: 1},
+		{Command: "find . -maxdepth 1", Confidence: DefaultConfidence},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseCommands() = %+v, want %+v", got, want)
+	}
+}
+
 func TestParseCommands(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -146,22 +229,60 @@ func TestParseCommands(t *testing.T) {
 			input:   `{"commands": ["", ""]}`,
 			wantErr: true,
 		},
+		{
+			name:  "commands with syntax errors are dropped",
+			input: `{"commands": ["echo $(date", "ls -la", "if true; then echo"]}`,
+			want:  []string{"ls -la"},
+		},
+		{
+			name:    "all commands with syntax errors",
+			input:   `{"commands": ["cat <<EOF", "echo 'unterminated"]}`,
+			wantErr: true,
+		},
+		{
+			name:  "placeholders parse as words",
+			input: `{"commands": ["scp {{src:path}} {{host}}:/tmp"]}`,
+			want:  []string{"scp {{src:path}} {{host}}:/tmp"},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := ParseCommands([]byte(tt.input))
+			got, err := ParseCommands([]byte(tt.input), ShellPOSIX)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ParseCommands() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if !tt.wantErr && !equalSlices(got, tt.want) {
+			if !tt.wantErr && !equalSlices(Commands(got), tt.want) {
 				t.Errorf("ParseCommands() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
 
+func TestCheckSyntax(t *testing.T) {
+	tests := []struct {
+		name    string
+		cmd     string
+		shell   Shell
+		wantErr bool
+	}{
+		{"valid pipeline", "ps aux | grep nginx", ShellPOSIX, false},
+		{"heredoc", "cat <<EOF\nhello\nEOF", ShellBash, false},
+		{"unclosed command substitution", "echo $(date", ShellBash, true},
+		{"unclosed quote", "echo 'hi", ShellPOSIX, true},
+		{"zsh is not checked", "print -l ${(f)\"$(ls)\"}", ShellZsh, false},
+		{"fish is not checked", "echo (pwd", ShellFish, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckSyntax(tt.cmd, tt.shell); (err != nil) != tt.wantErr {
+				t.Errorf("CheckSyntax(%q) error = %v, wantErr %v", tt.cmd, err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func equalSlices(a, b []string) bool {
 	if len(a) != len(b) {
 		return false
@@ -1,6 +1,10 @@
 package llm
 
-import "context"
+import (
+	"context"
+	"sync"
+	"time"
+)
 
 // Config contains configuration for LLM provider
 type Config struct {
@@ -9,20 +13,67 @@ type Config struct {
 	Model    string
 	Provider string
 	Count    int // number of command variants to generate
+
+	// CacheDir enables the response cache in this directory when set.
+	CacheDir        string
+	CacheTTL        time.Duration
+	CacheMaxEntries int
 }
 
 // FollowUpContext contains previous query and command for refinement mode.
+// A command that failed also carries its exit code and error output;
+// PreviousQuery is empty when the command was not generated by qx.
 type FollowUpContext struct {
 	PreviousQuery   string
 	PreviousCommand string
+	ExitCode        int
+	ErrorOutput     string
+}
+
+// Failed reports whether the previous command failed.
+func (f *FollowUpContext) Failed() bool {
+	return f.ExitCode != 0 || f.ErrorOutput != ""
 }
 
-// Provider generates shell commands using LLM
+// Request describes a single command generation.
+type Request struct {
+	Query       string
+	Count       int    // number of command variants to generate
+	Shell       Shell  // dialect of the generated commands
+	PipeContext string // optional stdin data piped into qx
+	FollowUp    *FollowUpContext
+	Context     []ContextBlock
+}
+
+// Provider generates and explains shell commands using LLM
 type Provider interface {
-	Generate(ctx context.Context, query string, count int, pipeContext string, followUp *FollowUpContext) ([]string, error)
+	Generate(ctx context.Context, req Request) ([]Variant, error)
+	Explain(ctx context.Context, req ExplainRequest) (*Explanation, error)
+	GenerateScript(ctx context.Context, req Request) ([]ScriptStep, error)
 }
 
-// NewProvider creates appropriate provider based on configuration
+var (
+	providersMu sync.Mutex
+	providers   = make(map[Config]Provider)
+)
+
+// NewProvider returns the provider for cfg. Providers are created once
+// per configuration and reused for the lifetime of the process.
 func NewProvider(cfg Config) (Provider, error) {
-	return newOpenAIProvider(cfg)
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	if p, ok := providers[cfg]; ok {
+		return p, nil
+	}
+	var p Provider
+	p, err := newOpenAIProvider(cfg, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.CacheDir != "" {
+		p = &cachingProvider{Provider: p, cfg: cfg, cache: NewCache(cfg.CacheDir, cfg.CacheTTL, cfg.CacheMaxEntries)}
+	}
+	providers[cfg] = p
+	return p, nil
 }
@@ -0,0 +1,114 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ScriptStep is one step of a generated script.
+type ScriptStep struct {
+	Description string `json:"description"`
+	Command     string `json:"command"`
+}
+
+// scriptResponse represents the expected JSON structure of a script.
+type scriptResponse struct {
+	Steps []ScriptStep `json:"steps"`
+}
+
+// GenerateScript breaks the task in r.Query down into ordered steps.
+// r.Count and r.FollowUp are ignored.
+func (p *baseProvider) GenerateScript(ctx context.Context, r Request) ([]ScriptStep, error) {
+	if r.Query == "" {
+		return nil, fmt.Errorf("query cannot be empty")
+	}
+
+	blocks := requestBlocks(r)
+	messages := []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: ScriptPrompt(r.Shell, r.PipeContext != "", blockRules(blocks)...)},
+		{Role: openai.ChatMessageRoleUser, Content: buildUserMessage(r.Query, blocks)},
+	}
+
+	content, err := p.complete(ctx, messages, DefaultTemperature)
+	if err != nil {
+		return nil, err
+	}
+
+	steps, err := ParseScript([]byte(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse LLM output: %w", err)
+	}
+	// a step cannot be dropped without breaking the ones after it
+	for i, step := range steps {
+		if err := CheckSyntax(step.Command, r.Shell); err != nil {
+			return nil, fmt.Errorf("LLM returned step %d with a syntax error: %w", i+1, err)
+		}
+	}
+	return steps, nil
+}
+
+// ScriptPrompt generates the system prompt for script generation.
+// shell selects the dialect the steps are written in.
+// hasPipeContext indicates whether stdin context is provided with the request.
+// extraRules are appended to the rule list, one per line.
+func ScriptPrompt(shell Shell, hasPipeContext bool, extraRules ...string) string {
+	pipeRules := ""
+	if hasPipeContext {
+		pipeRules = `
+- When stdin context is provided, use the concrete values from it (file names, hosts, database names, etc.)`
+	}
+
+	contextRules := ""
+	for _, rule := range extraRules {
+		contextRules += "\n- " + rule
+	}
+
+	return fmt.Sprintf(`You are a shell script generator. Break the user's task down into a short sequence of steps.
+
+Rules:
+- %s
+- Each step is a single command (a pipeline or && chain is fine) with a one-sentence description
+- Order the steps so that each one can rely on the results of the previous ones
+- Each step may run in a separate shell process: do not rely on cd or shell variables set by earlier steps; use absolute or explicit paths instead
+- Use as few steps as the task allows, usually 2 to 8
+- Steps that delete or overwrite data must be as narrow as possible, e.g. prune only files matching a pattern and age
+- Never include explanations outside the descriptions%s%s
+
+Response format (JSON):
+{
+  "steps": [
+    {"description": "what the step does", "command": "command"}
+  ]
+}`, shellRule(shell), pipeRules, contextRules)
+}
+
+// ParseScript parses JSON response from LLM into script steps, dropping
+// steps without a command.
+func ParseScript(jsonResponse []byte) ([]ScriptStep, error) {
+	if len(jsonResponse) == 0 {
+		return nil, fmt.Errorf("empty response from LLM")
+	}
+
+	var response scriptResponse
+	if err := json.Unmarshal(jsonResponse, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse LLM response: %w", err)
+	}
+
+	steps := make([]ScriptStep, 0, len(response.Steps))
+	for _, step := range response.Steps {
+		step.Command = strings.TrimSpace(step.Command)
+		if step.Command != "" {
+			step.Description = strings.TrimSpace(step.Description)
+			steps = append(steps, step)
+		}
+	}
+
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("LLM returned no steps")
+	}
+	return steps, nil
+}
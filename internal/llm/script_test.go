@@ -0,0 +1,119 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestGenerateScript(t *testing.T) {
+	var capturedRequest openai.ChatCompletionRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&capturedRequest); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		resp := openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{
+				{Message: openai.ChatCompletionMessage{Content: `{"steps": [
+					{"description": "Dump the database", "command": "pg_dump app > /tmp/app.sql"},
+					{"description": "Compress the dump", "command": "gzip -f /tmp/app.sql"}
+				]}`}},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	cfg := openai.DefaultConfig("test-key")
+	cfg.BaseURL = server.URL + "/v1"
+	provider := &baseProvider{
+		client: openai.NewClientWithConfig(cfg),
+		model:  "test-model",
+	}
+
+	steps, err := provider.GenerateScript(context.Background(), Request{
+		Query:       "back up the app database",
+		Shell:       ShellBash,
+		PipeContext: "app\nbilling",
+	})
+	if err != nil {
+		t.Fatalf("GenerateScript() unexpected error: %v", err)
+	}
+	want := []ScriptStep{
+		{Description: "Dump the database", Command: "pg_dump app > /tmp/app.sql"},
+		{Description: "Compress the dump", Command: "gzip -f /tmp/app.sql"},
+	}
+	if len(steps) != len(want) || steps[0] != want[0] || steps[1] != want[1] {
+		t.Errorf("GenerateScript() = %+v, want %+v", steps, want)
+	}
+
+	sysMsg := capturedRequest.Messages[0].Content
+	if !strings.Contains(sysMsg, "shell script generator") || !strings.Contains(sysMsg, "for bash") || !strings.Contains(sysMsg, "stdin context") {
+		t.Errorf("system prompt should be the script prompt for bash with stdin rules:\n%s", sysMsg)
+	}
+	userMsg := capturedRequest.Messages[1].Content
+	if !strings.Contains(userMsg, "<stdin>\napp\nbilling\n</stdin>") || !strings.Contains(userMsg, "Task: back up the app database") {
+		t.Errorf("user message = %q, want stdin followed by the task", userMsg)
+	}
+}
+
+func TestGenerateScript_RejectsSyntaxError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		resp := openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{
+				{Message: openai.ChatCompletionMessage{Content: `{"steps": [
+					{"description": "List files", "command": "ls"},
+					{"description": "Broken", "command": "echo $(date"}
+				]}`}},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	cfg := openai.DefaultConfig("test-key")
+	cfg.BaseURL = server.URL + "/v1"
+	provider := &baseProvider{client: openai.NewClientWithConfig(cfg), model: "test-model"}
+
+	_, err := provider.GenerateScript(context.Background(), Request{Query: "list", Shell: ShellBash})
+	if err == nil || !strings.Contains(err.Error(), "step 2 with a syntax error") {
+		t.Errorf("GenerateScript() error = %v, want a syntax error in step 2", err)
+	}
+}
+
+func TestParseScript(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		wantSteps int
+		wantErr   bool
+	}{
+		{"valid", `{"steps": [{"description": "List", "command": "ls"}, {"description": "Count", "command": "wc -l"}]}`, 2, false},
+		{"drops empty commands", `{"steps": [{"description": "Nothing", "command": "  "}, {"description": "List", "command": "ls"}]}`, 1, false},
+		{"no steps", `{"steps": []}`, 0, true},
+		{"only empty commands", `{"steps": [{"description": "Nothing", "command": ""}]}`, 0, true},
+		{"invalid json", `{"steps":`, 0, true},
+		{"empty", ``, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps, err := ParseScript([]byte(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseScript() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(steps) != tt.wantSteps {
+				t.Errorf("ParseScript() returned %d steps, want %d", len(steps), tt.wantSteps)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package llm
+
+import "fmt"
+
+// Shell is the shell dialect commands are generated for.
+type Shell string
+
+// Supported shell dialects. ShellPOSIX targets commands that work in any
+// of the supported shells and is used when the shell is unknown.
+const (
+	ShellPOSIX Shell = ""
+	ShellBash  Shell = "bash"
+	ShellZsh   Shell = "zsh"
+	ShellFish  Shell = "fish"
+)
+
+// ParseShell validates a shell name. An empty name yields ShellPOSIX.
+func ParseShell(name string) (Shell, error) {
+	switch s := Shell(name); s {
+	case ShellPOSIX, ShellBash, ShellZsh, ShellFish:
+		return s, nil
+	}
+	return ShellPOSIX, fmt.Errorf("unsupported shell: %s (supported: bash, zsh, fish)", name)
+}
+
+// shellRule returns the system prompt rule describing the target dialect.
+func shellRule(shell Shell) string {
+	switch shell {
+	case ShellBash:
+		return "Generate commands for bash; bash-specific syntax such as [[ ]], arrays and process substitution is allowed"
+	case ShellZsh:
+		return "Generate commands for zsh; zsh-specific syntax such as recursive globs (**/*.go) and glob qualifiers is allowed"
+	case ShellFish:
+		return "Generate commands for the fish shell, never bash syntax: use (command) instead of $(command) or backticks, set -gx NAME value instead of export NAME=value, and fish blocks for loops and conditionals (for f in *.txt; ...; end)"
+	}
+	return "Generate POSIX-compatible commands that work in bash, zsh, and fish"
+}
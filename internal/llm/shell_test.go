@@ -0,0 +1,29 @@
+package llm
+
+import "testing"
+
+func TestParseShell(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Shell
+		wantErr bool
+	}{
+		{"", ShellPOSIX, false},
+		{"bash", ShellBash, false},
+		{"zsh", ShellZsh, false},
+		{"fish", ShellFish, false},
+		{"powershell", ShellPOSIX, true},
+		{"Fish", ShellPOSIX, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseShell(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseShell(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseShell(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+// Package mcp serves qx's command generation, explanation, secret guard
+// and history as tools over the Model Context Protocol: JSON-RPC 2.0
+// messages, one per line, on stdin and stdout.
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/evgfitil/qx/pkg/qx"
+)
+
+// ProtocolVersion is the latest MCP revision the server implements.
+const ProtocolVersion = "2025-06-18"
+
+// supportedVersions are the revisions a client may ask for.
+var supportedVersions = []string{"2024-11-05", "2025-03-26", ProtocolVersion}
+
+// JSON-RPC error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// Options configures the server.
+type Options struct {
+	// Client runs every tool call. Its guard policy should block secrets,
+	// since the model cannot override the check, and its shell is used
+	// when a call names none.
+	Client *qx.Client
+	// Timeout bounds each LLM request.
+	Timeout time.Duration
+	// Version is reported to the client.
+	Version string
+}
+
+// Server answers MCP requests.
+type Server struct {
+	opts  Options
+	tools []tool
+}
+
+// NewServer creates a Server with the qx tools.
+func NewServer(opts Options) *Server {
+	s := &Server{opts: opts}
+	s.tools = s.newTools()
+	return s
+}
+
+// message is a JSON-RPC request, notification or response from the client.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+// Serve answers the messages read from r on w, one at a time, until r
+// ends or ctx is done.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	in := bufio.NewReader(r)
+	enc := json.NewEncoder(w)
+	for {
+		line, err := in.ReadBytes('\n')
+		if len(line) > 0 {
+			if resp, ok := s.handle(ctx, line); ok {
+				if err := enc.Encode(resp); err != nil {
+					return fmt.Errorf("writing response: %w", err)
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) || ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading request: %w", err)
+		}
+	}
+}
+
+// handle answers one line. Notifications, responses and blank lines get
+// no answer.
+func (s *Server) handle(ctx context.Context, line []byte) (response, bool) {
+	if len(bytes.TrimSpace(line)) == 0 {
+		return response{}, false
+	}
+	var msg message
+	if err := json.Unmarshal(line, &msg); err != nil {
+		return errorResponse(json.RawMessage("null"), &rpcError{Code: codeParseError, Message: "parse error: " + err.Error()}), true
+	}
+	if msg.Method == "" {
+		if len(msg.ID) == 0 {
+			return errorResponse(json.RawMessage("null"), &rpcError{Code: codeInvalidRequest, Message: "invalid request"}), true
+		}
+		// a response to a request the server never sends
+		return response{}, false
+	}
+	if len(msg.ID) == 0 {
+		return response{}, false
+	}
+
+	result, err := s.call(ctx, msg.Method, msg.Params)
+	if err != nil {
+		var rpcErr *rpcError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &rpcError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		return errorResponse(msg.ID, rpcErr), true
+	}
+	return response{JSONRPC: "2.0", ID: msg.ID, Result: result}, true
+}
+
+func errorResponse(id json.RawMessage, err *rpcError) response {
+	return response{JSONRPC: "2.0", ID: id, Error: err}
+}
+
+// call runs method with params.
+func (s *Server) call(ctx context.Context, method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "initialize":
+		var p struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		_ = json.Unmarshal(params, &p)
+		version := ProtocolVersion
+		if slices.Contains(supportedVersions, p.ProtocolVersion) {
+			version = p.ProtocolVersion
+		}
+		return map[string]any{
+			"protocolVersion": version,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]any{"name": "qx", "version": s.opts.Version},
+		}, nil
+	case "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		return map[string]any{"tools": s.tools}, nil
+	case "tools/call":
+		var p struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, fmt.Errorf("invalid params: %w", err)
+		}
+		return s.callTool(ctx, p.Name, p.Arguments)
+	}
+	return nil, &rpcError{Code: codeMethodNotFound, Message: "method not found: " + method}
+}
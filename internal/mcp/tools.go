@@ -0,0 +1,229 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/evgfitil/qx/internal/service"
+	"github.com/evgfitil/qx/pkg/qx"
+)
+
+// defaultHistoryLimit caps the entries search_history returns when the
+// call sets no limit.
+const defaultHistoryLimit = 20
+
+// tool is a tool as listed by tools/list, with the function that runs it.
+type tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"inputSchema"`
+	run         func(ctx context.Context, args json.RawMessage) (any, error)
+}
+
+// toolResult is the result of tools/call. Failures of the tool itself are
+// reported with IsError so the model can see them.
+type toolResult struct {
+	Content           []content `json:"content"`
+	StructuredContent any       `json:"structuredContent,omitempty"`
+	IsError           bool      `json:"isError,omitempty"`
+}
+
+type content struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// toolError is a failure reported to the model rather than as a protocol
+// error, such as a query rejected for containing secrets.
+type toolError struct {
+	err error
+}
+
+func (e toolError) Error() string { return e.err.Error() }
+
+const shellProperty = `"shell": {"type": "string", "enum": ["bash", "zsh", "fish"], "description": "Target shell dialect; the user's shell when omitted"}`
+
+func (s *Server) newTools() []tool {
+	return []tool{
+		{
+			Name:        "generate_command",
+			Description: "Generate shell command variants for a task described in natural language, best first, each with the model's confidence and a locally computed risk level. Queries containing secrets are rejected.",
+			InputSchema: json.RawMessage(`{"type": "object", "properties": {
+				"query": {"type": "string", "description": "What the command should do"},
+				"count": {"type": "integer", "minimum": 1, "description": "Number of variants"},
+				` + shellProperty + `,
+				"pipe_context": {"type": "string", "description": "Sample of the input the command will process"}
+			}, "required": ["query"]}`),
+			run: s.generateCommand,
+		},
+		{
+			Name:        "explain_command",
+			Description: "Explain what a shell command does, stage by stage and flag by flag, with a locally computed risk level. Commands containing secrets are rejected.",
+			InputSchema: json.RawMessage(`{"type": "object", "properties": {
+				"command": {"type": "string", "description": "The command to explain"},
+				` + shellProperty + `
+			}, "required": ["command"]}`),
+			run: s.explainCommand,
+		},
+		{
+			Name:        "check_secrets",
+			Description: "Check text for secrets such as API keys, tokens and private keys before sending it anywhere. Runs locally.",
+			InputSchema: json.RawMessage(`{"type": "object", "properties": {
+				"text": {"type": "string", "description": "The text to check"}
+			}, "required": ["text"]}`),
+			run: s.checkSecrets,
+		},
+		{
+			Name:        "search_history",
+			Description: "Search the user's qx history for past queries and the commands they picked, newest first. Texts containing secrets are redacted.",
+			InputSchema: json.RawMessage(`{"type": "object", "properties": {
+				"query": {"type": "string", "description": "Text to find in past queries or commands; all entries when empty"},
+				"limit": {"type": "integer", "minimum": 1, "description": "Maximum number of entries, 20 by default"}
+			}}`),
+			run: s.searchHistory,
+		},
+	}
+}
+
+// callTool runs the tool called name. Unknown tools and invalid
+// arguments are protocol errors; anything else is reported in the result.
+func (s *Server) callTool(ctx context.Context, name string, args json.RawMessage) (any, error) {
+	for _, t := range s.tools {
+		if t.Name != name {
+			continue
+		}
+		if len(args) == 0 {
+			args = json.RawMessage("{}")
+		}
+		result, err := t.run(ctx, args)
+		var te toolError
+		if errors.As(err, &te) {
+			return toolResult{Content: []content{{Type: "text", Text: te.Error()}}, IsError: true}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		text, err := json.Marshal(result)
+		if err != nil {
+			return nil, err
+		}
+		return toolResult{Content: []content{{Type: "text", Text: string(text)}}, StructuredContent: result}, nil
+	}
+	return nil, fmt.Errorf("unknown tool: %s", name)
+}
+
+// decodeArgs reads tool arguments into v, rejecting unknown ones.
+func decodeArgs(args json.RawMessage, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(args))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
+
+func (s *Server) generateCommand(ctx context.Context, raw json.RawMessage) (any, error) {
+	var args struct {
+		Query       string `json:"query"`
+		Count       int    `json:"count"`
+		Shell       string `json:"shell"`
+		PipeContext string `json:"pipe_context"`
+	}
+	if err := decodeArgs(raw, &args); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(args.Query) == "" {
+		return nil, errors.New("query is required")
+	}
+	if args.Count < 0 {
+		return nil, fmt.Errorf("count must not be negative, got %d", args.Count)
+	}
+	client, err := service.Client(s.opts.Client, args.Shell)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := service.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+
+	commands, _, err := service.Generate(ctx, client, qx.Request{Query: args.Query, Count: args.Count, PipeContext: args.PipeContext})
+	if err != nil {
+		return nil, clientError(err)
+	}
+	return map[string]any{"commands": commands}, nil
+}
+
+func (s *Server) explainCommand(ctx context.Context, raw json.RawMessage) (any, error) {
+	var args struct {
+		Command string `json:"command"`
+		Shell   string `json:"shell"`
+	}
+	if err := decodeArgs(raw, &args); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(args.Command) == "" {
+		return nil, errors.New("command is required")
+	}
+	client, err := service.Client(s.opts.Client, args.Shell)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := service.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+
+	explanation, err := service.Explain(ctx, client, args.Command)
+	if err != nil {
+		return nil, clientError(err)
+	}
+	return explanation, nil
+}
+
+func (s *Server) checkSecrets(_ context.Context, raw json.RawMessage) (any, error) {
+	var args struct {
+		Text string `json:"text"`
+	}
+	if err := decodeArgs(raw, &args); err != nil {
+		return nil, err
+	}
+	detections := qx.DetectSecrets(args.Text)
+	if detections == nil {
+		detections = []qx.Detection{}
+	}
+	return map[string]any{"has_secrets": len(detections) > 0, "detections": detections}, nil
+}
+
+func (s *Server) searchHistory(_ context.Context, raw json.RawMessage) (any, error) {
+	var args struct {
+		Query string `json:"query"`
+		Limit int    `json:"limit"`
+	}
+	if err := decodeArgs(raw, &args); err != nil {
+		return nil, err
+	}
+	if args.Limit < 0 {
+		return nil, fmt.Errorf("limit must not be negative, got %d", args.Limit)
+	}
+	limit := args.Limit
+	if limit == 0 {
+		limit = defaultHistoryLimit
+	}
+
+	entries, err := service.History(s.opts.Client, args.Query, limit)
+	if err != nil {
+		return nil, toolError{err}
+	}
+	return map[string]any{"entries": entries}, nil
+}
+
+// clientError reports a failed tool call to the model. Queries with
+// secrets are rejected; the model cannot override the check.
+func clientError(err error) error {
+	var secretsErr *qx.SecretsError
+	if errors.As(err, &secretsErr) {
+		return toolError{fmt.Errorf("%w; remove them and try again", err)}
+	}
+	return toolError{err}
+}
@@ -0,0 +1,277 @@
+package pipeinput
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/evgfitil/qx/internal/structured"
+)
+
+// Strategy selects how input over the budget is reduced.
+type Strategy string
+
+// Supported strategies.
+const (
+	// StrategyHeadTail keeps the beginning and the end of the input.
+	StrategyHeadTail Strategy = "head-tail"
+	// StrategySample keeps evenly spaced unique lines.
+	StrategySample Strategy = "sample"
+	// StrategyDedupe collapses repeated lines, such as log lines that only
+	// differ in timestamps or ids, then keeps the beginning and the end.
+	StrategyDedupe Strategy = "dedupe"
+)
+
+// DefaultStrategy is used when none is configured.
+const DefaultStrategy = StrategyDedupe
+
+// BytesPerToken is a rough estimate used to turn token budgets into bytes.
+const BytesPerToken = 4
+
+// headShare is the part of the budget head+tail truncation gives to the
+// beginning of the input; the rest goes to the end, where logs keep the
+// most recent lines.
+const headShare = 0.4
+
+// ParseStrategy validates a strategy name. An empty name yields
+// DefaultStrategy.
+func ParseStrategy(name string) (Strategy, error) {
+	switch s := Strategy(name); s {
+	case "":
+		return DefaultStrategy, nil
+	case StrategyHeadTail, StrategySample, StrategyDedupe:
+		return s, nil
+	}
+	return "", fmt.Errorf("unsupported strategy: %s (supported: head-tail, sample, dedupe)", name)
+}
+
+// Input is piped input as read by Read.
+type Input struct {
+	Text  string
+	Bytes int // total size, including anything Read left out
+}
+
+// Read reads all of r. Input larger than limit keeps its first and last
+// limit/2 bytes, so memory stays bounded however much is piped in.
+func Read(r io.Reader, limit int) (Input, error) {
+	half := limit / 2
+	var head, tail []byte
+	total := 0
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		chunk := buf[:n]
+		total += n
+		if room := half - len(head); room > 0 {
+			take := min(room, len(chunk))
+			head = append(head, chunk[:take]...)
+			chunk = chunk[take:]
+		}
+		if len(chunk) > 0 {
+			tail = append(tail, chunk...)
+			if len(tail) > 2*limit {
+				tail = append(tail[:0], tail[len(tail)-half:]...)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Input{}, err
+		}
+	}
+
+	if total <= limit {
+		return Input{Text: string(head) + string(tail), Bytes: total}, nil
+	}
+	tail = tail[len(tail)-min(half, len(tail)):]
+	omitted := total - len(head) - len(tail)
+	// Drop the partial lines at the cut.
+	if i := bytes.LastIndexByte(head, '\n'); i >= 0 {
+		omitted += len(head) - i - 1
+		head = head[:i+1]
+	}
+	if i := bytes.IndexByte(tail, '\n'); i >= 0 {
+		omitted += i + 1
+		tail = tail[i+1:]
+	}
+	return Input{Text: string(head) + omittedMarker(omitted) + "\n" + string(tail), Bytes: total}, nil
+}
+
+// Result is the input as it is sent to the LLM.
+type Result struct {
+	Text     string
+	Strategy Strategy
+	Bytes    int  // size of the original input
+	Reduced  bool // whether a strategy had to cut the input
+	// Format is set when structured input was replaced by its schema.
+	Format structured.Format
+}
+
+// Summary describes how much of the input is sent, e.g.
+// "sent 32.0KB of 2.1MB (dedupe)".
+func (r Result) Summary() string {
+	switch {
+	case !r.Reduced:
+		return fmt.Sprintf("sent all %s", formatSize(r.Bytes))
+	case r.Format != "":
+		return fmt.Sprintf("sent %s of %s (%s schema)", formatSize(len(r.Text)), formatSize(r.Bytes), r.Format)
+	}
+	return fmt.Sprintf("sent %s of %s (%s)", formatSize(len(r.Text)), formatSize(r.Bytes), r.Strategy)
+}
+
+// Reduce fits the input into budget bytes. Structured input (JSON, YAML,
+// CSV, tables) is replaced by a summary of its schema; anything else is
+// cut with the given strategy. Input that already fits is only trimmed of
+// surrounding whitespace.
+func Reduce(in Input, strategy Strategy, budget int) Result {
+	text := strings.TrimSpace(in.Text)
+	complete := len(in.Text) == in.Bytes
+	res := Result{Text: text, Strategy: strategy, Bytes: in.Bytes}
+	if len(text) <= budget && complete {
+		return res
+	}
+
+	res.Reduced = true
+	if complete {
+		if schema, ok := structured.Detect(text); ok {
+			res.Format = schema.Format
+			res.Text = headTail(schema.String(), budget)
+			return res
+		}
+	}
+	switch strategy {
+	case StrategySample:
+		res.Text = sample(text, budget)
+	case StrategyDedupe:
+		res.Text = headTail(dedupe(text), budget)
+	default:
+		res.Text = headTail(text, budget)
+	}
+	return res
+}
+
+// headTail keeps the beginning and the end of s, cut at line boundaries
+// where possible, with a marker for what was left out.
+func headTail(s string, budget int) string {
+	if len(s) <= budget {
+		return s
+	}
+	headLen := int(float64(budget) * headShare)
+	tailLen := budget - headLen
+
+	head := cut(s, headLen)
+	if i := strings.LastIndexByte(head, '\n'); i > 0 {
+		head = head[:i]
+	}
+	tail := s[len(s)-tailLen:]
+	for len(tail) > 0 && !utf8.RuneStart(tail[0]) {
+		tail = tail[1:]
+	}
+	if i := strings.IndexByte(tail, '\n'); i >= 0 && i < len(tail)-1 {
+		tail = tail[i+1:]
+	}
+
+	omitted := len(s) - len(head) - len(tail)
+	return head + "\n" + omittedMarker(omitted) + "\n" + tail
+}
+
+// volatileRe matches the parts of log lines that change between otherwise
+// identical lines: timestamps, counters, hex ids and addresses.
+var volatileRe = regexp.MustCompile(`0x[0-9a-fA-F]+|\b[0-9a-fA-F]*[0-9][0-9a-fA-F]*\b|[0-9]+`)
+
+// dedupe keeps the first of each group of lines that only differ in
+// volatile parts, annotated with how often the group occurred.
+func dedupe(s string) string {
+	lines := strings.Split(s, "\n")
+	counts := make(map[string]int, len(lines))
+	var order []string
+	first := make(map[string]string, len(lines))
+	for _, line := range lines {
+		key := volatileRe.ReplaceAllString(line, "#")
+		if counts[key] == 0 {
+			order = append(order, key)
+			first[key] = line
+		}
+		counts[key]++
+	}
+	if len(order) == len(lines) {
+		return s
+	}
+
+	out := make([]string, 0, len(order))
+	for _, key := range order {
+		line := first[key]
+		if n := counts[key]; n > 1 {
+			line += fmt.Sprintf(" [repeated %d times]", n)
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+// sample keeps the first line, often a header, and evenly spaced unique
+// lines after it.
+func sample(s string, budget int) string {
+	lines := uniqueLines(strings.Split(s, "\n"))
+	if len(lines) < 2 {
+		return headTail(strings.Join(lines, "\n"), budget)
+	}
+	joined := strings.Join(lines, "\n")
+	if len(joined) <= budget {
+		return joined
+	}
+
+	step := (len(joined) + budget - 1) / budget
+	kept := []string{lines[0]}
+	size := len(lines[0])
+	for i := step; i < len(lines); i += step {
+		if size+len(lines[i])+1 > budget {
+			break
+		}
+		kept = append(kept, lines[i])
+		size += len(lines[i]) + 1
+	}
+	note := fmt.Sprintf("[sampled %d of %d unique lines]", len(kept), len(lines))
+	return note + "\n" + strings.Join(kept, "\n")
+}
+
+func uniqueLines(lines []string) []string {
+	seen := make(map[string]bool, len(lines))
+	var out []string
+	for _, line := range lines {
+		if !seen[line] {
+			seen[line] = true
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+func omittedMarker(n int) string {
+	return fmt.Sprintf("[... %s omitted ...]", formatSize(n))
+}
+
+// cut shortens s to at most n bytes without splitting a UTF-8 sequence.
+func cut(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+func formatSize(n int) string {
+	switch {
+	case n < 1024:
+		return fmt.Sprintf("%dB", n)
+	case n < 1024*1024:
+		return fmt.Sprintf("%.1fKB", float64(n)/1024)
+	}
+	return fmt.Sprintf("%.1fMB", float64(n)/(1024*1024))
+}
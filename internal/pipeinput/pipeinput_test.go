@@ -0,0 +1,167 @@
+package pipeinput
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseStrategy(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Strategy
+		wantErr bool
+	}{
+		{"", DefaultStrategy, false},
+		{"head-tail", StrategyHeadTail, false},
+		{"sample", StrategySample, false},
+		{"dedupe", StrategyDedupe, false},
+		{"summarize", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseStrategy(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseStrategy(%q) = %q, %v, want %q, error %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestRead_KeepsHeadAndTail(t *testing.T) {
+	var lines []string
+	for i := range 1000 {
+		lines = append(lines, fmt.Sprintf("line %04d", i))
+	}
+	data := strings.Join(lines, "\n") + "\n"
+
+	in, err := Read(strings.NewReader(data), 1000)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if in.Bytes != len(data) {
+		t.Errorf("Bytes = %d, want %d", in.Bytes, len(data))
+	}
+	if !strings.HasPrefix(in.Text, "line 0000\n") || !strings.HasSuffix(in.Text, "line 0999\n") {
+		t.Errorf("Text should keep the first and last lines, got %q...", in.Text[:20])
+	}
+	if !strings.Contains(in.Text, "omitted ...]\nline 09") {
+		t.Errorf("Text should mark the omitted middle at a line boundary")
+	}
+	if len(in.Text) > 1100 {
+		t.Errorf("len(Text) = %d, want about the limit", len(in.Text))
+	}
+}
+
+func TestRead_SmallInput(t *testing.T) {
+	in, err := Read(strings.NewReader("a\nb\n"), 1000)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if in.Text != "a\nb\n" || in.Bytes != 4 {
+		t.Errorf("Read() = %+v", in)
+	}
+}
+
+func TestReduce_FitsBudget(t *testing.T) {
+	res := Reduce(Input{Text: "  ls output\n", Bytes: 12}, StrategyHeadTail, 100)
+	if res.Reduced || res.Text != "ls output" {
+		t.Errorf("Reduce() = %+v, want input unchanged", res)
+	}
+	if res.Summary() != "sent all 12B" {
+		t.Errorf("Summary() = %q", res.Summary())
+	}
+}
+
+func TestReduce_HeadTail(t *testing.T) {
+	var lines []string
+	for i := range 100 {
+		lines = append(lines, fmt.Sprintf("line %03d", i))
+	}
+	text := strings.Join(lines, "\n")
+
+	res := Reduce(Input{Text: text, Bytes: len(text)}, StrategyHeadTail, 200)
+	if !res.Reduced {
+		t.Fatal("Reduced = false, want true")
+	}
+	if !strings.HasPrefix(res.Text, "line 000\n") || !strings.HasSuffix(res.Text, "line 099") {
+		t.Errorf("Text = %q, want head and tail", res.Text)
+	}
+	if !strings.Contains(res.Text, "omitted ...]") {
+		t.Errorf("Text = %q, want omitted marker", res.Text)
+	}
+	if len(res.Text) > 240 {
+		t.Errorf("len(Text) = %d, want about the budget", len(res.Text))
+	}
+	if !strings.HasPrefix(res.Summary(), "sent ") || !strings.HasSuffix(res.Summary(), "of 899B (head-tail)") {
+		t.Errorf("Summary() = %q", res.Summary())
+	}
+}
+
+func TestReduce_HeadTailSingleLine(t *testing.T) {
+	text := strings.Repeat("é", 500)
+	res := Reduce(Input{Text: text, Bytes: len(text)}, StrategyHeadTail, 100)
+	if !strings.Contains(res.Text, "omitted") || strings.ContainsRune(res.Text, '�') {
+		t.Errorf("Text = %q, want runes kept whole around the marker", res.Text)
+	}
+}
+
+func TestReduce_Dedupe(t *testing.T) {
+	var lines []string
+	for i := range 200 {
+		lines = append(lines, fmt.Sprintf("2024-05-01T10:%02d:%02d kubelet[1234]: probe failed for pod web-%x", i/60, i%60, 0x1a2b0+i))
+	}
+	lines = append(lines, "2024-05-01T10:04:00 kubelet[1234]: OOMKilled container api")
+	text := strings.Join(lines, "\n")
+
+	res := Reduce(Input{Text: text, Bytes: len(text)}, StrategyDedupe, 1000)
+	want := lines[0] + " [repeated 200 times]\n" + lines[200]
+	if res.Text != want {
+		t.Errorf("Text = %q, want %q", res.Text, want)
+	}
+}
+
+func TestReduce_Sample(t *testing.T) {
+	lines := []string{"running processes"}
+	for i := range 300 {
+		lines = append(lines, fmt.Sprintf("%d proc-%d", i, i))
+	}
+	lines = append(lines, lines[1:50]...)
+	text := strings.Join(lines, "\n")
+
+	res := Reduce(Input{Text: text, Bytes: len(text)}, StrategySample, 500)
+	got := strings.Split(res.Text, "\n")
+	if !strings.HasPrefix(got[0], "[sampled ") || !strings.HasSuffix(got[0], " of 301 unique lines]") {
+		t.Errorf("first line = %q, want sampling note", got[0])
+	}
+	if got[1] != "running processes" {
+		t.Errorf("header = %q, want first line kept", got[1])
+	}
+	if len(res.Text) > 550 {
+		t.Errorf("len(Text) = %d, want about the budget", len(res.Text))
+	}
+}
+
+func TestReduce_InputCutWhileReading(t *testing.T) {
+	res := Reduce(Input{Text: "head\n[... 1.0MB omitted ...]\ntail", Bytes: 1 << 20}, StrategyHeadTail, 1000)
+	if !res.Reduced {
+		t.Error("Reduced = false, want true when Read left out part of the input")
+	}
+}
+
+func TestReduce_StructuredInputSendsSchema(t *testing.T) {
+	var rows []string
+	for i := range 500 {
+		rows = append(rows, fmt.Sprintf(`{"name": "pod-%d", "phase": "Running"}`, i))
+	}
+	text := `{"items": [` + strings.Join(rows, ",") + `]}`
+
+	res := Reduce(Input{Text: text, Bytes: len(text)}, StrategyDedupe, 1000)
+	if res.Format != "JSON" {
+		t.Fatalf("Format = %q, want JSON", res.Format)
+	}
+	if !strings.Contains(res.Text, `.items[].name: string (e.g. "pod-0")`) {
+		t.Errorf("Text = %q, want schema summary", res.Text)
+	}
+	if !strings.HasSuffix(res.Summary(), "(JSON schema)") {
+		t.Errorf("Summary() = %q", res.Summary())
+	}
+}
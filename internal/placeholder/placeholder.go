@@ -0,0 +1,221 @@
+// Package placeholder finds typed placeholders such as {{bucket:string}}
+// in generated commands and substitutes user-provided values for them,
+// quoted for the target shell.
+package placeholder
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/evgfitil/qx/internal/llm"
+)
+
+// Type is the kind of value a placeholder stands for.
+type Type string
+
+// Supported placeholder types. A placeholder without a type, or with an
+// unknown one, is a TypeString.
+const (
+	TypeString Type = "string"
+	TypePath   Type = "path"
+	TypeNumber Type = "number"
+)
+
+// Placeholder is a value the LLM could not know, e.g. {{file:path}}.
+type Placeholder struct {
+	Name string
+	Type Type
+}
+
+// placeholderRe matches {{name}} and {{name:type}}.
+var placeholderRe = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_-]*)\s*(?::\s*([A-Za-z]+)\s*)?\}\}`)
+
+// Has reports whether command contains a placeholder.
+func Has(command string) bool {
+	return placeholderRe.MatchString(command)
+}
+
+// Parse returns the placeholders in command in order of first appearance.
+// A name used more than once is returned once, with its first type.
+func Parse(command string) []Placeholder {
+	var result []Placeholder
+	seen := make(map[string]bool)
+	for _, m := range placeholderRe.FindAllStringSubmatch(command, -1) {
+		if seen[m[1]] {
+			continue
+		}
+		seen[m[1]] = true
+		result = append(result, Placeholder{Name: m[1], Type: parseType(m[2])})
+	}
+	return result
+}
+
+func parseType(name string) Type {
+	switch t := Type(strings.ToLower(name)); t {
+	case TypePath, TypeNumber:
+		return t
+	}
+	return TypeString
+}
+
+// Validate checks value for placeholder p: every value must be non-empty
+// and numbers must parse as one.
+func Validate(p Placeholder, value string) error {
+	if value == "" {
+		return fmt.Errorf("%s is required", p.Name)
+	}
+	if p.Type == TypeNumber {
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("%s must be a number", p.Name)
+		}
+	}
+	return nil
+}
+
+// quoteState is the quoting context at a position in a command.
+type quoteState int
+
+const (
+	unquoted quoteState = iota
+	inSingle
+	inDouble
+)
+
+// nesting is the quoting context inside a command substitution, subshell
+// or backticks, which starts unquoted whatever surrounds it, with the
+// character that ends it.
+type nesting struct {
+	state  quoteState
+	closer byte
+}
+
+// Substitute replaces every placeholder in command with its value from
+// values, quoted so that the shell sees exactly the value: as a separate
+// quoted word outside quotes and escaped inside quotes. Command
+// substitutions such as "$(du {{dir}})" are followed, so a placeholder in
+// them is quoted as a word too. Placeholders without a value are left as
+// they are.
+func Substitute(command string, values map[string]string, shell llm.Shell) string {
+	var b strings.Builder
+	outer := []nesting{}
+	cur := nesting{state: unquoted}
+	enter := func(closer byte) {
+		outer = append(outer, cur)
+		cur = nesting{state: unquoted, closer: closer}
+	}
+	leave := func() {
+		cur = outer[len(outer)-1]
+		outer = outer[:len(outer)-1]
+	}
+	inBackticks := func() bool {
+		return cur.closer == '`' || slices.ContainsFunc(outer, func(n nesting) bool { return n.closer == '`' })
+	}
+
+	for i := 0; i < len(command); {
+		if loc := placeholderRe.FindStringSubmatchIndex(command[i:]); loc != nil && loc[0] == 0 {
+			match := command[i : i+loc[1]]
+			name := command[i+loc[2] : i+loc[3]]
+			typ := TypeString
+			if loc[4] >= 0 {
+				typ = parseType(command[i+loc[4] : i+loc[5]])
+			}
+			value, ok := values[name]
+			var quoted string
+			switch {
+			case !ok:
+				quoted = match
+			case cur.state == inSingle:
+				quoted = escapeSingle(value, shell)
+			case cur.state == inDouble:
+				quoted = escapeDouble(value, shell)
+			default:
+				quoted = quoteWord(value, typ, shell)
+			}
+			if ok && inBackticks() {
+				// the shell removes a level of backslashes inside
+				// backticks and ends them at the first unescaped one
+				quoted = strings.NewReplacer(`\`, `\\`, "`", "\\`").Replace(quoted)
+			}
+			b.WriteString(quoted)
+			i += loc[1]
+			continue
+		}
+
+		c := command[i]
+		b.WriteByte(c)
+		i++
+		switch {
+		case c == '\\' && i < len(command) && (cur.state != inSingle || shell == llm.ShellFish):
+			// An escaped character never changes the quoting state; fish
+			// also honours \' and \\ inside single quotes.
+			b.WriteByte(command[i])
+			i++
+		case c == '\'' && cur.state == unquoted:
+			cur.state = inSingle
+		case c == '\'' && cur.state == inSingle:
+			cur.state = unquoted
+		case c == '"' && cur.state == unquoted:
+			cur.state = inDouble
+		case c == '"' && cur.state == inDouble:
+			cur.state = unquoted
+		case c == '(' && (cur.state == unquoted || cur.state == inDouble && i >= 2 && command[i-2] == '$'):
+			// $(...) and, in fish, (...) substitute a command; a subshell
+			// or $((...)) nests the same way.
+			enter(')')
+		case c == ')' && cur.state == unquoted && cur.closer == ')':
+			leave()
+		case c == '`' && shell != llm.ShellFish && inBackticks():
+			// the first unescaped backtick ends them, whatever is open
+			for cur.closer != '`' {
+				leave()
+			}
+			leave()
+		case c == '`' && cur.state != inSingle && shell != llm.ShellFish:
+			enter('`')
+		}
+	}
+	return b.String()
+}
+
+// safeWordRe matches values that need no quoting in any supported shell.
+var safeWordRe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// quoteWord quotes value as a single shell word. A leading ~/ of a path
+// stays unquoted so the shell still expands it to the home directory.
+func quoteWord(value string, typ Type, shell llm.Shell) string {
+	if typ == TypePath && (value == "~" || strings.HasPrefix(value, "~/")) {
+		if rest := strings.TrimPrefix(value[1:], "/"); rest != "" {
+			return "~/" + quoteWord(rest, TypeString, shell)
+		}
+		return value
+	}
+	if safeWordRe.MatchString(value) {
+		return value
+	}
+	return "'" + escapeSingle(value, shell) + "'"
+}
+
+// escapeSingle escapes the characters that end or are special inside
+// single quotes.
+func escapeSingle(value string, shell llm.Shell) string {
+	if shell == llm.ShellFish {
+		return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+	}
+	return strings.ReplaceAll(value, "'", `'\''`)
+}
+
+// escapeDouble escapes the characters that are special inside double
+// quotes. Bash and zsh expand history on ! even there, so such values
+// leave the double quotes for single-quoted text instead.
+func escapeDouble(value string, shell llm.Shell) string {
+	if shell == llm.ShellFish {
+		return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`).Replace(value)
+	}
+	if strings.Contains(value, "!") {
+		return `"'` + escapeSingle(value, shell) + `'"`
+	}
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "`", "\\`").Replace(value)
+}
@@ -0,0 +1,119 @@
+package placeholder
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/evgfitil/qx/internal/llm"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		want    []Placeholder
+	}{
+		{"none", "ls -la", nil},
+		{"typed", "aws s3 cp {{file:path}} s3://{{bucket:string}}/", []Placeholder{{"file", TypePath}, {"bucket", TypeString}}},
+		{"untyped and unknown type", "kill -{{signal}} {{pid:int}}", []Placeholder{{"signal", TypeString}, {"pid", TypeString}}},
+		{"number with spaces", "head -n {{ lines : number }} log.txt", []Placeholder{{"lines", TypeNumber}}},
+		{"repeated name", "cp {{src:path}} {{src:path}}.bak", []Placeholder{{"src", TypePath}}},
+		{"not a placeholder", "echo '{{ }}' {{1abc}}", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse(tt.command); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %v, want %v", tt.command, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		p       Placeholder
+		value   string
+		wantErr bool
+	}{
+		{"string", Placeholder{"bucket", TypeString}, "my bucket", false},
+		{"empty", Placeholder{"bucket", TypeString}, "", true},
+		{"number", Placeholder{"lines", TypeNumber}, "-2.5", false},
+		{"not a number", Placeholder{"lines", TypeNumber}, "ten", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.p, tt.value); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSubstitute(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		values  map[string]string
+		shell   llm.Shell
+		want    string
+	}{
+		{"safe word", "aws s3 ls s3://{{bucket:string}}/", map[string]string{"bucket": "logs-2024"}, llm.ShellBash, "aws s3 ls s3://logs-2024/"},
+		{"spaces", "rm {{file:path}}", map[string]string{"file": "my file.txt"}, llm.ShellBash, "rm 'my file.txt'"},
+		{"single quote", "echo {{msg}}", map[string]string{"msg": "it's"}, llm.ShellPOSIX, `echo 'it'\''s'`},
+		{"command substitution stays literal", "echo {{msg}}", map[string]string{"msg": "$(rm -rf ~)"}, llm.ShellZsh, "echo '$(rm -rf ~)'"},
+		{"home directory", "ls {{dir:path}}", map[string]string{"dir": "~/My Documents"}, llm.ShellBash, "ls ~/'My Documents'"},
+		{"home directory is not kept for strings", "echo {{s}}", map[string]string{"s": "~/x y"}, llm.ShellBash, "echo '~/x y'"},
+		{"inside double quotes", `grep "{{pattern}}" log`, map[string]string{"pattern": `say "$HOME"`}, llm.ShellBash, `grep "say \"\$HOME\"" log`},
+		{"history expansion inside double quotes", `git commit -m "{{msg}} now"`, map[string]string{"msg": `fix it!`}, llm.ShellBash, `git commit -m ""'fix it!'" now"`},
+		{"history expansion outside quotes", "echo {{msg}}", map[string]string{"msg": "hi!"}, llm.ShellZsh, "echo 'hi!'"},
+		{"no history expansion in fish", `echo "{{msg}}"`, map[string]string{"msg": "hi!"}, llm.ShellFish, `echo "hi!"`},
+		{"inside single quotes", `awk '/{{pattern}}/'`, map[string]string{"pattern": "a b"}, llm.ShellBash, `awk '/a b/'`},
+		{"after escaped quote", `echo \"{{s}}`, map[string]string{"s": "a b"}, llm.ShellBash, `echo \"'a b'`},
+		{"fish quoting", "echo {{msg}}", map[string]string{"msg": `it's a \ test`}, llm.ShellFish, `echo 'it\'s a \\ test'`},
+		{"repeated", "cp {{src:path}} {{src:path}}.bak", map[string]string{"src": "a b"}, llm.ShellBash, "cp 'a b' 'a b'.bak"},
+		{"inside command substitution", `echo "size: $(du -sh {{dir:path}})"`, map[string]string{"dir": "My Docs; touch /tmp/pwn"}, llm.ShellBash, `echo "size: $(du -sh 'My Docs; touch /tmp/pwn')"`},
+		{"after command substitution", `echo "$(pwd) {{s}}"`, map[string]string{"s": "a b"}, llm.ShellBash, `echo "$(pwd) a b"`},
+		{"nested parentheses", `echo $(( $(wc -l < {{f:path}}) + 1 )) "{{f:path}}"`, map[string]string{"f": "a b"}, llm.ShellBash, `echo $(( $(wc -l < 'a b') + 1 )) "a b"`},
+		{"inside backticks", "echo \"`cat {{f:path}}`\"", map[string]string{"f": "a`b"}, llm.ShellBash, "echo \"`cat 'a\\`b'`\""},
+		{"fish command substitution", `echo "(ls {{dir}})" (ls {{dir}})`, map[string]string{"dir": "a b"}, llm.ShellFish, `echo "(ls a b)" (ls 'a b')`},
+		{"missing value", "scp {{file:path}} {{host}}:", map[string]string{"file": "x"}, llm.ShellBash, "scp x {{host}}:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Substitute(tt.command, tt.values, tt.shell); got != tt.want {
+				t.Errorf("Substitute() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubstitute_ShellSeesValue(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	values := []string{`it's`, `a "b" $c`, `wow! it's !!`, "`id`", `back\slash`, "two\nlines", "$(echo no)"}
+	for _, v := range values {
+		for _, command := range []string{
+			`printf %s {{v}}`, `printf %s "{{v}}"`, `printf %s '{{v}}'`,
+			`printf %s "$(printf %s {{v}})"`, "printf %s \"`printf %s {{v}}`\"",
+		} {
+			script := Substitute(command, map[string]string{"v": v}, llm.ShellPOSIX)
+			out, err := exec.Command("sh", "-c", script).Output()
+			if err != nil {
+				t.Fatalf("sh -c %q: %v", script, err)
+			}
+			if got := string(out); got != v {
+				t.Errorf("sh -c %q printed %q, want %q", script, got, v)
+			}
+		}
+	}
+}
+
+func TestHas(t *testing.T) {
+	if !Has("ls {{dir:path}}") || Has("ls -la") || Has(strings.Repeat("{", 4)) {
+		t.Error("Has() should only report commands with placeholders")
+	}
+}
@@ -0,0 +1,100 @@
+package placeholder
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+const (
+	fileName = "placeholders.json"
+	// maxValues caps the values remembered per placeholder name.
+	maxValues = 10
+)
+
+// Store remembers the values entered for placeholders, by name, so they
+// can be offered again.
+type Store struct {
+	filePath string
+}
+
+// NewStore creates a Store that persists values in the given directory.
+func NewStore(dir string) *Store {
+	return &Store{filePath: filepath.Join(dir, fileName)}
+}
+
+// Recent returns the values remembered for each name, newest first.
+func (s *Store) Recent() (map[string][]string, error) {
+	values, err := s.readAll()
+	if err != nil {
+		return nil, err
+	}
+	if values == nil {
+		values = map[string][]string{}
+	}
+	return values, nil
+}
+
+// Add remembers values, moving repeated ones to the front and keeping at
+// most maxValues per name.
+func (s *Store) Add(values map[string]string) error {
+	all, err := s.Recent()
+	if err != nil {
+		return err
+	}
+	for name, value := range values {
+		recent := slices.DeleteFunc(all[name], func(v string) bool { return v == value })
+		recent = append([]string{value}, recent...)
+		if len(recent) > maxValues {
+			recent = recent[:maxValues]
+		}
+		all[name] = recent
+	}
+	return s.writeAll(all)
+}
+
+func (s *Store) readAll() (map[string][]string, error) {
+	data, err := os.ReadFile(s.filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading placeholder values: %w", err)
+	}
+
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var values map[string][]string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("parsing placeholder values: %w", err)
+	}
+	return values, nil
+}
+
+func (s *Store) writeAll(values map[string][]string) error {
+	data, err := json.MarshalIndent(values, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling placeholder values: %w", err)
+	}
+
+	dir := filepath.Dir(s.filePath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("creating placeholder directory: %w", err)
+	}
+
+	tmp := s.filePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("writing placeholder temp file: %w", err)
+	}
+
+	if err := os.Rename(tmp, s.filePath); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("renaming placeholder temp file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package placeholder
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	s := NewStore(t.TempDir())
+
+	recent, err := s.Recent()
+	if err != nil || len(recent) != 0 {
+		t.Fatalf("Recent() on a new store = %v, %v, want empty", recent, err)
+	}
+
+	for _, bucket := range []string{"logs", "backups", "logs"} {
+		if err := s.Add(map[string]string{"bucket": bucket}); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+	if err := s.Add(map[string]string{"file": "/tmp/a"}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	recent, err = s.Recent()
+	if err != nil {
+		t.Fatalf("Recent() error = %v", err)
+	}
+	want := map[string][]string{"bucket": {"logs", "backups"}, "file": {"/tmp/a"}}
+	if !reflect.DeepEqual(recent, want) {
+		t.Errorf("Recent() = %v, want %v", recent, want)
+	}
+}
+
+func TestStore_KeepsMaxValues(t *testing.T) {
+	s := NewStore(t.TempDir())
+	for i := range maxValues + 5 {
+		if err := s.Add(map[string]string{"n": fmt.Sprint(i)}); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+
+	recent, _ := s.Recent()
+	if len(recent["n"]) != maxValues || recent["n"][0] != fmt.Sprint(maxValues+4) {
+		t.Errorf("Recent() = %v, want the newest %d values", recent["n"], maxValues)
+	}
+}
@@ -0,0 +1,213 @@
+// Package rank orders generated command variants by a score that combines
+// the model's confidence with local signals: installed binaries, risk,
+// pipe count and commands the user picked before.
+package rank
+
+import (
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+
+	"github.com/evgfitil/qx/internal/guard"
+	"github.com/evgfitil/qx/internal/llm"
+	"github.com/evgfitil/qx/pkg/qx"
+)
+
+// Weights of the signals in the total score; they add up to 1.
+const (
+	weightConfidence  = 0.4
+	weightInstalled   = 0.25
+	weightRisk        = 0.15
+	weightPipes       = 0.1
+	weightFamiliarity = 0.1
+)
+
+// builtins are commands the shell runs itself, so they are not looked up
+// on $PATH.
+var builtins = []string{
+	".", ":", "[", "alias", "bg", "bind", "break", "builtin", "cd", "command",
+	"continue", "declare", "dirs", "disown", "echo", "eval", "exec", "exit",
+	"export", "false", "fg", "getopts", "hash", "help", "history", "jobs",
+	"kill", "let", "local", "mapfile", "popd", "printf", "pushd", "pwd", "read",
+	"readarray", "readonly", "return", "set", "shift", "shopt", "source",
+	"test", "trap", "true", "type", "typeset", "ulimit", "umask", "unalias",
+	"unset", "wait",
+	// fish
+	"abbr", "and", "argparse", "contains", "count", "functions", "math", "not",
+	"or", "status", "string",
+}
+
+// Options configures the local signals.
+type Options struct {
+	// Shell is the dialect of the commands.
+	Shell llm.Shell
+	// LookPath finds a binary on $PATH; exec.LookPath is used when nil.
+	LookPath func(file string) (string, error)
+	// Accepted holds commands the user picked before, e.g. from history.
+	Accepted []string
+}
+
+// Score is the total score of a command with the signals behind it.
+type Score struct {
+	// Total is between 0 and 1; higher is better.
+	Total      float64
+	Confidence float64
+	// Missing lists the binaries that are not on $PATH.
+	Missing []string
+	Risk    guard.RiskLevel
+	Pipes   int
+	// Familiarity is 1 for a command the user picked before, otherwise
+	// half the share of its binaries used in commands picked before.
+	Familiarity float64
+}
+
+// String describes s in one line, e.g.
+// "score 0.82: confidence 0.90, risk low, 1 pipe, used before".
+func (s Score) String() string {
+	parts := []string{
+		fmt.Sprintf("confidence %.2f", s.Confidence),
+		"risk " + s.Risk.String(),
+	}
+	switch s.Pipes {
+	case 0:
+	case 1:
+		parts = append(parts, "1 pipe")
+	default:
+		parts = append(parts, fmt.Sprintf("%d pipes", s.Pipes))
+	}
+	if len(s.Missing) > 0 {
+		parts = append(parts, "not installed: "+strings.Join(s.Missing, ", "))
+	}
+	switch {
+	case s.Familiarity >= 1:
+		parts = append(parts, "used before")
+	case s.Familiarity > 0:
+		parts = append(parts, "familiar tools")
+	}
+	return fmt.Sprintf("score %.2f: %s", s.Total, strings.Join(parts, ", "))
+}
+
+// Variant is a command with its score.
+type Variant struct {
+	Command string
+	Score   Score
+}
+
+// Rank scores variants and returns them best first. Variants with the
+// same score keep the order the model returned them in.
+func Rank(variants []qx.Variant, opts Options) []Variant {
+	if opts.LookPath == nil {
+		opts.LookPath = exec.LookPath
+	}
+	accepted := make(map[string]bool, len(opts.Accepted))
+	acceptedBinaries := make(map[string]bool)
+	for _, cmd := range opts.Accepted {
+		accepted[llm.NormalizeCommand(cmd, opts.Shell)] = true
+		for _, name := range binaries(cmd) {
+			acceptedBinaries[name] = true
+		}
+	}
+	found := make(map[string]bool)
+
+	ranked := make([]Variant, len(variants))
+	for i, v := range variants {
+		s := Score{
+			Confidence: v.Confidence,
+			Risk:       guard.ClassifyRisk(v.Command).Level,
+			Pipes:      pipes(v.Command),
+		}
+
+		names := binaries(v.Command)
+		familiar := 0
+		for _, name := range names {
+			ok, checked := found[name]
+			if !checked {
+				_, err := opts.LookPath(name)
+				ok = err == nil
+				found[name] = ok
+			}
+			if !ok {
+				s.Missing = append(s.Missing, name)
+			}
+			if acceptedBinaries[name] {
+				familiar++
+			}
+		}
+		if accepted[llm.NormalizeCommand(v.Command, opts.Shell)] {
+			s.Familiarity = 1
+		} else if len(names) > 0 {
+			s.Familiarity = 0.5 * float64(familiar) / float64(len(names))
+		}
+
+		installed := 1.0
+		if len(names) > 0 {
+			installed = float64(len(names)-len(s.Missing)) / float64(len(names))
+		}
+		s.Total = weightConfidence*s.Confidence +
+			weightInstalled*installed +
+			weightRisk*(1-float64(s.Risk)/float64(guard.RiskHigh)) +
+			weightPipes/float64(1+s.Pipes) +
+			weightFamiliarity*s.Familiarity
+		ranked[i] = Variant{Command: v.Command, Score: s}
+	}
+
+	slices.SortStableFunc(ranked, func(a, b Variant) int {
+		switch {
+		case a.Score.Total > b.Score.Total:
+			return -1
+		case a.Score.Total < b.Score.Total:
+			return 1
+		}
+		return 0
+	})
+	return ranked
+}
+
+// parse parses cmd with the bash grammar; commands that do not parse,
+// such as most fish syntax, yield no signals from the syntax tree.
+func parse(cmd string) (*syntax.File, bool) {
+	file, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(cmd), "")
+	return file, err == nil
+}
+
+// binaries returns the distinct external commands cmd runs, in order.
+// Builtins, placeholders and names built from expansions are left out.
+func binaries(cmd string) []string {
+	file, ok := parse(cmd)
+	if !ok {
+		return nil
+	}
+	var names []string
+	syntax.Walk(file, func(node syntax.Node) bool {
+		call, ok := node.(*syntax.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		name := call.Args[0].Lit()
+		if name != "" && !strings.Contains(name, "{{") && !slices.Contains(builtins, name) && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+		return true
+	})
+	return names
+}
+
+// pipes counts the pipes in cmd, including those in command
+// substitutions and subshells.
+func pipes(cmd string) int {
+	file, ok := parse(cmd)
+	if !ok {
+		return 0
+	}
+	n := 0
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if bc, ok := node.(*syntax.BinaryCmd); ok && (bc.Op == syntax.Pipe || bc.Op == syntax.PipeAll) {
+			n++
+		}
+		return true
+	})
+	return n
+}
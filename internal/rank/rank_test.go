@@ -0,0 +1,148 @@
+package rank
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/evgfitil/qx/internal/guard"
+	"github.com/evgfitil/qx/pkg/qx"
+)
+
+// lookPathOf finds only the binaries in installed.
+func lookPathOf(installed ...string) func(string) (string, error) {
+	return func(name string) (string, error) {
+		for _, n := range installed {
+			if n == name {
+				return "/usr/bin/" + name, nil
+			}
+		}
+		return "", errors.New("not found")
+	}
+}
+
+func commands(ranked []Variant) []string {
+	result := make([]string, len(ranked))
+	for i, v := range ranked {
+		result[i] = v.Command
+	}
+	return result
+}
+
+func TestRank(t *testing.T) {
+	tests := []struct {
+		name     string
+		variants []qx.Variant
+		opts     Options
+		want     []string
+	}{
+		{
+			name: "higher confidence first",
+			variants: []qx.Variant{
+				{Command: "ls -la", Confidence: 0.4},
+				{Command: "ls -l", Confidence: 0.9},
+			},
+			opts: Options{LookPath: lookPathOf("ls")},
+			want: []string{"ls -l", "ls -la"},
+		},
+		{
+			name: "missing binary ranks lower",
+			variants: []qx.Variant{
+				{Command: "rg TODO", Confidence: 0.8},
+				{Command: "grep -r TODO .", Confidence: 0.7},
+			},
+			opts: Options{LookPath: lookPathOf("grep")},
+			want: []string{"grep -r TODO .", "rg TODO"},
+		},
+		{
+			name: "risky command ranks lower",
+			variants: []qx.Variant{
+				{Command: "rm -rf build", Confidence: 0.7},
+				{Command: "ls build", Confidence: 0.7},
+			},
+			opts: Options{LookPath: lookPathOf("rm", "ls")},
+			want: []string{"ls build", "rm -rf build"},
+		},
+		{
+			name: "fewer pipes first",
+			variants: []qx.Variant{
+				{Command: "ps aux | grep nginx | wc -l", Confidence: 0.7},
+				{Command: "pgrep -c nginx", Confidence: 0.7},
+			},
+			opts: Options{LookPath: lookPathOf("ps", "grep", "wc", "pgrep")},
+			want: []string{"pgrep -c nginx", "ps aux | grep nginx | wc -l"},
+		},
+		{
+			name: "command picked before first",
+			variants: []qx.Variant{
+				{Command: "du -sh *", Confidence: 0.7},
+				{Command: "ncdu", Confidence: 0.7},
+			},
+			opts: Options{LookPath: lookPathOf("du", "ncdu"), Accepted: []string{"ncdu"}},
+			want: []string{"ncdu", "du -sh *"},
+		},
+		{
+			name: "equal scores keep the model order",
+			variants: []qx.Variant{
+				{Command: "ls", Confidence: 0.5},
+				{Command: "pwd", Confidence: 0.5},
+			},
+			opts: Options{LookPath: lookPathOf("ls")},
+			want: []string{"ls", "pwd"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := commands(Rank(tt.variants, tt.opts))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Rank() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRank_Score(t *testing.T) {
+	ranked := Rank([]qx.Variant{{Command: "kubectl get pods | jq '.items[]' | grep web", Confidence: 0.8}}, Options{
+		LookPath: lookPathOf("kubectl", "grep"),
+		Accepted: []string{"kubectl get nodes"},
+	})
+	s := ranked[0].Score
+
+	if s.Pipes != 2 || s.Risk != guard.RiskLow || !reflect.DeepEqual(s.Missing, []string{"jq"}) {
+		t.Errorf("Score = %+v, want 2 pipes, low risk and jq missing", s)
+	}
+	if want := 0.5 * 1 / 3; s.Familiarity != want {
+		t.Errorf("Familiarity = %v, want %v", s.Familiarity, want)
+	}
+	if s.Total <= 0 || s.Total >= 1 {
+		t.Errorf("Total = %v, want between 0 and 1", s.Total)
+	}
+
+	str := s.String()
+	for _, want := range []string{"confidence 0.80", "risk low", "2 pipes", "not installed: jq", "familiar tools"} {
+		if !strings.Contains(str, want) {
+			t.Errorf("String() = %q, want it to contain %q", str, want)
+		}
+	}
+}
+
+func TestBinaries(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want []string
+	}{
+		{"ls -la | wc -l", []string{"ls", "wc"}},
+		{"cd /tmp && echo $(date) > out.txt", []string{"date"}},
+		{"FOO=1 make test", []string{"make"}},
+		{"$EDITOR file", nil},
+		{"{{tool:string}} --version", nil},
+		{"grep a f | grep b", []string{"grep"}},
+		{"echo $(", nil},
+	}
+	for _, tt := range tests {
+		if got := binaries(tt.cmd); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("binaries(%q) = %v, want %v", tt.cmd, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Package script turns reviewed script steps into an executable file.
+package script
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/evgfitil/qx/internal/guard"
+	"github.com/evgfitil/qx/internal/llm"
+	"github.com/evgfitil/qx/pkg/qx"
+)
+
+// Step is one step of a script under review.
+type Step struct {
+	Description string
+	Command     string
+	// Skip leaves the step out of runs and saved scripts.
+	Skip bool
+}
+
+// FromLLM converts generated steps into steps for review. Control
+// characters are removed from both fields and descriptions are kept to a
+// single line.
+func FromLLM(steps []qx.ScriptStep) []Step {
+	result := make([]Step, len(steps))
+	for i, s := range steps {
+		result[i] = Step{
+			Description: singleLine(guard.SanitizeOutput(s.Description)),
+			Command:     guard.SanitizeOutput(s.Command),
+		}
+	}
+	return result
+}
+
+// singleLine joins the lines of text with spaces.
+func singleLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// Active returns the steps that are not skipped, in order.
+func Active(steps []Step) []Step {
+	var active []Step
+	for _, s := range steps {
+		if !s.Skip {
+			active = append(active, s)
+		}
+	}
+	return active
+}
+
+// Header returns the shebang line for shell followed by the options that
+// make the script stop at the first failing step. Fish has no such
+// option, so fish scripts get the shebang alone.
+func Header(shell llm.Shell) string {
+	switch shell {
+	case llm.ShellBash:
+		return "#!/usr/bin/env bash\nset -euo pipefail\n"
+	case llm.ShellZsh:
+		return "#!/usr/bin/env zsh\nset -euo pipefail\n"
+	case llm.ShellFish:
+		return "#!/usr/bin/env fish\n"
+	}
+	return "#!/bin/sh\nset -eu\n"
+}
+
+// DefaultName returns the file name offered when saving a script for shell.
+func DefaultName(shell llm.Shell) string {
+	if shell == llm.ShellFish {
+		return "script.fish"
+	}
+	return "script.sh"
+}
+
+// Render returns the script for the steps that are not skipped, each
+// preceded by its description as a comment. query is recorded at the top.
+func Render(query string, steps []Step, shell llm.Shell) string {
+	var b strings.Builder
+	b.WriteString(Header(shell))
+	if query != "" {
+		fmt.Fprintf(&b, "\n%s\n", comment(query))
+	}
+	for i, s := range Active(steps) {
+		b.WriteString("\n")
+		if s.Description != "" {
+			fmt.Fprintf(&b, "%s\n", comment(fmt.Sprintf("%d. %s", i+1, singleLine(s.Description))))
+		}
+		fmt.Fprintf(&b, "%s\n", s.Command)
+	}
+	return b.String()
+}
+
+// comment prefixes every line of text with "# ".
+func comment(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight("# "+line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Save writes content to a new executable file at path. An existing file
+// is never overwritten.
+func Save(path, content string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o755)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("%s already exists", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save script: %w", err)
+	}
+	if _, err := f.WriteString(content); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to save script: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to save script: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package script
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evgfitil/qx/internal/llm"
+	"github.com/evgfitil/qx/pkg/qx"
+)
+
+func TestRender(t *testing.T) {
+	steps := []Step{
+		{Description: "Dump the database", Command: "pg_dump app > /tmp/app.sql"},
+		{Description: "Upload the dump", Command: "aws s3 cp /tmp/app.sql s3://backups/", Skip: true},
+		{Description: "Compress the dump", Command: "gzip -f /tmp/app.sql"},
+		{Command: "ls -l /tmp/app.sql.gz"},
+	}
+
+	got := Render("back up the app database", steps, llm.ShellBash)
+	want := `#!/usr/bin/env bash
+set -euo pipefail
+
+# back up the app database
+
+# 1. Dump the database
+pg_dump app > /tmp/app.sql
+
+# 2. Compress the dump
+gzip -f /tmp/app.sql
+
+ls -l /tmp/app.sql.gz
+`
+	if got != want {
+		t.Errorf("Render() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRender_MultilineQuery(t *testing.T) {
+	got := Render("rotate logs\n\nkeep a week", []Step{{Command: "logrotate -f /etc/logrotate.conf"}}, llm.ShellPOSIX)
+	if !strings.Contains(got, "# rotate logs\n#\n# keep a week\n") {
+		t.Errorf("every query line should be commented out, got:\n%s", got)
+	}
+}
+
+func TestRender_DescriptionStaysAComment(t *testing.T) {
+	got := Render("", []Step{{Description: "Clean up\nrm -rf ~\r", Command: "make clean"}}, llm.ShellBash)
+	if !strings.Contains(got, "# 1. Clean up rm -rf ~\nmake clean\n") {
+		t.Errorf("the description should be a single comment line, got:\n%q", got)
+	}
+}
+
+func TestFromLLM(t *testing.T) {
+	got := FromLLM([]qx.ScriptStep{{Description: "List\x1b[2J\nfiles", Command: "ls\x07 -la"}})
+	want := []Step{{Description: "List[2J files", Command: "ls -la"}}
+	if len(got) != 1 || got[0] != want[0] {
+		t.Errorf("FromLLM() = %+v, want %+v", got, want)
+	}
+}
+
+func TestHeader(t *testing.T) {
+	tests := []struct {
+		shell llm.Shell
+		want  string
+	}{
+		{llm.ShellBash, "#!/usr/bin/env bash\nset -euo pipefail\n"},
+		{llm.ShellZsh, "#!/usr/bin/env zsh\nset -euo pipefail\n"},
+		{llm.ShellFish, "#!/usr/bin/env fish\n"},
+		{llm.ShellPOSIX, "#!/bin/sh\nset -eu\n"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.shell), func(t *testing.T) {
+			if got := Header(tt.shell); got != tt.want {
+				t.Errorf("Header(%q) = %q, want %q", tt.shell, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backup.sh")
+
+	if err := Save(path, "#!/bin/sh\nls\n"); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if info.Mode().Perm()&0o100 == 0 {
+		t.Errorf("saved script mode = %v, want executable", info.Mode().Perm())
+	}
+
+	err = Save(path, "#!/bin/sh\nrm -rf /tmp/x\n")
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Save() over an existing file error = %v, want already exists", err)
+	}
+	content, _ := os.ReadFile(path)
+	if string(content) != "#!/bin/sh\nls\n" {
+		t.Errorf("existing file was overwritten: %q", content)
+	}
+}
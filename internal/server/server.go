@@ -0,0 +1,234 @@
+// Package server exposes command generation, explanation and the history
+// over a local HTTP API with JSON bodies. Every request needs the bearer
+// token, and queries are checked for secrets before they reach the LLM.
+package server
+
+import (
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/evgfitil/qx/internal/service"
+	"github.com/evgfitil/qx/pkg/qx"
+)
+
+// Schema describes the requests and responses of every endpoint as a JSON
+// Schema document; it is served on GET /v1/schema.
+//
+//go:embed schema.json
+var Schema []byte
+
+// maxBodyBytes caps request bodies.
+const maxBodyBytes = 1 << 20
+
+// defaultHistoryLimit is the number of history entries returned when the
+// request sets no limit.
+const defaultHistoryLimit = 20
+
+// Options configures the API.
+type Options struct {
+	// Token must be sent as "Authorization: Bearer <token>".
+	Token string
+	// Client runs every request. Its guard policy should block secrets,
+	// since clients cannot override the check, and its shell is used
+	// when a request names none.
+	Client *qx.Client
+	// Timeout bounds each LLM request.
+	Timeout time.Duration
+}
+
+// generateRequest is the body of POST /v1/generate.
+type generateRequest struct {
+	Query       string `json:"query"`
+	Count       int    `json:"count,omitempty"`
+	Shell       string `json:"shell,omitempty"`
+	PipeContext string `json:"pipe_context,omitempty"`
+}
+
+// generateResponse answers POST /v1/generate.
+type generateResponse struct {
+	Commands []qx.RatedCommand `json:"commands"`
+	Cached   bool              `json:"cached"`
+}
+
+// explainRequest is the body of POST /v1/explain.
+type explainRequest struct {
+	Command string `json:"command"`
+	Shell   string `json:"shell,omitempty"`
+}
+
+// explainResponse answers POST /v1/explain.
+type explainResponse = service.Explanation
+
+// historyResponse answers the history endpoints, newest first.
+type historyResponse struct {
+	Entries []qx.HistoryEntry `json:"entries"`
+}
+
+// errorResponse is returned with every status other than 200. Detections
+// lists the secrets found in a rejected query.
+type errorResponse struct {
+	Error      string         `json:"error"`
+	Detections []qx.Detection `json:"detections,omitempty"`
+}
+
+// api serves the endpoints.
+type api struct {
+	opts Options
+}
+
+// New returns the handler of the API.
+func New(opts Options) http.Handler {
+	a := &api{opts: opts}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/generate", a.generate)
+	mux.HandleFunc("POST /v1/explain", a.explain)
+	mux.HandleFunc("GET /v1/history", a.history)
+	mux.HandleFunc("GET /v1/history/search", a.searchHistory)
+	mux.HandleFunc("GET /v1/schema", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/schema+json")
+		_, _ = w.Write(Schema)
+	})
+	return a.authenticate(mux)
+}
+
+// authenticate rejects requests without the bearer token.
+func (a *api) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.opts.Token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("missing or invalid token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *api) generate(w http.ResponseWriter, r *http.Request) {
+	var req generateRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		writeError(w, http.StatusBadRequest, errors.New("query is required"))
+		return
+	}
+	if req.Count < 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("count must not be negative, got %d", req.Count))
+		return
+	}
+	client, err := service.Client(a.opts.Client, req.Shell)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	ctx, cancel := service.WithTimeout(r.Context(), a.opts.Timeout)
+	defer cancel()
+
+	var resp generateResponse
+	resp.Commands, resp.Cached, err = service.Generate(ctx, client, qx.Request{Query: req.Query, Count: req.Count, PipeContext: req.PipeContext})
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (a *api) explain(w http.ResponseWriter, r *http.Request) {
+	var req explainRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if strings.TrimSpace(req.Command) == "" {
+		writeError(w, http.StatusBadRequest, errors.New("command is required"))
+		return
+	}
+	client, err := service.Client(a.opts.Client, req.Shell)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	ctx, cancel := service.WithTimeout(r.Context(), a.opts.Timeout)
+	defer cancel()
+
+	resp, err := service.Explain(ctx, client, req.Command)
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (a *api) history(w http.ResponseWriter, r *http.Request) {
+	a.writeHistory(w, r, "")
+}
+
+func (a *api) searchHistory(w http.ResponseWriter, r *http.Request) {
+	term := r.URL.Query().Get("q")
+	if term == "" {
+		writeError(w, http.StatusBadRequest, errors.New("q is required"))
+		return
+	}
+	a.writeHistory(w, r, term)
+}
+
+// writeHistory writes the entries containing term, all of them when term
+// is empty, up to the limit parameter.
+func (a *api) writeHistory(w http.ResponseWriter, r *http.Request, term string) {
+	limit := defaultHistoryLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("limit must be a positive number, got %q", s))
+			return
+		}
+		limit = n
+	}
+
+	entries, err := service.History(a.opts.Client, term, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, historyResponse{Entries: entries})
+}
+
+// writeClientError rejects a query with secrets with 422 and the
+// detections; clients cannot override the check. Any other failure is
+// the LLM's.
+func writeClientError(w http.ResponseWriter, err error) {
+	var secretsErr *qx.SecretsError
+	if errors.As(err, &secretsErr) {
+		writeJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: err.Error(), Detections: secretsErr.Detected})
+		return
+	}
+	writeError(w, http.StatusBadGateway, err)
+}
+
+// decode reads the JSON body of r into v, rejecting unknown fields.
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return false
+	}
+	return true
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,101 @@
+// Package service handles what the HTTP API and the MCP server have in
+// common on top of a qx.Client: picking the shell a request names,
+// ranking and rating generated commands, explaining a command with its
+// risk and listing the history without secrets.
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/evgfitil/qx/internal/llm"
+	"github.com/evgfitil/qx/internal/rank"
+	"github.com/evgfitil/qx/pkg/qx"
+)
+
+// Explanation is the explanation of a command with its risk, which is
+// computed locally.
+type Explanation struct {
+	Summary  string     `json:"summary"`
+	Stages   []qx.Stage `json:"stages"`
+	Warnings []string   `json:"warnings"`
+	Risk     qx.Risk    `json:"risk"`
+}
+
+// Client returns the client for the shell a request names, or client
+// itself when it names none.
+func Client(client *qx.Client, shell string) (*qx.Client, error) {
+	if shell == "" {
+		return client, nil
+	}
+	s, err := qx.ParseShell(shell)
+	if err != nil {
+		return nil, err
+	}
+	return client.ForShell(s), nil
+}
+
+// WithTimeout bounds ctx by timeout unless it is zero.
+func WithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {}
+}
+
+// Generate returns the commands for req best first, each rated with its
+// risk, and whether any came from the response cache. No commands is not
+// an error.
+func Generate(ctx context.Context, client *qx.Client, req qx.Request) ([]qx.RatedCommand, bool, error) {
+	variants, err := client.Generate(ctx, req)
+	if err != nil && !errors.Is(err, qx.ErrNoCommands) {
+		return nil, false, err
+	}
+
+	commands := make([]qx.RatedCommand, 0, len(variants))
+	for _, v := range rank.Rank(variants, rank.Options{Shell: llm.Shell(client.Shell())}) {
+		commands = append(commands, qx.Rate(v.Command, v.Score.Confidence))
+	}
+	cached := false
+	for _, v := range variants {
+		cached = cached || v.Cached
+	}
+	return commands, cached, nil
+}
+
+// Explain explains command and rates its risk.
+func Explain(ctx context.Context, client *qx.Client, command string) (Explanation, error) {
+	explanation, err := client.Explain(ctx, command)
+	if err != nil {
+		return Explanation{}, err
+	}
+	return Explanation{
+		Summary:  explanation.Summary,
+		Stages:   explanation.Stages,
+		Warnings: explanation.Warnings,
+		Risk:     qx.ClassifyRisk(command),
+	}, nil
+}
+
+// History returns up to limit history entries containing term, all of
+// them when term is empty, newest first. The entries leave the machine,
+// so their secrets are redacted.
+func History(client *qx.Client, term string, limit int) ([]qx.HistoryEntry, error) {
+	var entries []qx.HistoryEntry
+	var err error
+	if term == "" {
+		entries, err = client.History()
+	} else {
+		entries, err = client.SearchHistory(term)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]qx.HistoryEntry, 0, min(limit, len(entries)))
+	for _, e := range entries[:min(limit, len(entries))] {
+		result = append(result, e.Redacted())
+	}
+	return result, nil
+}
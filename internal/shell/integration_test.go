@@ -53,9 +53,9 @@ func TestScriptContent(t *testing.T) {
 		shell         string
 		expectedParts []string
 	}{
-		{"bash", []string{"READLINE_LINE", "bind -x", "QX_PATH", "--query"}},
-		{"zsh", []string{"LBUFFER", "bindkey", "QX_PATH", "--query"}},
-		{"fish", []string{"__qx_widget", "commandline -r", "\\cg", "QX_PATH", "--query", "string collect", "pipestatus"}},
+		{"bash", []string{"READLINE_LINE", "bind -x", "QX_PATH", "--query", "--shell bash", `explain --shell bash -- "$READLINE_LINE"`, `"\ee": __qx_explain_widget`, "QX_LAST_COMMAND_DIR", "__qx_record", "mktemp -d", `__qx_trap_add '__qx_cleanup' EXIT "$(trap -p EXIT)"`, `__qx_trap_add __qx_preexec DEBUG "$(trap -p DEBUG)"`, "preexec_functions+=(__qx_preexec)"}},
+		{"zsh", []string{"LBUFFER", "bindkey", "QX_PATH", "--query", "--shell zsh", `explain --shell zsh -- "$current_buffer"`, "bindkey '^[e' __qx_explain_widget", "QX_LAST_COMMAND_DIR", "mktemp -d", "add-zsh-hook precmd __qx_precmd", "add-zsh-hook zshexit __qx_cleanup"}},
+		{"fish", []string{"__qx_widget", "commandline -r", "\\cg", "QX_PATH", "--query", "--shell fish", "string collect", "pipestatus", `explain --shell fish -- "$current_buffer"`, "bind \\ee __qx_explain_widget", "QX_LAST_COMMAND_DIR", "mktemp -d", "--on-event fish_postexec", "--on-event fish_exit"}},
 	}
 
 	for _, tt := range tests {
@@ -73,6 +73,24 @@ func TestScriptContent(t *testing.T) {
 	}
 }
 
+// TestScriptsUsePrivateRecordDir verifies the record directory is created
+// with mktemp rather than at a predictable path another user could create
+// first, and that no script replaces the DEBUG trap outright.
+func TestScriptsUsePrivateRecordDir(t *testing.T) {
+	for _, sh := range []string{"bash", "zsh", "fish"} {
+		script, err := Script(sh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(script, "mkdir") || strings.Contains(script, "qx-") {
+			t.Errorf("%s script should not create the record directory at a predictable path", sh)
+		}
+		if strings.Contains(script, "trap '__qx_preexec' DEBUG") {
+			t.Errorf("%s script should not replace an existing DEBUG trap", sh)
+		}
+	}
+}
+
 // TestBashSplitCondition verifies bash script uses split exit code handling:
 // exit 0 always updates buffer (even empty result), exit 130 only if non-empty.
 func TestBashSplitCondition(t *testing.T) {
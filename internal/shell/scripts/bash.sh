@@ -1,7 +1,7 @@
 __qx_widget() {
     local qx_cmd="${QX_PATH:-qx}"
     local result
-    result=$("$qx_cmd" --query "$READLINE_LINE" </dev/tty 2>/dev/tty)
+    result=$("$qx_cmd" --shell bash --query "$READLINE_LINE" </dev/tty 2>/dev/tty)
     local exit_code=$?
     if [[ $exit_code -eq 0 ]]; then
         READLINE_LINE="$result"
@@ -12,3 +12,86 @@ __qx_widget() {
     fi
 }
 bind -x '"\C-g": __qx_widget'
+
+__qx_explain_widget() {
+    [[ -z "$READLINE_LINE" ]] && return
+    local qx_cmd="${QX_PATH:-qx}"
+    "$qx_cmd" explain --shell bash -- "$READLINE_LINE" </dev/tty >/dev/tty 2>&1
+}
+bind -x '"\ee": __qx_explain_widget'
+
+# Record the last command and its exit code for `qx fix`. With
+# QX_CAPTURE_STDERR=1 its error output is also captured, tee'd to the terminal.
+# The record directory is private to this shell and removed when it exits.
+if [[ -z "$__qx_record_dir" || ! -d "$__qx_record_dir" ]]; then
+    __qx_record_dir=$(mktemp -d "${TMPDIR:-/tmp}/qx.XXXXXXXXXX")
+fi
+export QX_LAST_COMMAND_DIR="$__qx_record_dir"
+
+# __qx_trap_add sets the trap for signal $2 to run $1 after $3, the output
+# of `trap -p` for it, so the traps of the user and of tools such as
+# bash-preexec keep working. Callers read the trap themselves: functions
+# and sourced files do not see the DEBUG trap.
+__qx_trap_add() {
+    local existing=
+    if [[ -n "$3" ]]; then
+        eval "local -a __qx_trap=($3)"
+        existing=${__qx_trap[2]}
+    fi
+    [[ "$existing" == *"$1"* ]] && return
+    trap "${existing:+$existing$'\n'}$1" "$2"
+}
+
+__qx_cleanup() {
+    # Let the capture of `exit` itself finish before removing its file.
+    if [[ -n "$__qx_stderr_fd" ]]; then
+        exec 2>&"$__qx_stderr_fd" {__qx_stderr_fd}>&-
+        wait "$__qx_tee_pid" 2>/dev/null
+    fi
+    [[ -n "$__qx_record_dir" ]] && rm -rf -- "$__qx_record_dir"
+}
+__qx_trap_add '__qx_cleanup' EXIT "$(trap -p EXIT)"
+
+__qx_is_fix() {
+    [[ "$1" == "qx fix"* || "$1" == "${QX_PATH:-qx} fix"* ]]
+}
+
+__qx_record() {
+    local exit_status=$?
+    if [[ -n "$__qx_stderr_fd" ]]; then
+        exec 2>&"$__qx_stderr_fd" {__qx_stderr_fd}>&-
+        __qx_stderr_fd=
+    fi
+    local cmd
+    cmd=$(HISTTIMEFORMAT= builtin history 1)
+    cmd="${cmd#*[0-9]  }"
+    if [[ -n "$cmd" && -d "$QX_LAST_COMMAND_DIR" ]] && ! __qx_is_fix "$cmd"; then
+        printf '%s\n' "$cmd" >"$QX_LAST_COMMAND_DIR/command"
+        printf '%s\n' "$exit_status" >"$QX_LAST_COMMAND_DIR/status"
+        [[ -n "$QX_CAPTURE_STDERR" ]] || rm -f "$QX_LAST_COMMAND_DIR/stderr"
+    fi
+    return $exit_status
+}
+
+__qx_arm() {
+    __qx_at_prompt=1
+}
+
+__qx_preexec() {
+    [[ -n "$__qx_at_prompt" && -z "${READLINE_POINT+x}" && -d "$QX_LAST_COMMAND_DIR" ]] || return
+    __qx_at_prompt=
+    __qx_is_fix "${1:-$BASH_COMMAND}" && return
+    exec {__qx_stderr_fd}>&2 2> >(tee "$QX_LAST_COMMAND_DIR/stderr" >&2)
+    __qx_tee_pid=$!
+}
+
+PROMPT_COMMAND="__qx_record${PROMPT_COMMAND:+; $PROMPT_COMMAND}; __qx_arm"
+if [[ -n "$QX_CAPTURE_STDERR" ]]; then
+    if declare -p preexec_functions &>/dev/null; then
+        # bash-preexec owns the DEBUG trap and calls preexec_functions.
+        [[ " ${preexec_functions[*]} " == *" __qx_preexec "* ]] || preexec_functions+=(__qx_preexec)
+    else
+        # Chain onto the DEBUG trap at the first prompt, where it is visible.
+        PROMPT_COMMAND+='; [[ -n "$__qx_debug_set" ]] || { __qx_trap_add __qx_preexec DEBUG "$(trap -p DEBUG)"; __qx_debug_set=1; }'
+    fi
+fi
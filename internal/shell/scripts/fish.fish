@@ -4,7 +4,7 @@ function __qx_widget
         set qx_cmd $QX_PATH
     end
     set -l current_buffer (commandline)
-    set -l result ($qx_cmd --query "$current_buffer" 2>/dev/tty </dev/tty | string collect)
+    set -l result ($qx_cmd --shell fish --query "$current_buffer" 2>/dev/tty </dev/tty | string collect)
     set -l exit_code $pipestatus[1]
     if test $exit_code -eq 0
         commandline -r -- "$result"
@@ -14,3 +14,43 @@ function __qx_widget
     commandline -f repaint
 end
 bind \cg __qx_widget
+
+function __qx_explain_widget
+    set -l current_buffer (commandline | string collect)
+    test -z "$current_buffer"; and return
+    set -l qx_cmd qx
+    if set -q QX_PATH; and test -n "$QX_PATH"
+        set qx_cmd $QX_PATH
+    end
+    echo >/dev/tty
+    $qx_cmd explain --shell fish -- "$current_buffer" </dev/tty >/dev/tty 2>&1
+    commandline -f repaint
+end
+bind \ee __qx_explain_widget
+
+# Record the last command and its exit code for `qx fix`. Fish cannot
+# redirect the error output of the commands it runs, so none is captured.
+# The record directory is private to this shell and removed when it exits.
+if not set -q __qx_record_dir; or not test -d "$__qx_record_dir"
+    set -l __qx_tmp /tmp
+    set -q TMPDIR; and test -n "$TMPDIR"; and set __qx_tmp $TMPDIR
+    set -g __qx_record_dir (mktemp -d "$__qx_tmp/qx.XXXXXXXXXX")
+end
+set -gx QX_LAST_COMMAND_DIR $__qx_record_dir
+
+function __qx_cleanup --on-event fish_exit
+    test -n "$__qx_record_dir"; and rm -rf -- $__qx_record_dir
+end
+
+function __qx_record --on-event fish_postexec
+    set -l exit_status $status
+    set -l qx_cmd qx
+    if set -q QX_PATH; and test -n "$QX_PATH"
+        set qx_cmd $QX_PATH
+    end
+    if test -z "$argv[1]"; or not test -d "$QX_LAST_COMMAND_DIR"; or string match -q -- 'qx fix*' $argv[1]; or string match -q -- "$qx_cmd fix*" $argv[1]
+        return
+    end
+    printf '%s\n' $argv[1] >$QX_LAST_COMMAND_DIR/command
+    printf '%s\n' $exit_status >$QX_LAST_COMMAND_DIR/status
+end
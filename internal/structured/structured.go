@@ -0,0 +1,469 @@
+package structured
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Format is the kind of structured data detected.
+type Format string
+
+// Detected formats.
+const (
+	FormatJSON  Format = "JSON"
+	FormatYAML  Format = "YAML"
+	FormatCSV   Format = "CSV"
+	FormatTable Format = "table"
+)
+
+const (
+	// headerPrefix starts every summary; Detect never treats a summary as
+	// structured data itself.
+	headerPrefix = "[structure of "
+	maxPaths     = 120
+	maxArrayScan = 200
+	sampleRows   = 3
+	maxSample    = 40
+)
+
+// Schema is a compact description of structured data: field paths or
+// columns with their types and sample values.
+type Schema struct {
+	Format Format
+	Size   int      // bytes of the described input
+	Rows   int      // records, table rows or documents
+	Fields []string // one line per path or column
+	Sample []string // sample rows for CSV and tables
+}
+
+// String renders the schema as sent to the LLM.
+func (s Schema) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s%s input, %d bytes", headerPrefix, s.Format, s.Size)
+	switch s.Format {
+	case FormatCSV, FormatTable:
+		fmt.Fprintf(&b, ", %d rows", s.Rows)
+	default:
+		if s.Rows > 1 {
+			fmt.Fprintf(&b, ", %d documents", s.Rows)
+		}
+	}
+	b.WriteString("]\n")
+
+	switch s.Format {
+	case FormatCSV, FormatTable:
+		b.WriteString("columns:\n")
+	default:
+		b.WriteString("paths:\n")
+	}
+	for _, f := range s.Fields {
+		fmt.Fprintf(&b, "  %s\n", f)
+	}
+	if len(s.Sample) > 0 {
+		b.WriteString("sample rows:\n")
+		for _, row := range s.Sample {
+			fmt.Fprintf(&b, "  %s\n", row)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Detect recognizes JSON (including JSON lines), YAML, CSV/TSV and
+// column-aligned tables such as kubectl or docker output, and describes
+// their structure.
+func Detect(text string) (Schema, bool) {
+	text = strings.TrimSpace(text)
+	if text == "" || strings.HasPrefix(text, headerPrefix) {
+		return Schema{}, false
+	}
+	for _, detect := range []func(string) (Schema, bool){detectJSON, detectYAML, detectCSV, detectTable} {
+		if s, ok := detect(text); ok {
+			s.Size = len(text)
+			return s, true
+		}
+	}
+	return Schema{}, false
+}
+
+func detectJSON(text string) (Schema, bool) {
+	if text[0] != '{' && text[0] != '[' {
+		return Schema{}, false
+	}
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+	var docs []any
+	for {
+		var v any
+		err := dec.Decode(&v)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Schema{}, false
+		}
+		docs = append(docs, v)
+	}
+	return describeDocs(FormatJSON, docs), true
+}
+
+// yamlKeyRe matches top-level keys that look like field names rather
+// than log prefixes such as "2024-05-01 10:00:00 app".
+var yamlKeyRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+func detectYAML(text string) (Schema, bool) {
+	dec := yaml.NewDecoder(strings.NewReader(text))
+	var docs []any
+	for {
+		var v any
+		err := dec.Decode(&v)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Schema{}, false
+		}
+		switch doc := v.(type) {
+		case map[string]any:
+			for k := range doc {
+				if !yamlKeyRe.MatchString(k) {
+					return Schema{}, false
+				}
+			}
+		case []any:
+		case nil:
+			continue
+		default:
+			return Schema{}, false
+		}
+		docs = append(docs, v)
+	}
+	if len(docs) == 0 {
+		return Schema{}, false
+	}
+	return describeDocs(FormatYAML, docs), true
+}
+
+func describeDocs(format Format, docs []any) Schema {
+	w := newWalker()
+	for _, doc := range docs {
+		w.walk(".", doc)
+	}
+	return Schema{Format: format, Rows: len(docs), Fields: w.lines()}
+}
+
+// field collects what was seen at one path.
+type field struct {
+	types          []string
+	sample         string
+	minLen, maxLen int
+}
+
+type walker struct {
+	order  []string
+	fields map[string]*field
+}
+
+func newWalker() *walker {
+	return &walker{fields: make(map[string]*field)}
+}
+
+func (w *walker) field(path string) *field {
+	f, ok := w.fields[path]
+	if !ok {
+		f = &field{minLen: -1}
+		w.fields[path] = f
+		w.order = append(w.order, path)
+	}
+	return f
+}
+
+func (w *walker) addType(f *field, typ string) {
+	if !slices.Contains(f.types, typ) {
+		f.types = append(f.types, typ)
+	}
+}
+
+// walk records v at path in jq syntax, e.g. ".items[].metadata.name".
+func (w *walker) walk(path string, v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		for _, k := range slices.Sorted(maps.Keys(v)) {
+			w.walk(joinPath(path, k), v[k])
+		}
+	case []any:
+		f := w.field(path)
+		w.addType(f, "array")
+		if f.minLen < 0 || len(v) < f.minLen {
+			f.minLen = len(v)
+		}
+		f.maxLen = max(f.maxLen, len(v))
+		elem := path + "[]"
+		if path == "." {
+			elem = ".[]"
+		}
+		for _, e := range v[:min(len(v), maxArrayScan)] {
+			w.walk(elem, e)
+		}
+	default:
+		f := w.field(path)
+		typ, sample := scalar(v)
+		w.addType(f, typ)
+		if f.sample == "" && sample != "" {
+			f.sample = sample
+		}
+	}
+}
+
+func (w *walker) lines() []string {
+	var out []string
+	for i, path := range w.order {
+		if i == maxPaths {
+			out = append(out, fmt.Sprintf("... %d more paths", len(w.order)-maxPaths))
+			break
+		}
+		f := w.fields[path]
+		line := path + ": " + strings.Join(f.types, "|")
+		switch {
+		case f.minLen >= 0 && f.minLen == f.maxLen:
+			line += fmt.Sprintf(" (%d items)", f.maxLen)
+		case f.minLen >= 0:
+			line += fmt.Sprintf(" (%d-%d items)", f.minLen, f.maxLen)
+		}
+		if f.sample != "" {
+			line += " (e.g. " + f.sample + ")"
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+func scalar(v any) (typ, sample string) {
+	switch v := v.(type) {
+	case nil:
+		return "null", ""
+	case bool:
+		return "bool", strconv.FormatBool(v)
+	case string:
+		if v == "" {
+			return "string", ""
+		}
+		return "string", strconv.Quote(shorten(v))
+	case json.Number:
+		return "number", v.String()
+	case int, int64, uint64, float64:
+		return "number", fmt.Sprint(v)
+	}
+	return fmt.Sprintf("%T", v), shorten(fmt.Sprint(v))
+}
+
+// joinPath appends key to a jq path, quoting keys that are not plain
+// identifiers.
+func joinPath(path, key string) string {
+	if !jqIdentRe.MatchString(key) {
+		key = strconv.Quote(key)
+	}
+	if path == "." {
+		return "." + key
+	}
+	return path + "." + key
+}
+
+var jqIdentRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func detectCSV(text string) (Schema, bool) {
+	firstLine, _, _ := strings.Cut(text, "\n")
+	for _, sep := range []rune{'\t', ',', ';'} {
+		if !strings.ContainsRune(firstLine, sep) {
+			continue
+		}
+		r := csv.NewReader(strings.NewReader(text))
+		r.Comma = sep
+		records, err := r.ReadAll()
+		if err != nil || len(records) < 2 || len(records[0]) < 2 {
+			continue
+		}
+		header := records[0]
+		if !plausibleHeader(header) {
+			continue
+		}
+		rows := records[1:]
+		var sample []string
+		for _, row := range rows[:min(len(rows), sampleRows)] {
+			sample = append(sample, shorten(strings.Join(row, string(sep))))
+		}
+		return Schema{Format: FormatCSV, Rows: len(rows), Fields: columns(header, rows), Sample: sample}, true
+	}
+	return Schema{}, false
+}
+
+func plausibleHeader(header []string) bool {
+	for _, h := range header {
+		h = strings.TrimSpace(h)
+		if h == "" || len(h) > 64 {
+			return false
+		}
+	}
+	return true
+}
+
+// tableHeaderRe matches header cells of column-aligned output, e.g.
+// "NAME", "CONTAINER ID" or "%CPU".
+var tableHeaderRe = regexp.MustCompile(`^[A-Z%][A-Z0-9_%()/.:-]*( [A-Z][A-Z0-9_%()/.:-]*)*$`)
+
+var wideGapRe = regexp.MustCompile(` {2,}|\t`)
+
+func detectTable(text string) (Schema, bool) {
+	lines := strings.Split(text, "\n")
+	if len(lines) < 2 {
+		return Schema{}, false
+	}
+	headerLine := strings.TrimRight(lines[0], " \r")
+
+	// Aligned output separates columns with two or more spaces, so cells
+	// such as "Up 2 hours" can contain single spaces.
+	header, starts := splitAligned(headerLine)
+	aligned := len(header) >= 2
+	if !aligned {
+		header = strings.Fields(headerLine)
+	}
+	if len(header) < 2 {
+		return Schema{}, false
+	}
+	for _, h := range header {
+		if !tableHeaderRe.MatchString(h) {
+			return Schema{}, false
+		}
+	}
+
+	var rows [][]string
+	for _, line := range lines[1:] {
+		line = strings.TrimRight(line, " \r")
+		if line == "" {
+			continue
+		}
+		var row []string
+		if aligned {
+			row = sliceColumns(line, starts)
+		} else {
+			row = strings.SplitN(strings.Join(strings.Fields(line), " "), " ", len(header))
+		}
+		if len(row) != len(header) {
+			return Schema{}, false
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return Schema{}, false
+	}
+
+	var sample []string
+	for _, line := range lines[1:min(len(lines), sampleRows+1)] {
+		sample = append(sample, shorten(strings.TrimSpace(line)))
+	}
+	return Schema{Format: FormatTable, Rows: len(rows), Fields: columns(header, rows), Sample: sample}, true
+}
+
+// splitAligned splits a header on runs of two or more spaces and returns
+// the cells with their start offsets.
+func splitAligned(line string) ([]string, []int) {
+	var cells []string
+	var starts []int
+	pos := 0
+	for _, gap := range wideGapRe.FindAllStringIndex(line, -1) {
+		if gap[0] > pos {
+			cells = append(cells, line[pos:gap[0]])
+			starts = append(starts, pos)
+		}
+		pos = gap[1]
+	}
+	if pos < len(line) {
+		cells = append(cells, line[pos:])
+		starts = append(starts, pos)
+	}
+	return cells, starts
+}
+
+// sliceColumns cuts a row at the header's column offsets. Cells past the
+// end of a short row are empty.
+func sliceColumns(line string, starts []int) []string {
+	row := make([]string, len(starts))
+	for i, start := range starts {
+		if start >= len(line) {
+			continue
+		}
+		end := len(line)
+		if i+1 < len(starts) && starts[i+1] < end {
+			end = starts[i+1]
+		}
+		row[i] = strings.TrimSpace(line[start:end])
+	}
+	return row
+}
+
+// columns describes each column with the type of its values and a sample.
+func columns(header []string, rows [][]string) []string {
+	out := make([]string, len(header))
+	for i, name := range header {
+		typ, sample := "", ""
+		for _, row := range rows {
+			if i >= len(row) || row[i] == "" {
+				continue
+			}
+			if sample == "" {
+				sample = strconv.Quote(shorten(row[i]))
+			}
+			typ = widen(typ, valueType(row[i]))
+		}
+		if typ == "" {
+			typ = "empty"
+		}
+		out[i] = fmt.Sprintf("%s: %s", strings.TrimSpace(name), typ)
+		if sample != "" {
+			out[i] += " (e.g. " + sample + ")"
+		}
+	}
+	return out
+}
+
+func valueType(s string) string {
+	if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return "int"
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return "float"
+	}
+	if _, err := strconv.ParseBool(s); err == nil {
+		return "bool"
+	}
+	return "string"
+}
+
+// widen returns the narrowest type that fits values of both types.
+func widen(a, b string) string {
+	switch {
+	case a == "" || a == b:
+		return b
+	case (a == "int" && b == "float") || (a == "float" && b == "int"):
+		return "float"
+	}
+	return "string"
+}
+
+// shorten collapses whitespace and cuts s to maxSample runes.
+func shorten(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > maxSample {
+		return string(r[:maxSample]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,146 @@
+package structured
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetect_JSON(t *testing.T) {
+	input := `{
+  "apiVersion": "v1",
+  "items": [
+    {"metadata": {"name": "web-1", "labels": {"app.kubernetes.io/name": "web"}}, "status": {"phase": "Running", "restarts": 0}},
+    {"metadata": {"name": "db-0"}, "status": {"phase": "Pending", "restarts": 3, "reason": null}}
+  ]
+}`
+	s, ok := Detect(input)
+	if !ok {
+		t.Fatal("Detect() did not recognize JSON")
+	}
+	if s.Format != FormatJSON {
+		t.Errorf("Format = %q, want JSON", s.Format)
+	}
+	want := []string{
+		`.apiVersion: string (e.g. "v1")`,
+		`.items: array (2 items)`,
+		`.items[].metadata.labels."app.kubernetes.io/name": string (e.g. "web")`,
+		`.items[].metadata.name: string (e.g. "web-1")`,
+		`.items[].status.phase: string (e.g. "Running")`,
+		`.items[].status.restarts: number (e.g. 0)`,
+		`.items[].status.reason: null`,
+	}
+	if strings.Join(s.Fields, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Fields =\n%s\nwant\n%s", strings.Join(s.Fields, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestDetect_JSONLines(t *testing.T) {
+	s, ok := Detect("{\"level\":\"info\",\"msg\":\"started\"}\n{\"level\":\"error\",\"msg\":\"failed\",\"code\":2}\n")
+	if !ok || s.Format != FormatJSON || s.Rows != 2 {
+		t.Fatalf("Detect() = %+v, %v, want two JSON documents", s, ok)
+	}
+	if !strings.Contains(s.String(), ".code: number (e.g. 2)") {
+		t.Errorf("String() = %q, want keys from all documents", s.String())
+	}
+}
+
+func TestDetect_RootArray(t *testing.T) {
+	s, ok := Detect(`[{"id": 1}, {"id": 2}, {"id": 3}]`)
+	if !ok || s.Fields[0] != ".: array (3 items)" || s.Fields[1] != ".[].id: number (e.g. 1)" {
+		t.Errorf("Detect() = %+v, %v", s.Fields, ok)
+	}
+}
+
+func TestDetect_YAML(t *testing.T) {
+	input := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx:1.27
+`
+	s, ok := Detect(input)
+	if !ok || s.Format != FormatYAML {
+		t.Fatalf("Detect() = %+v, %v, want YAML", s, ok)
+	}
+	for _, want := range []string{
+		`.spec.replicas: number (e.g. 3)`,
+		`.spec.template.spec.containers[].image: string (e.g. "nginx:1.27")`,
+	} {
+		if !strings.Contains(s.String(), want) {
+			t.Errorf("String() missing %q:\n%s", want, s.String())
+		}
+	}
+}
+
+func TestDetect_CSV(t *testing.T) {
+	s, ok := Detect("name,age,score\nalice,30,9.5\nbob,25,7\ncarol,41,8.25\ndave,,6\n")
+	if !ok || s.Format != FormatCSV {
+		t.Fatalf("Detect() = %+v, %v, want CSV", s, ok)
+	}
+	want := []string{`name: string (e.g. "alice")`, `age: int (e.g. "30")`, `score: float (e.g. "9.5")`}
+	if strings.Join(s.Fields, "|") != strings.Join(want, "|") {
+		t.Errorf("Fields = %v, want %v", s.Fields, want)
+	}
+	if s.Rows != 4 || len(s.Sample) != sampleRows {
+		t.Errorf("Rows = %d, Sample = %v", s.Rows, s.Sample)
+	}
+}
+
+func TestDetect_AlignedTable(t *testing.T) {
+	input := `CONTAINER ID   IMAGE          STATUS          PORTS
+3f4e5d6c7b8a   nginx:1.27     Up 2 hours      0.0.0.0:80->80/tcp
+9a8b7c6d5e4f   postgres:16    Up 5 minutes
+`
+	s, ok := Detect(input)
+	if !ok || s.Format != FormatTable {
+		t.Fatalf("Detect() = %+v, %v, want table", s, ok)
+	}
+	want := []string{
+		`CONTAINER ID: string (e.g. "3f4e5d6c7b8a")`,
+		`IMAGE: string (e.g. "nginx:1.27")`,
+		`STATUS: string (e.g. "Up 2 hours")`,
+		`PORTS: string (e.g. "0.0.0.0:80->80/tcp")`,
+	}
+	if strings.Join(s.Fields, "|") != strings.Join(want, "|") {
+		t.Errorf("Fields = %v, want %v", s.Fields, want)
+	}
+}
+
+func TestDetect_WhitespaceTable(t *testing.T) {
+	input := "USER PID %CPU COMMAND\nroot 1 0.0 /sbin/init splash\nwww 812 1.5 nginx: worker process\n"
+	s, ok := Detect(input)
+	if !ok || s.Format != FormatTable {
+		t.Fatalf("Detect() = %+v, %v, want table", s, ok)
+	}
+	if s.Fields[1] != `PID: int (e.g. "1")` || s.Fields[3] != `COMMAND: string (e.g. "/sbin/init splash")` {
+		t.Errorf("Fields = %v", s.Fields)
+	}
+}
+
+func TestDetect_Unstructured(t *testing.T) {
+	for _, input := range []string{
+		"total 24\ndrwxr-xr-x  5 user staff  160 Jan  1 12:00 dir1",
+		"2024-05-01 10:00:00 app: started\n2024-05-01 10:00:01 app: ready",
+		"[INFO] build started\n[INFO] build finished",
+		"just one line",
+		"[structure of JSON input, 10 bytes]\npaths:\n  .a: string",
+	} {
+		if s, ok := Detect(input); ok {
+			t.Errorf("Detect(%q) = %+v, want not structured", input, s)
+		}
+	}
+}
+
+func TestSchemaString(t *testing.T) {
+	s := Schema{Format: FormatCSV, Size: 120, Rows: 2, Fields: []string{"a: int"}, Sample: []string{"1"}}
+	want := "[structure of CSV input, 120 bytes, 2 rows]\ncolumns:\n  a: int\nsample rows:\n  1"
+	if got := s.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,68 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/evgfitil/qx/internal/guard"
+	"github.com/evgfitil/qx/pkg/qx"
+)
+
+// riskColors maps each risk level to the color of its label.
+var riskColors = map[qx.RiskLevel]string{
+	qx.RiskLow:    "42",
+	qx.RiskMedium: "214",
+	qx.RiskHigh:   "196",
+}
+
+// RenderExplanation formats the explanation of command for the terminal:
+// the command and its risk, the summary, every stage with its flags, and
+// warnings. Every text from the LLM is sanitized before it is rendered.
+func RenderExplanation(command string, e *qx.Explanation, risk qx.Risk, theme Theme) string {
+	var b strings.Builder
+	muted := theme.MutedStyle()
+	clean := guard.SanitizeOutput
+
+	fmt.Fprintf(&b, "\n  %s\n\n", theme.SelectedStyle().Render(command))
+
+	label := theme.newStyle().Foreground(lipgloss.Color(riskColors[risk.Level])).Bold(true).
+		Render(strings.ToUpper(risk.Level.String()))
+	fmt.Fprintf(&b, "  Risk: %s", label)
+	if len(risk.Reasons) > 0 {
+		b.WriteString(muted.Render(" — " + strings.Join(risk.Reasons, "; ")))
+	}
+	b.WriteString("\n")
+
+	if e.Summary != "" {
+		fmt.Fprintf(&b, "\n  %s\n", clean(e.Summary))
+	}
+
+	if len(e.Stages) > 0 {
+		b.WriteString("\n")
+	}
+	for i, stage := range e.Stages {
+		fmt.Fprintf(&b, "  %s %s\n", muted.Render(fmt.Sprintf("%d.", i+1)), theme.PromptStyle().Render(clean(stage.Command)))
+		if stage.Description != "" {
+			fmt.Fprintf(&b, "     %s\n", clean(stage.Description))
+		}
+		width := 0
+		for _, f := range stage.Flags {
+			width = max(width, lipgloss.Width(clean(f.Flag)))
+		}
+		for _, f := range stage.Flags {
+			flag := clean(f.Flag)
+			pad := strings.Repeat(" ", width-lipgloss.Width(flag))
+			fmt.Fprintf(&b, "       %s%s  %s\n", theme.NormalStyle().Render(flag), pad, muted.Render(clean(f.Description)))
+		}
+	}
+
+	if len(e.Warnings) > 0 {
+		b.WriteString("\n  Warnings:\n")
+		for _, w := range e.Warnings {
+			fmt.Fprintf(&b, "  %s %s\n", theme.newStyle().Foreground(lipgloss.Color(riskColors[qx.RiskMedium])).Render("!"), clean(w))
+		}
+	}
+	return b.String()
+}
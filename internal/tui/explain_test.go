@@ -0,0 +1,69 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/evgfitil/qx/pkg/qx"
+)
+
+func TestRenderExplanation(t *testing.T) {
+	e := &qx.Explanation{
+		Summary: "Deletes old logs",
+		Stages: []qx.Stage{{
+			Command:     "find /var/log -mtime +7 -delete",
+			Description: "Finds and deletes files",
+			Flags: []qx.Flag{
+				{Flag: "-mtime +7", Description: "modified more than 7 days ago"},
+				{Flag: "-delete", Description: "deletes each match"},
+			},
+		}},
+		Warnings: []string{"Runs without confirmation"},
+	}
+	risk := qx.Risk{Level: qx.RiskHigh, Reasons: []string{"deletes every file find matches"}}
+
+	got := RenderExplanation("find /var/log -mtime +7 -delete", e, risk, DefaultTheme())
+
+	for _, want := range []string{
+		"  find /var/log -mtime +7 -delete\n",
+		"Risk: HIGH — deletes every file find matches",
+		"  Deletes old logs\n",
+		"  1. find /var/log -mtime +7 -delete\n     Finds and deletes files\n",
+		"       -mtime +7  modified more than 7 days ago\n",
+		"       -delete    deletes each match\n",
+		"  Warnings:\n  ! Runs without confirmation\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("RenderExplanation() missing %q in:\n%s", want, got)
+		}
+	}
+}
+
+func TestRenderExplanation_SanitizesLLMOutput(t *testing.T) {
+	e := &qx.Explanation{
+		Summary: "Lists\x1b]0;pwned\x07 files",
+		Stages: []qx.Stage{{
+			Command:     "ls\x1b[2J",
+			Description: "Lists\x1b[31m",
+			Flags:       []qx.Flag{{Flag: "-l\x07", Description: "long\x1b[1A"}},
+		}},
+		Warnings: []string{"none\x1b[K"},
+	}
+
+	got := RenderExplanation("ls -l", e, qx.Risk{Level: qx.RiskLow}, DefaultTheme())
+	for _, bad := range []string{"\x1b]", "\x07", "\x1b[2J", "\x1b[31m", "\x1b[1A", "\x1b[K"} {
+		if strings.Contains(got, bad) {
+			t.Errorf("RenderExplanation() contains %q:\n%q", bad, got)
+		}
+	}
+}
+
+func TestRenderExplanation_SummaryOnly(t *testing.T) {
+	got := RenderExplanation("date", &qx.Explanation{Summary: "Prints the date"}, qx.Risk{Level: qx.RiskLow}, DefaultTheme())
+	if strings.Contains(got, "Warnings") || strings.Contains(got, "1.") {
+		t.Errorf("RenderExplanation() should omit empty sections:\n%s", got)
+	}
+	if !strings.Contains(got, "Risk: LOW\n") {
+		t.Errorf("RenderExplanation() = %q, want risk without reasons", got)
+	}
+}
@@ -0,0 +1,290 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/evgfitil/qx/internal/keymap"
+	"github.com/evgfitil/qx/internal/llm"
+	"github.com/evgfitil/qx/internal/placeholder"
+)
+
+// maxPathCompletions caps the directory entries offered for a path.
+const maxPathCompletions = 50
+
+// FormOptions configures the placeholder form.
+type FormOptions struct {
+	Theme Theme
+	Keys  keymap.KeyMap
+	// Shell is the dialect the preview quotes values for.
+	Shell llm.Shell
+	// Recent holds remembered values by placeholder name, newest first.
+	Recent map[string][]string
+}
+
+// formField is the input for one placeholder.
+type formField struct {
+	placeholder placeholder.Placeholder
+	input       textinput.Model
+	recent      []string
+	// recentIdx is the remembered value shown in the input, -1 when the
+	// input holds the user's own text.
+	recentIdx int
+}
+
+// formModel asks for a value for every placeholder in a command.
+type formModel struct {
+	command   string
+	fields    []formField
+	focus     int
+	shell     llm.Shell
+	theme     Theme
+	cancel    key.Binding
+	width     int
+	err       string
+	done      bool
+	cancelled bool
+}
+
+func newFormModel(command string, opts FormOptions) formModel {
+	km := opts.Keys.WithDefaults()
+	m := formModel{
+		command: command,
+		shell:   opts.Shell,
+		theme:   opts.Theme,
+		cancel:  key.NewBinding(key.WithKeys(km.Cancel...)),
+	}
+
+	for _, p := range placeholder.Parse(command) {
+		input := textinput.New()
+		input.Prompt = fmt.Sprintf("%s (%s): ", p.Name, p.Type)
+		input.PromptStyle = opts.Theme.NormalStyle()
+		input.TextStyle = opts.Theme.newStyle()
+		input.CompletionStyle = opts.Theme.MutedStyle()
+		input.ShowSuggestions = true
+		// up and down cycle through remembered values instead
+		input.KeyMap.NextSuggestion = key.NewBinding(key.WithKeys("ctrl+n"))
+		input.KeyMap.PrevSuggestion = key.NewBinding(key.WithKeys("ctrl+p"))
+
+		f := formField{placeholder: p, input: input, recent: opts.Recent[p.Name], recentIdx: -1}
+		if len(f.recent) > 0 {
+			f.recentIdx = 0
+			f.input.SetValue(f.recent[0])
+			f.input.CursorEnd()
+		}
+		f.refreshSuggestions()
+		m.fields = append(m.fields, f)
+	}
+	if len(m.fields) > 0 {
+		m.fields[0].input.Focus()
+	}
+	return m
+}
+
+// refreshSuggestions offers the remembered values and, for paths, the
+// entries of the directory being typed.
+func (f *formField) refreshSuggestions() {
+	suggestions := append([]string(nil), f.recent...)
+	if f.placeholder.Type == placeholder.TypePath {
+		suggestions = append(suggestions, completePath(f.input.Value())...)
+	}
+	f.input.SetSuggestions(suggestions)
+}
+
+// completePath returns the entries of the directory in value that start
+// with its last element, keeping value's spelling of the directory.
+// Directories end with a slash; hidden entries are offered only once a
+// dot is typed.
+func completePath(value string) []string {
+	dir, prefix := filepath.Split(value)
+	readDir := dir
+	switch {
+	case readDir == "":
+		readDir = "."
+	case strings.HasPrefix(readDir, "~/"):
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil
+		}
+		readDir = filepath.Join(home, readDir[2:])
+	}
+
+	entries, err := os.ReadDir(readDir)
+	if err != nil {
+		return nil
+	}
+	var result []string
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, prefix) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(prefix, ".")) {
+			continue
+		}
+		if e.IsDir() {
+			name += "/"
+		}
+		result = append(result, dir+name)
+		if len(result) == maxPathCompletions {
+			break
+		}
+	}
+	return result
+}
+
+// Init implements tea.Model.
+func (m formModel) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+// Update implements tea.Model.
+func (m formModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		return m, nil
+	case tea.KeyMsg:
+		return m.updateKey(msg)
+	}
+
+	var cmd tea.Cmd
+	m.fields[m.focus].input, cmd = m.fields[m.focus].input.Update(msg)
+	return m, cmd
+}
+
+func (m formModel) updateKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	f := &m.fields[m.focus]
+	switch {
+	case msg.Type == tea.KeyCtrlC, key.Matches(msg, m.cancel):
+		m.cancelled = true
+		m.done = true
+		return m, tea.Quit
+
+	case msg.Type == tea.KeyEnter:
+		if err := placeholder.Validate(f.placeholder, f.input.Value()); err != nil {
+			m.err = err.Error()
+			return m, nil
+		}
+		m.err = ""
+		if m.focus == len(m.fields)-1 {
+			m.done = true
+			return m, tea.Quit
+		}
+		return m, m.moveFocus(1)
+
+	case msg.Type == tea.KeyShiftTab:
+		if m.focus > 0 {
+			return m, m.moveFocus(-1)
+		}
+		return m, nil
+
+	case msg.Type == tea.KeyUp, msg.Type == tea.KeyDown:
+		if len(f.recent) == 0 {
+			return m, nil
+		}
+		if msg.Type == tea.KeyUp {
+			f.recentIdx = min(f.recentIdx+1, len(f.recent)-1)
+		} else {
+			f.recentIdx = max(f.recentIdx-1, 0)
+		}
+		f.input.SetValue(f.recent[f.recentIdx])
+		f.input.CursorEnd()
+		f.refreshSuggestions()
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	before := f.input.Value()
+	f.input, cmd = f.input.Update(msg)
+	if f.input.Value() != before {
+		f.recentIdx = -1
+		m.err = ""
+		f.refreshSuggestions()
+	}
+	return m, cmd
+}
+
+func (m *formModel) moveFocus(delta int) tea.Cmd {
+	m.fields[m.focus].input.Blur()
+	m.focus += delta
+	return m.fields[m.focus].input.Focus()
+}
+
+// values returns the non-empty values entered so far.
+func (m formModel) values() map[string]string {
+	values := make(map[string]string, len(m.fields))
+	for _, f := range m.fields {
+		if v := f.input.Value(); v != "" {
+			values[f.placeholder.Name] = v
+		}
+	}
+	return values
+}
+
+// View implements tea.Model.
+func (m formModel) View() string {
+	if m.done {
+		return ""
+	}
+
+	var content strings.Builder
+	muted := m.theme.MutedStyle()
+
+	content.WriteString(m.theme.SelectedStyle().Render(placeholder.Substitute(m.command, m.values(), m.shell)))
+	content.WriteString("\n\n")
+	for i, f := range m.fields {
+		pointer := strings.Repeat(" ", lipgloss.Width(m.theme.Pointer))
+		if i == m.focus {
+			pointer = m.theme.Pointer
+		}
+		content.WriteString(pointer + " " + f.input.View() + "\n")
+	}
+	content.WriteString("\n")
+	if m.err != "" {
+		content.WriteString(muted.Render("Error: "+m.err) + "\n")
+	}
+	content.WriteString(muted.Render(fmt.Sprintf("enter next · shift+tab back · tab complete · ↑/↓ recent values · %s cancel", m.cancel.Keys()[0])))
+
+	borderStyle := m.theme.BorderStyle()
+	if m.width > 0 {
+		borderStyle = borderStyle.Width(m.width - 2)
+	}
+	return borderStyle.Render(content.String()) + "\n"
+}
+
+// RunForm asks for a value for every placeholder in command. It returns
+// the values by placeholder name, or ok false if the user cancelled.
+func RunForm(command string, opts FormOptions) (values map[string]string, ok bool, err error) {
+	if !placeholder.Has(command) {
+		return map[string]string{}, true, nil
+	}
+
+	tty, theme := openTTY(opts.Theme)
+	if tty != os.Stdout {
+		defer tty.Close() //nolint:errcheck
+	}
+	opts.Theme = theme
+
+	restore := saveTermState()
+	p := tea.NewProgram(newFormModel(command, opts), tea.WithOutput(tty), tea.WithInputTTY())
+
+	result, err := p.Run()
+	restore()
+	if err != nil {
+		return nil, false, fmt.Errorf("TUI error: %w", err)
+	}
+
+	model, isForm := result.(formModel)
+	if !isForm {
+		return nil, false, fmt.Errorf("unexpected model type: %T", result)
+	}
+	if model.cancelled {
+		return nil, false, nil
+	}
+	return model.values(), true, nil
+}
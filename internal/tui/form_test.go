@@ -0,0 +1,137 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/evgfitil/qx/internal/keymap"
+	"github.com/evgfitil/qx/internal/llm"
+)
+
+func newTestFormModel(command string, recent map[string][]string) formModel {
+	return newFormModel(command, FormOptions{
+		Theme:  DefaultTheme(),
+		Keys:   keymap.Default(),
+		Shell:  llm.ShellBash,
+		Recent: recent,
+	})
+}
+
+// sendFormKeys feeds keys to m and returns the resulting model and the
+// command returned by the last one.
+func sendFormKeys(t *testing.T, m formModel, keys ...tea.KeyMsg) (formModel, tea.Cmd) {
+	t.Helper()
+	var cmd tea.Cmd
+	for _, k := range keys {
+		var updated tea.Model
+		updated, cmd = m.Update(k)
+		m = updated.(formModel)
+	}
+	return m, cmd
+}
+
+func TestFormModel_FillsEveryPlaceholder(t *testing.T) {
+	m := newTestFormModel("aws s3 cp {{file:path}} s3://{{bucket:string}}/ --expires {{days:number}}", nil)
+	if len(m.fields) != 3 {
+		t.Fatalf("fields = %d, want 3", len(m.fields))
+	}
+
+	enter := tea.KeyMsg{Type: tea.KeyEnter}
+	m, _ = sendFormKeys(t, m, runeKey("my report.pdf"), enter, runeKey("logs"), enter)
+	if m.focus != 2 || m.done {
+		t.Fatalf("focus = %d, done = %v, want the third field", m.focus, m.done)
+	}
+	if view := m.View(); !strings.Contains(view, "aws s3 cp 'my report.pdf' s3://logs/ --expires {{days:number}}") {
+		t.Errorf("View() should preview the command with the values so far:\n%s", view)
+	}
+
+	m, _ = sendFormKeys(t, m, runeKey("seven"), enter)
+	if m.done || !strings.Contains(m.err, "must be a number") {
+		t.Fatalf("a non-numeric value should be refused, err = %q", m.err)
+	}
+
+	m, _ = sendFormKeys(t, m, tea.KeyMsg{Type: tea.KeyCtrlU}, runeKey("7"), enter)
+	if !m.done || m.cancelled {
+		t.Fatal("enter on the last field should submit the form")
+	}
+	want := map[string]string{"file": "my report.pdf", "bucket": "logs", "days": "7"}
+	if got := m.values(); !reflect.DeepEqual(got, want) {
+		t.Errorf("values() = %v, want %v", got, want)
+	}
+}
+
+func TestFormModel_RequiresValue(t *testing.T) {
+	m, _ := sendFormKeys(t, newTestFormModel("ssh {{host}}", nil), tea.KeyMsg{Type: tea.KeyEnter})
+	if m.done || !strings.Contains(m.err, "host is required") {
+		t.Errorf("empty value should be refused, done = %v, err = %q", m.done, m.err)
+	}
+}
+
+func TestFormModel_RecentValues(t *testing.T) {
+	m := newTestFormModel("ssh {{host}}", map[string][]string{"host": {"web-1", "db-1"}})
+	if got := m.fields[0].input.Value(); got != "web-1" {
+		t.Fatalf("input = %q, want the most recent value", got)
+	}
+
+	m, _ = sendFormKeys(t, m, tea.KeyMsg{Type: tea.KeyUp})
+	if got := m.fields[0].input.Value(); got != "db-1" {
+		t.Errorf("up should show the older value, got %q", got)
+	}
+	m, _ = sendFormKeys(t, m, tea.KeyMsg{Type: tea.KeyUp}, tea.KeyMsg{Type: tea.KeyDown})
+	if got := m.fields[0].input.Value(); got != "web-1" {
+		t.Errorf("down should show the newer value, got %q", got)
+	}
+}
+
+func TestFormModel_ShiftTabGoesBack(t *testing.T) {
+	m, _ := sendFormKeys(t, newTestFormModel("scp {{src:path}} {{host}}:", nil),
+		runeKey("a.txt"), tea.KeyMsg{Type: tea.KeyEnter}, tea.KeyMsg{Type: tea.KeyShiftTab})
+	if m.focus != 0 {
+		t.Errorf("focus = %d, want 0", m.focus)
+	}
+}
+
+func TestFormModel_Cancel(t *testing.T) {
+	for _, k := range []tea.KeyMsg{{Type: tea.KeyEsc}, {Type: tea.KeyCtrlC}} {
+		m, cmd := sendFormKeys(t, newTestFormModel("ssh {{host}}", nil), k)
+		if cmd == nil || !m.cancelled {
+			t.Errorf("%s should cancel the form", k)
+		}
+	}
+}
+
+func TestFormModel_PathCompletion(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"report.pdf", "reports", ".hidden"} {
+		path := filepath.Join(dir, name)
+		var err error
+		if name == "reports" {
+			err = os.Mkdir(path, 0o755)
+		} else {
+			err = os.WriteFile(path, nil, 0o600)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := completePath(dir + "/rep")
+	want := []string{dir + "/report.pdf", dir + "/reports/"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("completePath() = %v, want %v", got, want)
+	}
+	if got := completePath(dir + "/."); !reflect.DeepEqual(got, []string{dir + "/.hidden"}) {
+		t.Errorf("completePath() with a dot = %v, want the hidden file", got)
+	}
+
+	m := newTestFormModel("open {{file:path}}", nil)
+	m, _ = sendFormKeys(t, m, runeKey(dir+"/reports"), tea.KeyMsg{Type: tea.KeyTab})
+	if got := m.fields[0].input.Value(); got != dir+"/reports/" {
+		t.Errorf("tab should complete the path, got %q", got)
+	}
+}
@@ -0,0 +1,59 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+
+	"github.com/evgfitil/qx/internal/keymap"
+)
+
+// keyBindings holds the TUI bindings built from a keymap.KeyMap.
+type keyBindings struct {
+	up      key.Binding
+	down    key.Binding
+	accept  key.Binding
+	newline key.Binding
+	search  key.Binding
+	score   key.Binding
+	cancel  key.Binding
+	help    key.Binding
+	keymap  keymap.KeyMap
+}
+
+// newKeyBindings converts km into bubbletea bindings. Actions missing
+// from km use the default bindings.
+func newKeyBindings(km keymap.KeyMap) keyBindings {
+	km = km.WithDefaults()
+	return keyBindings{
+		up:      key.NewBinding(key.WithKeys(km.Up...)),
+		down:    key.NewBinding(key.WithKeys(km.Down...)),
+		accept:  key.NewBinding(key.WithKeys(km.Accept...)),
+		newline: key.NewBinding(key.WithKeys(km.Newline...)),
+		search:  key.NewBinding(key.WithKeys(km.Search...)),
+		score:   key.NewBinding(key.WithKeys(km.Score...)),
+		cancel:  key.NewBinding(key.WithKeys(km.Cancel...)),
+		help:    key.NewBinding(key.WithKeys(km.Help...)),
+		keymap:  km,
+	}
+}
+
+// viewHelp renders the key binding overlay shown on the help key.
+func (m Model) viewHelp() string {
+	var content strings.Builder
+	content.WriteString(m.theme.NormalStyle().Render("Key bindings"))
+	content.WriteString("\n")
+	for _, line := range m.keys.keymap.HelpLines() {
+		content.WriteString(m.theme.MutedStyle().Render(line))
+		content.WriteString("\n")
+	}
+	content.WriteString(m.theme.MutedStyle().Render("quit    ctrl+c"))
+	content.WriteString("\n\n")
+	content.WriteString(m.theme.MutedStyle().Render("press any key to close"))
+
+	borderStyle := m.theme.BorderStyle()
+	if m.width > 0 {
+		borderStyle = borderStyle.Width(m.width - 2)
+	}
+	return borderStyle.Render(content.String()) + "\n"
+}
@@ -0,0 +1,133 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/evgfitil/qx/internal/keymap"
+)
+
+func newSelectModelWithKeys(commands []string, km keymap.KeyMap) Model {
+	m := newModel(RunOptions{
+		InitialQuery: "test",
+		Theme:        DefaultTheme(),
+		Keys:         km,
+	})
+	updated, _ := m.Update(commandsMsg{commands: commands})
+	return updated.(Model)
+}
+
+func TestCustomKeysNavigateSelector(t *testing.T) {
+	km, err := keymap.Preset(keymap.PresetVi)
+	if err != nil {
+		t.Fatalf("Preset(vi) returned error: %v", err)
+	}
+	m := newSelectModelWithKeys([]string{"cmd1", "cmd2", "cmd3"}, km)
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlJ})
+	m = updated.(Model)
+	if m.cursor != 1 {
+		t.Errorf("cursor = %d, want 1 after ctrl+j", m.cursor)
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyCtrlK})
+	m = updated.(Model)
+	if m.cursor != 0 {
+		t.Errorf("cursor = %d, want 0 after ctrl+k", m.cursor)
+	}
+
+	if m.textArea.Value() != "" {
+		t.Errorf("filter = %q, navigation keys should not reach the filter", m.textArea.Value())
+	}
+}
+
+func TestArrowKeysStillWorkWithPreset(t *testing.T) {
+	km, _ := keymap.Preset(keymap.PresetEmacs)
+	m := newSelectModelWithKeys([]string{"cmd1", "cmd2"}, km)
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m = updated.(Model)
+	if m.cursor != 1 {
+		t.Errorf("cursor = %d, want 1 after down", m.cursor)
+	}
+}
+
+func TestReboundCancelKey(t *testing.T) {
+	km := keymap.Default()
+	km.Cancel = []string{"ctrl+g"}
+	m := newModel(RunOptions{Theme: DefaultTheme(), Keys: km})
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if updated.(Model).state == stateDone {
+		t.Error("esc should not quit when cancel is rebound")
+	}
+
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlG})
+	if updated.(Model).state != stateDone || cmd == nil {
+		t.Error("ctrl+g should quit when bound to cancel")
+	}
+}
+
+func TestCtrlCQuitsWithReboundCancel(t *testing.T) {
+	km := keymap.Default()
+	km.Cancel = []string{"ctrl+g"}
+	m := newModel(RunOptions{Theme: DefaultTheme(), Keys: km})
+
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlC})
+	if updated.(Model).state != stateDone || cmd == nil {
+		t.Error("ctrl+c should always quit")
+	}
+}
+
+func TestHelpOverlayOnEmptyInput(t *testing.T) {
+	m := newModel(RunOptions{Theme: DefaultTheme()})
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'?'}})
+	m = updated.(Model)
+
+	if !m.showHelp {
+		t.Fatal("showHelp = false, want true after ? on empty input")
+	}
+	view := m.View()
+	for _, want := range []string{"Key bindings", "up", "down", "ctrl+c"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("help view should contain %q", want)
+		}
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}})
+	m = updated.(Model)
+	if m.showHelp {
+		t.Error("any key should close the help overlay")
+	}
+	if m.textArea.Value() != "" {
+		t.Errorf("textArea = %q, key closing help should not be typed", m.textArea.Value())
+	}
+}
+
+func TestHelpKeyTypedWhenInputNotEmpty(t *testing.T) {
+	m := newModel(RunOptions{Theme: DefaultTheme(), InitialQuery: "what is this"})
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'?'}})
+	m = updated.(Model)
+
+	if m.showHelp {
+		t.Error("showHelp = true, want ? typed into non-empty input")
+	}
+	if m.textArea.Value() != "what is this?" {
+		t.Errorf("textArea = %q, want %q", m.textArea.Value(), "what is this?")
+	}
+}
+
+func TestNewKeyBindingsFillsDefaults(t *testing.T) {
+	kb := newKeyBindings(keymap.KeyMap{Up: []string{"ctrl+k"}})
+
+	if got := strings.Join(kb.down.Keys(), ","); got != "down" {
+		t.Errorf("down keys = %q, want default %q", got, "down")
+	}
+	if got := strings.Join(kb.up.Keys(), ","); got != "ctrl+k" {
+		t.Errorf("up keys = %q, want %q", got, "ctrl+k")
+	}
+}
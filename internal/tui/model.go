@@ -10,9 +10,13 @@ import (
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textarea"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 
+	"github.com/evgfitil/qx/internal/action"
 	"github.com/evgfitil/qx/internal/guard"
-	"github.com/evgfitil/qx/internal/llm"
+	"github.com/evgfitil/qx/internal/keymap"
+	"github.com/evgfitil/qx/internal/rank"
+	"github.com/evgfitil/qx/pkg/qx"
 )
 
 type state int
@@ -29,14 +33,27 @@ const (
 	minHeight        = 5
 	reservedLines    = 4 // border top + textarea + counter + border bottom
 	generateTimeout  = 60 * time.Second
+	prewarmTimeout   = 10 * time.Second
 )
 
-// commandsMsg is sent when LLM returns generated commands.
+// DefaultCharLimit is the maximum query length in the input.
+const DefaultCharLimit = 2000
+
+// commandsMsg is sent when LLM returns generated commands, best first.
+// id identifies the generation so results of a cancelled one are ignored.
 type commandsMsg struct {
+	id       int
 	commands []string
+	scores   []rank.Score
+	cached   bool // served from the response cache
 	err      error
 }
 
+// startGenerationMsg starts the generation of a model created in the
+// loading state. Init cannot start it itself: its receiver is a copy, so
+// the cancel func it stores would be lost.
+type startGenerationMsg struct{}
+
 // Model represents the unified TUI state machine.
 type Model struct {
 	state         state
@@ -44,6 +61,10 @@ type Model struct {
 	textArea      textarea.Model
 	spinner       spinner.Model
 	commands      []string
+	scores        []rank.Score // by index into commands or items, nil if unranked
+	showScores    bool
+	cached        bool
+	rankOpts      rank.Options
 	filtered      []string
 	filteredIdx   []int
 	cursor        int
@@ -51,14 +72,32 @@ type Model struct {
 	selected      string
 	prevFilter    string
 	err           error
-	llmConfig     llm.Config
+	llmConfig     qx.ProviderConfig
 	forceSend     bool
 	pipeContext   string
+	pipeSummary   string
 	width         int
 	height        int
 	maxHeight     int
 	originalQuery string
 	quitting      bool
+	keys          keyBindings
+	showHelp      bool
+	recall        recallState
+
+	// in-flight generation
+	genID     int
+	genCancel context.CancelFunc
+	client    *qx.Client
+	prewarm   bool
+	prewarmFn func(ctx context.Context, cfg qx.ProviderConfig) error
+
+	// live preview of read-only commands
+	preview     PreviewOptions
+	previewFn   func(ctx context.Context, command string, maxLines int) (string, error)
+	previews    map[string]previewResult
+	previewCtx  context.Context
+	previewStop context.CancelFunc
 
 	// selector-only mode
 	selectorMode  bool
@@ -83,9 +122,15 @@ func newTextArea(theme Theme) textarea.Model {
 }
 
 func newModel(opts RunOptions) Model {
+	keys := newKeyBindings(opts.Keys)
+
 	ta := newTextArea(opts.Theme)
 	ta.Placeholder = "describe the command you need..."
-	ta.MaxHeight = 1
+	ta.CharLimit = opts.CharLimit
+	if ta.CharLimit <= 0 {
+		ta.CharLimit = DefaultCharLimit
+	}
+	ta.KeyMap.InsertNewline = keys.newline
 	ta.SetHeight(1)
 
 	s := spinner.New()
@@ -97,7 +142,9 @@ func newModel(opts RunOptions) Model {
 		ta.CursorEnd()
 	}
 
-	return Model{
+	previewCtx, previewStop := context.WithCancel(context.Background())
+
+	m := Model{
 		state:         stateInput,
 		theme:         opts.Theme,
 		textArea:      ta,
@@ -105,9 +152,23 @@ func newModel(opts RunOptions) Model {
 		llmConfig:     opts.LLMConfig,
 		forceSend:     opts.ForceSend,
 		pipeContext:   opts.PipeContext,
+		pipeSummary:   opts.PipeSummary,
+		rankOpts:      rank.Options{Shell: opts.Shell, Accepted: opts.Accepted},
 		maxHeight:     minHeight,
 		selectedIndex: -1,
+		keys:          keys,
+		recall:        newRecallState(opts.History),
+		preview:       opts.Preview,
+		previewFn:     action.Preview,
+		client:        opts.Client,
+		prewarm:       opts.Prewarm,
+		prewarmFn:     qx.Prewarm,
+		previews:      make(map[string]previewResult),
+		previewCtx:    previewCtx,
+		previewStop:   previewStop,
 	}
+	m.fitInput()
+	return m
 }
 
 func newSelectorModel(items []string, display func(int) string, theme Theme) Model {
@@ -137,55 +198,142 @@ func newSelectorModel(items []string, display func(int) string, theme Theme) Mod
 		filteredIdx:   idx,
 		displayFn:     display,
 		selectedIndex: -1,
+		keys:          newKeyBindings(keymap.Default()),
+		recall:        newRecallState(nil),
 	}
 }
 
 // Init implements tea.Model.
 func (m Model) Init() tea.Cmd {
 	if m.state == stateLoading {
-		return tea.Batch(
-			m.spinner.Tick,
-			generateCommands(m.originalQuery, m.llmConfig, m.pipeContext),
-		)
+		return tea.Batch(m.spinner.Tick, func() tea.Msg { return startGenerationMsg{} })
+	}
+	if m.prewarm {
+		return tea.Batch(textarea.Blink, m.prewarmConnection())
 	}
 	return textarea.Blink
 }
 
+// prewarmConnection opens the API connection in the background so the
+// first generation does not wait for it. Failures are ignored: the
+// request itself reports them.
+func (m Model) prewarmConnection() tea.Cmd {
+	cfg, prewarm := m.llmConfig, m.prewarmFn
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), prewarmTimeout)
+		defer cancel()
+		_ = prewarm(ctx, cfg)
+		return nil
+	}
+}
+
 // Update implements tea.Model.
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmds []tea.Cmd
 
 	switch msg := msg.(type) {
+	case startGenerationMsg:
+		if m.state == stateLoading && m.genCancel == nil {
+			return m, m.startGeneration(m.originalQuery)
+		}
+		return m, nil
+
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
 		m.maxHeight = max(msg.Height*maxHeightPercent/100, minHeight)
 		m.textArea.SetWidth(msg.Width - 2)
+		if m.state == stateInput {
+			m.fitInput()
+		}
 
 	case tea.KeyMsg:
-		switch msg.Type {
-		case tea.KeyCtrlC, tea.KeyEsc:
+		if msg.Type == tea.KeyCtrlC {
+			m.stopGeneration()
+			m.state = stateDone
+			m.quitting = true
+			return m, tea.Quit
+		}
+
+		if m.showHelp {
+			m.showHelp = false
+			return m, nil
+		}
+
+		if m.recall.searching {
+			return m.updateSearch(msg)
+		}
+
+		switch {
+		case key.Matches(msg, m.keys.cancel):
+			if m.state == stateLoading {
+				return m.cancelGeneration()
+			}
 			m.state = stateDone
 			m.quitting = true
 			return m, tea.Quit
 
-		case tea.KeyEnter:
+		case key.Matches(msg, m.keys.accept):
 			return m.handleEnter()
 
-		case tea.KeyUp:
-			if m.state == stateSelect {
+		case key.Matches(msg, m.keys.up):
+			switch m.state {
+			case stateSelect:
 				m.moveCursor(-1)
+				return m, m.previewCurrent()
+			case stateInput:
+				if li := m.textArea.LineInfo(); m.textArea.Line() > 0 || li.RowOffset > 0 {
+					m.textArea.CursorUp()
+				} else {
+					m.recallQuery(1)
+				}
 				return m, nil
 			}
 
-		case tea.KeyDown:
-			if m.state == stateSelect {
+		case key.Matches(msg, m.keys.down):
+			switch m.state {
+			case stateSelect:
 				m.moveCursor(1)
+				return m, m.previewCurrent()
+			case stateInput:
+				if li := m.textArea.LineInfo(); m.textArea.Line() < m.textArea.LineCount()-1 || li.RowOffset < li.Height-1 {
+					m.textArea.CursorDown()
+				} else {
+					m.recallQuery(-1)
+				}
+				return m, nil
+			}
+
+		case key.Matches(msg, m.keys.search):
+			if m.state == stateInput && len(m.recall.history) > 0 {
+				m.startSearch()
+				return m, nil
+			}
+
+		case key.Matches(msg, m.keys.score):
+			if m.state == stateSelect && m.scores != nil {
+				m.showScores = !m.showScores
+				return m, nil
+			}
+
+		case key.Matches(msg, m.keys.help):
+			// Only an empty input opens help, so the key can still be typed.
+			if (m.state == stateInput || m.state == stateSelect) && m.textArea.Value() == "" {
+				m.showHelp = true
 				return m, nil
 			}
 		}
 
+	case previewMsg:
+		m.previews[msg.command] = previewResult{readOnly: true, output: msg.output, err: msg.err}
+		return m, nil
+
 	case commandsMsg:
+		if msg.id != m.genID {
+			return m, nil
+		}
+		m.stopGeneration()
+
 		if msg.err != nil {
 			m.err = msg.err
 			m.state = stateInput
@@ -199,6 +347,8 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 		m.commands = msg.commands
+		m.scores = msg.scores
+		m.cached = msg.cached
 		m.filtered = msg.commands
 		m.filteredIdx = make([]int, len(msg.commands))
 		for i := range msg.commands {
@@ -216,11 +366,12 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		m.textArea.SetValue("")
 		m.textArea.Placeholder = "filter..."
+		m.textArea.KeyMap.InsertNewline.SetEnabled(false)
 		m.textArea.MaxHeight = 1
 		m.textArea.SetHeight(1)
 		m.prevFilter = ""
 		m.state = stateSelect
-		return m, nil
+		return m, m.previewCurrent()
 
 	case spinner.TickMsg:
 		if m.state == stateLoading {
@@ -231,15 +382,24 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 
 	if m.state == stateInput || m.state == stateSelect {
+		before := m.textArea.Value()
 		var cmd tea.Cmd
 		m.textArea, cmd = m.textArea.Update(msg)
 		cmds = append(cmds, cmd)
+		if m.textArea.Value() != before {
+			m.resetRecall()
+		}
+	}
+
+	if m.state == stateInput {
+		m.fitInput()
 	}
 
 	if m.state == stateSelect {
 		if current := m.textArea.Value(); current != m.prevFilter {
 			m.prevFilter = current
 			m.applyFilter()
+			cmds = append(cmds, m.previewCurrent())
 		}
 	}
 
@@ -262,10 +422,7 @@ func (m Model) handleEnter() (tea.Model, tea.Cmd) {
 		m.state = stateLoading
 		m.originalQuery = query
 		m.err = nil
-		return m, tea.Batch(
-			m.spinner.Tick,
-			generateCommands(query, m.llmConfig, m.pipeContext),
-		)
+		return m, tea.Batch(m.spinner.Tick, m.startGeneration(query))
 
 	case stateSelect:
 		if len(m.filtered) == 0 {
@@ -283,27 +440,69 @@ func (m Model) handleEnter() (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
-func generateCommands(query string, cfg llm.Config, pipeContext string) tea.Cmd {
-	return func() tea.Msg {
-		provider, err := llm.NewProvider(cfg)
-		if err != nil {
-			return commandsMsg{err: err}
-		}
+// startGeneration starts an LLM request for query that can be cancelled
+// with cancelGeneration.
+func (m *Model) startGeneration(query string) tea.Cmd {
+	ctx, cancel := context.WithTimeout(context.Background(), generateTimeout)
+	m.genCancel = cancel
+	return generateCommands(ctx, m.genID, m.client, qx.Request{Query: query, PipeContext: m.pipeContext}, m.rankOpts)
+}
 
-		ctx, cancel := context.WithTimeout(context.Background(), generateTimeout)
-		defer cancel()
+// stopGeneration releases the context of the current generation,
+// aborting the HTTP request if it is still in flight.
+func (m *Model) stopGeneration() {
+	if m.genCancel != nil {
+		m.genCancel()
+		m.genCancel = nil
+	}
+}
+
+// cancelGeneration aborts the in-flight request and returns to the input
+// with the query kept for editing. The late result, if any, is ignored.
+func (m Model) cancelGeneration() (tea.Model, tea.Cmd) {
+	m.stopGeneration()
+	m.genID++
+	m.state = stateInput
+	m.setInput(m.originalQuery)
+	m.originalQuery = ""
+	return m, textarea.Blink
+}
 
-		commands, err := provider.Generate(ctx, query, cfg.Count, pipeContext, nil)
+// generateCommands runs req on client, which checks it for secrets,
+// collects the context and sanitizes the variants, and ranks the result.
+func generateCommands(ctx context.Context, id int, client *qx.Client, req qx.Request, rankOpts rank.Options) tea.Cmd {
+	return func() tea.Msg {
+		variants, err := client.Generate(ctx, req)
 		if err != nil {
-			return commandsMsg{err: err}
+			return commandsMsg{id: id, err: err}
 		}
 
-		for i, cmd := range commands {
-			commands[i] = guard.SanitizeOutput(cmd)
+		msg := commandsMsg{id: id, cached: variants[0].Cached}
+		for _, v := range rank.Rank(variants, rankOpts) {
+			msg.commands = append(msg.commands, v.Command)
+			msg.scores = append(msg.scores, v.Score)
 		}
+		return msg
+	}
+}
+
+// fitInput grows the input to show the whole query, keeping one line of
+// the height budget free for errors.
+func (m *Model) fitInput() {
+	budget := max(m.maxHeight-1, 1)
+	m.textArea.SetHeight(min(inputRows(m.textArea.Value(), m.textArea.Width()), budget))
+}
 
-		return commandsMsg{commands: commands}
+// inputRows returns how many rows value takes when soft-wrapped at width.
+func inputRows(value string, width int) int {
+	rows := 0
+	for _, line := range strings.Split(value, "\n") {
+		rows++
+		if width > 0 {
+			rows += lipgloss.Width(line) / width
+		}
 	}
+	return rows
 }
 
 func (m *Model) moveCursor(delta int) {
@@ -374,6 +573,15 @@ func (m Model) getDisplayText(filteredIndex int) string {
 	return m.filtered[filteredIndex]
 }
 
+// scoreOf returns the ranking score of the filtered item at
+// filteredIndex, if the items are ranked.
+func (m Model) scoreOf(filteredIndex int) (rank.Score, bool) {
+	if filteredIndex >= len(m.filteredIdx) || m.filteredIdx[filteredIndex] >= len(m.scores) {
+		return rank.Score{}, false
+	}
+	return m.scores[m.filteredIdx[filteredIndex]], true
+}
+
 // Result returns the outcome of TUI interaction.
 func (m Model) Result() Result {
 	if m.selected != "" {
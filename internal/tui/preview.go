@@ -0,0 +1,152 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/evgfitil/qx/internal/action"
+	"github.com/evgfitil/qx/internal/guard"
+	"github.com/evgfitil/qx/internal/placeholder"
+)
+
+// PreviewOptions configures the live preview pane shown under the selector.
+type PreviewOptions struct {
+	Enabled bool
+	Lines   int
+	Timeout time.Duration
+}
+
+// previewMsg is sent when a background preview run finishes.
+type previewMsg struct {
+	command string
+	output  string
+	err     error
+}
+
+// previewResult holds the preview state of a single command.
+type previewResult struct {
+	running      bool
+	readOnly     bool
+	placeholders bool
+	output       string
+	err          error
+}
+
+func (m Model) previewEnabled() bool {
+	return m.preview.Enabled && !m.selectorMode
+}
+
+// previewCurrent starts a background preview of the highlighted command
+// unless it was already previewed or is not classified as read-only.
+func (m *Model) previewCurrent() tea.Cmd {
+	if !m.previewEnabled() || m.state != stateSelect || len(m.filtered) == 0 {
+		return nil
+	}
+
+	command := m.filtered[m.cursor]
+	if _, ok := m.previews[command]; ok {
+		return nil
+	}
+	if placeholder.Has(command) {
+		m.previews[command] = previewResult{placeholders: true}
+		return nil
+	}
+	if !guard.IsReadOnly(command) {
+		m.previews[command] = previewResult{}
+		return nil
+	}
+	m.previews[command] = previewResult{running: true, readOnly: true}
+
+	ctx, lines, timeout, run := m.previewCtx, m.preview.Lines, m.preview.Timeout, m.previewFn
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		output, err := run(ctx, command, lines)
+		return previewMsg{command: command, output: output, err: err}
+	}
+}
+
+// stopPreviews kills any preview commands still running.
+func (m Model) stopPreviews() {
+	if m.previewStop != nil {
+		m.previewStop()
+	}
+}
+
+// previewHeight returns how many output lines fit under the selector list
+// without exceeding maxHeight. One extra line is taken by the pane header.
+func (m Model) previewHeight() int {
+	if !m.previewEnabled() {
+		return 0
+	}
+	items := min(len(m.filtered), m.visibleItemCount())
+	return max(min(m.preview.Lines, m.maxHeight-reservedLines-items-1), 0)
+}
+
+// viewPreview renders the preview pane for the highlighted command.
+func (m Model) viewPreview() string {
+	height := m.previewHeight()
+	if height == 0 || len(m.filtered) == 0 {
+		return ""
+	}
+
+	res := m.previews[m.filtered[m.cursor]]
+	muted := m.theme.MutedStyle()
+
+	var status string
+	switch {
+	case res.placeholders:
+		status = "not run: fill in the placeholders first"
+	case !res.readOnly:
+		status = "not run: command may change state"
+	case res.running:
+		status = "running..."
+	case errors.Is(res.err, action.ErrPreviewTimeout):
+		status = "timed out"
+	case res.err != nil:
+		status = res.err.Error()
+	case res.output == "":
+		status = "no output"
+	}
+
+	var b strings.Builder
+	header := "preview"
+	if status != "" {
+		header = fmt.Sprintf("preview: %s", status)
+	}
+	b.WriteString("\n")
+	b.WriteString(muted.Render("── " + header))
+
+	if res.output == "" {
+		return b.String()
+	}
+
+	width := m.width - 4
+	lines := strings.Split(guard.SanitizeOutput(strings.ReplaceAll(res.output, "\t", "    ")), "\n")
+	for i, line := range lines {
+		if i == height {
+			break
+		}
+		b.WriteString("\n")
+		b.WriteString(muted.Render(truncateLine(line, width)))
+	}
+	return b.String()
+}
+
+// truncateLine cuts s to at most width runes so a line never wraps inside
+// the bordered pane. A non-positive width leaves s unchanged.
+func truncateLine(s string, width int) string {
+	if width <= 0 {
+		return s
+	}
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-1]) + "…"
+}
@@ -0,0 +1,196 @@
+package tui
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/evgfitil/qx/internal/action"
+)
+
+func newPreviewModel() Model {
+	m := newModel(RunOptions{
+		InitialQuery: "test",
+		Theme:        DefaultTheme(),
+		Preview:      PreviewOptions{Enabled: true, Lines: 3, Timeout: time.Second},
+	})
+	m.previewFn = func(_ context.Context, command string, _ int) (string, error) {
+		return "output of " + command, nil
+	}
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+	return updated.(Model)
+}
+
+// runCmd executes a tea.Cmd and feeds the resulting message back into the model.
+func runCmd(t *testing.T, m Model, cmd tea.Cmd) Model {
+	t.Helper()
+	if cmd == nil {
+		t.Fatal("cmd = nil, want preview command")
+	}
+	updated, _ := m.Update(cmd())
+	return updated.(Model)
+}
+
+func TestPreviewStartsForReadOnlyCommand(t *testing.T) {
+	m := newPreviewModel()
+
+	updated, cmd := m.Update(commandsMsg{commands: []string{"ls -la", "rm -rf build"}})
+	m = updated.(Model)
+
+	if !m.previews["ls -la"].running {
+		t.Error("preview should be running for read-only command")
+	}
+
+	m = runCmd(t, m, cmd)
+	res := m.previews["ls -la"]
+	if res.running {
+		t.Error("preview should no longer be running after previewMsg")
+	}
+	if res.output != "output of ls -la" {
+		t.Errorf("output = %q, want %q", res.output, "output of ls -la")
+	}
+	if !strings.Contains(m.View(), "output of ls -la") {
+		t.Error("view should contain preview output")
+	}
+}
+
+func TestPreviewSkipsCommandThatMayChangeState(t *testing.T) {
+	m := newPreviewModel()
+	called := false
+	m.previewFn = func(context.Context, string, int) (string, error) {
+		called = true
+		return "", nil
+	}
+
+	updated, cmd := m.Update(commandsMsg{commands: []string{"rm -rf build", "ls"}})
+	m = updated.(Model)
+
+	if cmd != nil {
+		cmd()
+	}
+	if called {
+		t.Error("preview must not run a command that is not read-only")
+	}
+	if !strings.Contains(m.View(), "not run") {
+		t.Error("view should explain why the preview was not run")
+	}
+}
+
+func TestPreviewSkipsCommandWithPlaceholders(t *testing.T) {
+	m := newPreviewModel()
+	called := false
+	m.previewFn = func(context.Context, string, int) (string, error) {
+		called = true
+		return "", nil
+	}
+
+	updated, cmd := m.Update(commandsMsg{commands: []string{"ls {{dir:path}}", "ls"}})
+	m = updated.(Model)
+
+	if cmd != nil {
+		cmd()
+	}
+	if called {
+		t.Error("preview must not run a command with unfilled placeholders")
+	}
+	if !strings.Contains(m.View(), "fill in the placeholders") {
+		t.Error("view should explain why the preview was not run")
+	}
+}
+
+func TestPreviewRunsOnCursorMove(t *testing.T) {
+	m := newPreviewModel()
+	updated, _ := m.Update(commandsMsg{commands: []string{"rm -rf build", "ls"}})
+	m = updated.(Model)
+
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m = updated.(Model)
+	m = runCmd(t, m, cmd)
+
+	if m.previews["ls"].output != "output of ls" {
+		t.Errorf("output = %q, want %q", m.previews["ls"].output, "output of ls")
+	}
+}
+
+func TestPreviewNotRepeatedForSameCommand(t *testing.T) {
+	m := newPreviewModel()
+	updated, cmd := m.Update(commandsMsg{commands: []string{"ls", "pwd"}})
+	m = updated.(Model)
+	m = runCmd(t, m, cmd)
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m = updated.(Model)
+	updated, cmd = m.Update(tea.KeyMsg{Type: tea.KeyUp})
+	m = updated.(Model)
+
+	if cmd != nil {
+		t.Error("returning to a previewed command should reuse the cached result")
+	}
+}
+
+func TestPreviewShowsTimeout(t *testing.T) {
+	m := newPreviewModel()
+	m.previewFn = func(context.Context, string, int) (string, error) {
+		return "", action.ErrPreviewTimeout
+	}
+	updated, cmd := m.Update(commandsMsg{commands: []string{"ls", "pwd"}})
+	m = runCmd(t, updated.(Model), cmd)
+
+	if !strings.Contains(m.View(), "timed out") {
+		t.Error("view should report preview timeout")
+	}
+}
+
+func TestPreviewDisabledByDefault(t *testing.T) {
+	m := newSelectModel([]string{"ls", "pwd"})
+
+	if m.previewEnabled() {
+		t.Error("preview should be disabled without options")
+	}
+	if strings.Contains(m.View(), "preview") {
+		t.Error("view should not contain preview pane when disabled")
+	}
+}
+
+func TestPreviewDisabledInSelectorMode(t *testing.T) {
+	items := []string{"ls", "pwd"}
+	m := newSelectorModel(items, func(i int) string { return items[i] }, DefaultTheme())
+	m.preview = PreviewOptions{Enabled: true, Lines: 3, Timeout: time.Second}
+
+	if m.previewEnabled() {
+		t.Error("preview should not run for history selector")
+	}
+}
+
+func TestPreviewHeightFitsMaxHeight(t *testing.T) {
+	m := newPreviewModel()
+	m.preview.Lines = 100
+	updated, _ := m.Update(commandsMsg{commands: []string{"ls", "pwd", "id"}})
+	m = updated.(Model)
+
+	want := m.maxHeight - reservedLines - 3 - 1
+	if got := m.previewHeight(); got != want {
+		t.Errorf("previewHeight() = %d, want %d", got, want)
+	}
+}
+
+func TestTruncateLine(t *testing.T) {
+	tests := []struct {
+		in    string
+		width int
+		want  string
+	}{
+		{"short", 10, "short"},
+		{"exactly", 7, "exactly"},
+		{"too long line", 8, "too lon…"},
+		{"unlimited", 0, "unlimited"},
+	}
+	for _, tt := range tests {
+		if got := truncateLine(tt.in, tt.width); got != tt.want {
+			t.Errorf("truncateLine(%q, %d) = %q, want %q", tt.in, tt.width, got, tt.want)
+		}
+	}
+}
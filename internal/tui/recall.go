@@ -0,0 +1,148 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// recallState tracks shell-style browsing of past queries in the input.
+type recallState struct {
+	// history holds past queries, newest first.
+	history []string
+	// index is the position in history shown in the input, -1 when the
+	// input holds the user's own text.
+	index int
+	// prefix limits browsing to queries starting with the text typed
+	// before browsing began.
+	prefix string
+	// draft is restored when browsing moves past the newest query.
+	draft string
+
+	// reverse search (ctrl+r)
+	searching   bool
+	searchTerm  string
+	searchIndex int
+	searchFail  bool
+}
+
+func newRecallState(history []string) recallState {
+	return recallState{history: history, index: -1, searchIndex: -1}
+}
+
+// recallQuery replaces the input with the next older (dir > 0) or newer
+// (dir < 0) past query that starts with the browsing prefix.
+func (m *Model) recallQuery(dir int) {
+	r := &m.recall
+	if r.index == -1 {
+		if dir < 0 {
+			return
+		}
+		r.draft = m.textArea.Value()
+		r.prefix = r.draft
+	}
+
+	current := m.textArea.Value()
+	for i := r.index + dir; i >= 0 && i < len(r.history); i += dir {
+		if q := r.history[i]; strings.HasPrefix(q, r.prefix) && q != current {
+			r.index = i
+			m.setInput(q)
+			return
+		}
+	}
+
+	if dir < 0 {
+		r.index = -1
+		m.setInput(r.draft)
+	}
+}
+
+// resetRecall ends browsing so the next up key starts from the newest
+// query again, filtered by whatever the input holds then.
+func (m *Model) resetRecall() {
+	m.recall.index = -1
+}
+
+func (m *Model) setInput(value string) {
+	m.textArea.SetValue(value)
+	m.textArea.CursorEnd()
+	m.fitInput()
+}
+
+// startSearch enters reverse search over past queries.
+func (m *Model) startSearch() {
+	m.recall.searching = true
+	m.recall.searchTerm = ""
+	m.recall.searchIndex = -1
+	m.recall.searchFail = false
+	m.recall.draft = m.textArea.Value()
+}
+
+// search shows the first past query at or after position from that
+// contains the search term, ignoring case.
+func (m *Model) search(from int) {
+	r := &m.recall
+	term := strings.ToLower(r.searchTerm)
+	for i := max(from, 0); i < len(r.history); i++ {
+		if strings.Contains(strings.ToLower(r.history[i]), term) {
+			r.searchIndex = i
+			r.searchFail = false
+			m.setInput(r.history[i])
+			return
+		}
+	}
+	r.searchFail = true
+}
+
+// updateSearch handles keys while reverse search is active. Typing
+// refines the term, the search key jumps to the next older match, accept
+// submits the match and any other key ends the search leaving the match
+// in the input for editing.
+func (m Model) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	r := &m.recall
+	switch {
+	case key.Matches(msg, m.keys.search):
+		if r.searchTerm != "" {
+			m.search(r.searchIndex + 1)
+		}
+		return m, nil
+
+	case key.Matches(msg, m.keys.accept):
+		r.searching = false
+		m.resetRecall()
+		return m.handleEnter()
+
+	case msg.Type == tea.KeyBackspace:
+		if term := []rune(r.searchTerm); len(term) > 0 {
+			r.searchTerm = string(term[:len(term)-1])
+		}
+		if r.searchTerm == "" {
+			r.searchIndex = -1
+			r.searchFail = false
+			m.setInput(r.draft)
+			return m, nil
+		}
+		m.search(0)
+		return m, nil
+
+	case msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace:
+		r.searchTerm += string(msg.Runes)
+		m.search(r.searchIndex)
+		return m, nil
+	}
+
+	r.searching = false
+	m.resetRecall()
+	return m, nil
+}
+
+// viewSearch renders the reverse search status line below the input.
+func (m Model) viewSearch() string {
+	label := "reverse search"
+	if m.recall.searchFail {
+		label = "failing reverse search"
+	}
+	return m.theme.MutedStyle().Render(fmt.Sprintf("%s: %s", label, m.recall.searchTerm)) + "\n"
+}
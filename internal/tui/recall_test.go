@@ -0,0 +1,197 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func newRecallModel(history ...string) Model {
+	return newModel(RunOptions{Theme: DefaultTheme(), History: history})
+}
+
+func press(m Model, msgs ...tea.KeyMsg) Model {
+	for _, msg := range msgs {
+		updated, _ := m.Update(msg)
+		m = updated.(Model)
+	}
+	return m
+}
+
+func typeText(m Model, text string) Model {
+	for _, r := range text {
+		m = press(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	return m
+}
+
+var (
+	keyUp    = tea.KeyMsg{Type: tea.KeyUp}
+	keyDown  = tea.KeyMsg{Type: tea.KeyDown}
+	keyCtrlR = tea.KeyMsg{Type: tea.KeyCtrlR}
+)
+
+func TestRecallUpDown(t *testing.T) {
+	m := newRecallModel("third", "second", "first")
+
+	steps := []struct {
+		key  tea.KeyMsg
+		want string
+	}{
+		{keyUp, "third"},
+		{keyUp, "second"},
+		{keyUp, "first"},
+		{keyUp, "first"},
+		{keyDown, "second"},
+		{keyDown, "third"},
+		{keyDown, ""},
+		{keyDown, ""},
+	}
+	for i, step := range steps {
+		m = press(m, step.key)
+		if got := m.textArea.Value(); got != step.want {
+			t.Fatalf("step %d: input = %q, want %q", i, got, step.want)
+		}
+	}
+}
+
+func TestRecallFiltersByPrefix(t *testing.T) {
+	m := newRecallModel("kubectl logs", "disk usage", "kubectl get pods")
+	m = typeText(m, "kub")
+
+	m = press(m, keyUp)
+	if got := m.textArea.Value(); got != "kubectl logs" {
+		t.Fatalf("input = %q, want %q", got, "kubectl logs")
+	}
+	m = press(m, keyUp)
+	if got := m.textArea.Value(); got != "kubectl get pods" {
+		t.Fatalf("input = %q, want %q", got, "kubectl get pods")
+	}
+	m = press(m, keyDown, keyDown)
+	if got := m.textArea.Value(); got != "kub" {
+		t.Errorf("input = %q, want typed prefix restored", got)
+	}
+}
+
+func TestRecallResetsAfterEditing(t *testing.T) {
+	m := newRecallModel("list pods", "list files", "disk usage")
+
+	m = press(m, keyUp)
+	m = typeText(m, " now")
+	m = press(m, keyUp)
+
+	if got := m.textArea.Value(); got != "list pods now" {
+		t.Errorf("input = %q, editing should start a new prefix search", got)
+	}
+}
+
+func TestRecallWithoutHistory(t *testing.T) {
+	m := newRecallModel()
+	m = typeText(m, "query")
+	m = press(m, keyUp, keyDown)
+
+	if got := m.textArea.Value(); got != "query" {
+		t.Errorf("input = %q, want %q", got, "query")
+	}
+}
+
+func TestReverseSearch(t *testing.T) {
+	m := newRecallModel("kubectl get pods", "disk usage", "kubectl logs app")
+
+	m = press(m, keyCtrlR)
+	if !m.recall.searching {
+		t.Fatal("ctrl+r should start reverse search")
+	}
+	m = typeText(m, "KUBE")
+	if got := m.textArea.Value(); got != "kubectl get pods" {
+		t.Fatalf("input = %q, want newest match", got)
+	}
+	if !strings.Contains(m.View(), "reverse search: KUBE") {
+		t.Error("view should show the search term")
+	}
+
+	m = press(m, keyCtrlR)
+	if got := m.textArea.Value(); got != "kubectl logs app" {
+		t.Fatalf("input = %q, ctrl+r should move to the next older match", got)
+	}
+
+	m = press(m, keyCtrlR)
+	if got := m.textArea.Value(); got != "kubectl logs app" {
+		t.Errorf("input = %q, want last match kept", got)
+	}
+	if !strings.Contains(m.View(), "failing reverse search") {
+		t.Error("view should report a failed search")
+	}
+}
+
+func TestReverseSearchNoMatchKeepsInput(t *testing.T) {
+	m := newRecallModel("disk usage")
+	m = typeText(m, "draft")
+
+	m = press(m, keyCtrlR)
+	m = typeText(m, "zzz")
+
+	if got := m.textArea.Value(); got != "draft" {
+		t.Errorf("input = %q, want draft kept", got)
+	}
+	if !m.recall.searchFail {
+		t.Error("searchFail = false, want true")
+	}
+}
+
+func TestReverseSearchBackspace(t *testing.T) {
+	m := newRecallModel("disk usage", "docker ps")
+	m = press(m, keyCtrlR)
+	m = typeText(m, "dock")
+	m = press(m, tea.KeyMsg{Type: tea.KeyBackspace})
+
+	if m.recall.searchTerm != "doc" {
+		t.Errorf("searchTerm = %q, want %q", m.recall.searchTerm, "doc")
+	}
+	if got := m.textArea.Value(); got != "docker ps" {
+		t.Errorf("input = %q, want %q", got, "docker ps")
+	}
+}
+
+func TestReverseSearchCancelKeepsMatchForEditing(t *testing.T) {
+	m := newRecallModel("disk usage")
+	m = press(m, keyCtrlR)
+	m = typeText(m, "disk")
+
+	m = press(m, tea.KeyMsg{Type: tea.KeyEsc})
+	if m.state != stateInput {
+		t.Fatalf("state = %d, esc during search should not quit", m.state)
+	}
+	if m.recall.searching {
+		t.Error("esc should end reverse search")
+	}
+	if got := m.textArea.Value(); got != "disk usage" {
+		t.Errorf("input = %q, want match kept", got)
+	}
+}
+
+func TestReverseSearchAcceptSubmits(t *testing.T) {
+	m := newRecallModel("disk usage")
+	m = press(m, keyCtrlR)
+	m = typeText(m, "disk")
+
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updated.(Model)
+	if m.state != stateLoading || cmd == nil {
+		t.Fatalf("state = %d, enter should submit the match", m.state)
+	}
+	m.stopGeneration()
+	if m.originalQuery != "disk usage" {
+		t.Errorf("originalQuery = %q, want %q", m.originalQuery, "disk usage")
+	}
+}
+
+func TestReverseSearchIgnoredWithoutHistory(t *testing.T) {
+	m := newRecallModel()
+	m = press(m, keyCtrlR)
+
+	if m.recall.searching {
+		t.Error("reverse search should not start without history")
+	}
+}
@@ -8,16 +8,38 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"golang.org/x/term"
 
+	"github.com/evgfitil/qx/internal/keymap"
 	"github.com/evgfitil/qx/internal/llm"
+	"github.com/evgfitil/qx/internal/rank"
+	"github.com/evgfitil/qx/pkg/qx"
 )
 
 // RunOptions configures the TUI run behavior.
 type RunOptions struct {
 	InitialQuery string
-	LLMConfig    llm.Config
 	ForceSend    bool
 	PipeContext  string
 	Theme        Theme
+	Preview      PreviewOptions
+	Keys         keymap.KeyMap
+	// CharLimit caps the query length; DefaultCharLimit is used when zero.
+	CharLimit int
+	// Shell is the dialect of the generated commands, used to rank them.
+	Shell llm.Shell
+	// PipeSummary tells how much of the piped input is sent, e.g.
+	// "sent 32.0KB of 2.1MB (dedupe)".
+	PipeSummary string
+	// History holds past queries, newest first, for recall in the input.
+	History []string
+	// Accepted holds commands picked before, which rank variants that
+	// resemble them higher.
+	Accepted []string
+	// Prewarm opens the API connection of LLMConfig while the user types
+	// the query.
+	Prewarm   bool
+	LLMConfig qx.ProviderConfig
+	// Client generates the commands with its shell, count and context.
+	Client *qx.Client
 }
 
 // saveTermState saves the current terminal state from /dev/tty and returns
@@ -66,6 +88,7 @@ func Run(opts RunOptions) (Result, error) {
 
 	result, err := p.Run()
 	restore()
+	m.stopPreviews()
 	if err != nil {
 		return nil, fmt.Errorf("TUI error: %w", err)
 	}
@@ -79,7 +102,7 @@ func Run(opts RunOptions) (Result, error) {
 
 // RunSelector starts a selector-only TUI for picking from a list of items.
 // Returns the selected index or -1 if cancelled.
-func RunSelector(items []string, display func(int) string, theme Theme) (int, error) {
+func RunSelector(items []string, display func(int) string, theme Theme, keys keymap.KeyMap) (int, error) {
 	tty, theme := openTTY(theme)
 	if tty != os.Stdout {
 		defer tty.Close() //nolint:errcheck
@@ -87,6 +110,7 @@ func RunSelector(items []string, display func(int) string, theme Theme) (int, er
 
 	restore := saveTermState()
 	m := newSelectorModel(items, display, theme)
+	m.keys = newKeyBindings(keys)
 	p := tea.NewProgram(m, tea.WithOutput(tty), tea.WithInputTTY())
 
 	result, err := p.Run()
@@ -101,3 +125,43 @@ func RunSelector(items []string, display func(int) string, theme Theme) (int, er
 	}
 	return model.selectedIndex, nil
 }
+
+// RunRankedSelector starts a selector-only TUI for picking one of ranked
+// variants, whose scores are shown on the score key. Returns the selected
+// index or -1 if cancelled.
+func RunRankedSelector(variants []rank.Variant, theme Theme, keys keymap.KeyMap) (int, error) {
+	tty, theme := openTTY(theme)
+	if tty != os.Stdout {
+		defer tty.Close() //nolint:errcheck
+	}
+
+	restore := saveTermState()
+	p := tea.NewProgram(newRankedSelectorModel(variants, theme, keys), tea.WithOutput(tty), tea.WithInputTTY())
+
+	result, err := p.Run()
+	restore()
+	if err != nil {
+		return -1, fmt.Errorf("selector error: %w", err)
+	}
+
+	model, ok := result.(Model)
+	if !ok {
+		return -1, fmt.Errorf("unexpected model type: %T", result)
+	}
+	return model.selectedIndex, nil
+}
+
+// newRankedSelectorModel returns a selector over the commands of variants
+// that keeps their scores.
+func newRankedSelectorModel(variants []rank.Variant, theme Theme, keys keymap.KeyMap) Model {
+	items := make([]string, len(variants))
+	scores := make([]rank.Score, len(variants))
+	for i, v := range variants {
+		items[i] = v.Command
+		scores[i] = v.Score
+	}
+	m := newSelectorModel(items, func(i int) string { return items[i] }, theme)
+	m.keys = newKeyBindings(keys)
+	m.scores = scores
+	return m
+}
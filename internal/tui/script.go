@@ -0,0 +1,316 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/evgfitil/qx/internal/keymap"
+	"github.com/evgfitil/qx/internal/llm"
+	"github.com/evgfitil/qx/internal/script"
+)
+
+// ScriptAction is what the user chose to do with a reviewed script.
+type ScriptAction int
+
+const (
+	ScriptCancel ScriptAction = iota
+	ScriptRun
+	ScriptSave
+)
+
+// ScriptOptions configures the script review.
+type ScriptOptions struct {
+	Theme Theme
+	Keys  keymap.KeyMap
+	// Shell picks the default file name when saving.
+	Shell llm.Shell
+}
+
+// ScriptResult is the outcome of the script review. Steps holds every
+// step in the final order, skipped ones included. Path is set for
+// ScriptSave.
+type ScriptResult struct {
+	Action ScriptAction
+	Steps  []script.Step
+	Path   string
+}
+
+// scriptMode tells whether the review list or one of the inputs has focus.
+type scriptMode int
+
+const (
+	scriptModeList scriptMode = iota
+	scriptModeEdit
+	scriptModePath
+)
+
+// scriptKeys holds the bindings of the script review. Navigation and
+// cancel come from the keymap; the step actions are fixed letters since
+// the list has no text input.
+type scriptKeys struct {
+	up       key.Binding
+	down     key.Binding
+	moveUp   key.Binding
+	moveDown key.Binding
+	skip     key.Binding
+	edit     key.Binding
+	run      key.Binding
+	save     key.Binding
+	cancel   key.Binding
+	quit     key.Binding
+}
+
+func newScriptKeys(km keymap.KeyMap) scriptKeys {
+	km = km.WithDefaults()
+	return scriptKeys{
+		up:       key.NewBinding(key.WithKeys(km.Up...)),
+		down:     key.NewBinding(key.WithKeys(km.Down...)),
+		moveUp:   key.NewBinding(key.WithKeys("shift+up", "K")),
+		moveDown: key.NewBinding(key.WithKeys("shift+down", "J")),
+		skip:     key.NewBinding(key.WithKeys(" ")),
+		edit:     key.NewBinding(key.WithKeys("e", "enter")),
+		run:      key.NewBinding(key.WithKeys("r")),
+		save:     key.NewBinding(key.WithKeys("w")),
+		cancel:   key.NewBinding(key.WithKeys(km.Cancel...)),
+		quit:     key.NewBinding(key.WithKeys("q")),
+	}
+}
+
+// scriptModel reviews generated script steps before they are run or saved.
+type scriptModel struct {
+	query  string
+	steps  []script.Step
+	cursor int
+	mode   scriptMode
+	input  textinput.Model
+	shell  llm.Shell
+	theme  Theme
+	keys   scriptKeys
+	width  int
+	err    string
+	result ScriptResult
+	done   bool
+}
+
+func newScriptModel(query string, steps []script.Step, opts ScriptOptions) scriptModel {
+	input := textinput.New()
+	input.Prompt = opts.Theme.Prompt
+	input.PromptStyle = opts.Theme.PromptStyle()
+	input.TextStyle = opts.Theme.newStyle()
+
+	return scriptModel{
+		query: query,
+		steps: append([]script.Step(nil), steps...),
+		input: input,
+		shell: opts.Shell,
+		theme: opts.Theme,
+		keys:  newScriptKeys(opts.Keys),
+	}
+}
+
+// Init implements tea.Model.
+func (m scriptModel) Init() tea.Cmd {
+	return nil
+}
+
+// Update implements tea.Model.
+func (m scriptModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.input.Width = max(msg.Width-lipgloss.Width(m.theme.Pointer)-4, 10)
+		return m, nil
+	case tea.KeyMsg:
+		if msg.Type == tea.KeyCtrlC {
+			return m.finish(ScriptCancel)
+		}
+		switch m.mode {
+		case scriptModeEdit, scriptModePath:
+			return m.updateInput(msg)
+		}
+		return m.updateList(msg)
+	}
+	return m, nil
+}
+
+// updateList handles keys while the step list has focus.
+func (m scriptModel) updateList(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.err = ""
+	switch {
+	case key.Matches(msg, m.keys.cancel), key.Matches(msg, m.keys.quit):
+		return m.finish(ScriptCancel)
+
+	case key.Matches(msg, m.keys.moveUp):
+		if m.cursor > 0 {
+			m.steps[m.cursor-1], m.steps[m.cursor] = m.steps[m.cursor], m.steps[m.cursor-1]
+			m.cursor--
+		}
+	case key.Matches(msg, m.keys.moveDown):
+		if m.cursor < len(m.steps)-1 {
+			m.steps[m.cursor+1], m.steps[m.cursor] = m.steps[m.cursor], m.steps[m.cursor+1]
+			m.cursor++
+		}
+	case key.Matches(msg, m.keys.up):
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case key.Matches(msg, m.keys.down):
+		if m.cursor < len(m.steps)-1 {
+			m.cursor++
+		}
+
+	case key.Matches(msg, m.keys.skip):
+		m.steps[m.cursor].Skip = !m.steps[m.cursor].Skip
+
+	case key.Matches(msg, m.keys.edit):
+		m.mode = scriptModeEdit
+		m.input.SetValue(m.steps[m.cursor].Command)
+		m.input.CursorEnd()
+		return m, m.input.Focus()
+
+	case key.Matches(msg, m.keys.run):
+		if len(script.Active(m.steps)) == 0 {
+			m.err = "every step is skipped"
+			return m, nil
+		}
+		return m.finish(ScriptRun)
+
+	case key.Matches(msg, m.keys.save):
+		if len(script.Active(m.steps)) == 0 {
+			m.err = "every step is skipped"
+			return m, nil
+		}
+		m.mode = scriptModePath
+		m.input.SetValue(script.DefaultName(m.shell))
+		m.input.CursorEnd()
+		return m, m.input.Focus()
+	}
+	return m, nil
+}
+
+// updateInput handles keys while the command or the file name is edited:
+// enter confirms, cancel discards the edit.
+func (m scriptModel) updateInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.cancel):
+		m.mode = scriptModeList
+		m.input.Blur()
+		return m, nil
+
+	case msg.Type == tea.KeyEnter:
+		value := strings.TrimSpace(m.input.Value())
+		if value == "" {
+			return m, nil
+		}
+		m.input.Blur()
+		if m.mode == scriptModePath {
+			m.result.Path = value
+			return m.finish(ScriptSave)
+		}
+		m.steps[m.cursor].Command = value
+		m.mode = scriptModeList
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+func (m scriptModel) finish(action ScriptAction) (tea.Model, tea.Cmd) {
+	m.result.Action = action
+	m.result.Steps = m.steps
+	m.done = true
+	return m, tea.Quit
+}
+
+// View implements tea.Model.
+func (m scriptModel) View() string {
+	if m.done {
+		return ""
+	}
+
+	var content strings.Builder
+	muted := m.theme.MutedStyle()
+
+	content.WriteString(m.theme.PromptStyle().Render(m.theme.Prompt) + m.theme.NormalStyle().Render(m.query))
+	content.WriteString("\n")
+
+	padding := strings.Repeat(" ", lipgloss.Width(m.theme.Pointer))
+	for i, step := range m.steps {
+		pointer := padding
+		if i == m.cursor {
+			pointer = m.theme.Pointer
+		}
+		number := fmt.Sprintf("%d.", i+1)
+		description := step.Description
+		if step.Skip {
+			description += " (skipped)"
+		}
+
+		titleStyle, commandStyle := m.theme.NormalStyle(), m.theme.NormalStyle()
+		switch {
+		case step.Skip:
+			titleStyle, commandStyle = muted, muted
+		case i == m.cursor:
+			titleStyle = m.theme.SelectedStyle()
+		}
+
+		content.WriteString("\n")
+		content.WriteString(pointer + " " + titleStyle.Render(number+" "+description) + "\n")
+		content.WriteString(padding + "    " + commandStyle.Render(step.Command))
+		content.WriteString("\n")
+	}
+	content.WriteString("\n")
+
+	switch m.mode {
+	case scriptModeEdit:
+		content.WriteString(muted.Render(fmt.Sprintf("edit step %d (enter to confirm, %s to discard)", m.cursor+1, m.keys.cancel.Keys()[0])))
+		content.WriteString("\n" + m.input.View())
+	case scriptModePath:
+		content.WriteString(muted.Render(fmt.Sprintf("save as (enter to confirm, %s to go back)", m.keys.cancel.Keys()[0])))
+		content.WriteString("\n" + m.input.View())
+	default:
+		if m.err != "" {
+			content.WriteString(muted.Render("Error: "+m.err) + "\n")
+		}
+		content.WriteString(muted.Render("r run step by step · w save · e edit · space skip · shift+↑/↓ move · q quit"))
+	}
+
+	borderStyle := m.theme.BorderStyle()
+	if m.width > 0 {
+		borderStyle = borderStyle.Width(m.width - 2)
+	}
+	return borderStyle.Render(content.String()) + "\n"
+}
+
+// RunScript lets the user review, edit, reorder and skip the steps of a
+// generated script, then choose to run or save it.
+func RunScript(query string, steps []script.Step, opts ScriptOptions) (ScriptResult, error) {
+	tty, theme := openTTY(opts.Theme)
+	if tty != os.Stdout {
+		defer tty.Close() //nolint:errcheck
+	}
+	opts.Theme = theme
+
+	restore := saveTermState()
+	p := tea.NewProgram(newScriptModel(query, steps, opts), tea.WithOutput(tty), tea.WithInputTTY())
+
+	result, err := p.Run()
+	restore()
+	if err != nil {
+		return ScriptResult{}, fmt.Errorf("TUI error: %w", err)
+	}
+
+	model, ok := result.(scriptModel)
+	if !ok {
+		return ScriptResult{}, fmt.Errorf("unexpected model type: %T", result)
+	}
+	return model.result, nil
+}
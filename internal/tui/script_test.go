@@ -0,0 +1,189 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/evgfitil/qx/internal/keymap"
+	"github.com/evgfitil/qx/internal/llm"
+	"github.com/evgfitil/qx/internal/script"
+)
+
+func testSteps() []script.Step {
+	return []script.Step{
+		{Description: "Dump the database", Command: "pg_dump app > /tmp/app.sql"},
+		{Description: "Compress the dump", Command: "gzip -f /tmp/app.sql"},
+		{Description: "Upload the dump", Command: "aws s3 cp /tmp/app.sql.gz s3://backups/"},
+	}
+}
+
+func newTestScriptModel(shell llm.Shell) scriptModel {
+	return newScriptModel("back up the app database", testSteps(), ScriptOptions{
+		Theme: DefaultTheme(),
+		Keys:  keymap.Default(),
+		Shell: shell,
+	})
+}
+
+// sendScriptKeys feeds keys to m and returns the resulting model and the
+// command returned by the last one.
+func sendScriptKeys(t *testing.T, m scriptModel, keys ...tea.KeyMsg) (scriptModel, tea.Cmd) {
+	t.Helper()
+	var cmd tea.Cmd
+	for _, k := range keys {
+		var updated tea.Model
+		updated, cmd = m.Update(k)
+		m = updated.(scriptModel)
+	}
+	return m, cmd
+}
+
+func runeKey(s string) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+func stepCommands(steps []script.Step) []string {
+	result := make([]string, len(steps))
+	for i, s := range steps {
+		result[i] = s.Command
+	}
+	return result
+}
+
+func TestScriptModel_Reorder(t *testing.T) {
+	m, _ := sendScriptKeys(t, newTestScriptModel(llm.ShellBash),
+		tea.KeyMsg{Type: tea.KeyDown},
+		tea.KeyMsg{Type: tea.KeyShiftUp},
+	)
+	if m.cursor != 0 {
+		t.Errorf("cursor = %d, want 0 (follows the moved step)", m.cursor)
+	}
+	got := strings.Join(stepCommands(m.steps), "|")
+	want := "gzip -f /tmp/app.sql|pg_dump app > /tmp/app.sql|aws s3 cp /tmp/app.sql.gz s3://backups/"
+	if got != want {
+		t.Errorf("steps = %s, want %s", got, want)
+	}
+
+	m, _ = sendScriptKeys(t, m, runeKey("J"), runeKey("J"), runeKey("J"))
+	if m.cursor != 2 || m.steps[2].Command != "gzip -f /tmp/app.sql" {
+		t.Errorf("J should move the step down to the end, got cursor %d and steps %v", m.cursor, stepCommands(m.steps))
+	}
+}
+
+func TestScriptModel_Skip(t *testing.T) {
+	m, _ := sendScriptKeys(t, newTestScriptModel(llm.ShellBash),
+		tea.KeyMsg{Type: tea.KeyDown},
+		tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}},
+	)
+	if !m.steps[1].Skip {
+		t.Fatal("space should skip the step under the cursor")
+	}
+	if view := m.View(); !strings.Contains(view, "Compress the dump (skipped)") {
+		t.Errorf("View() should mark the skipped step, got:\n%s", view)
+	}
+
+	m, _ = sendScriptKeys(t, m, tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}})
+	if m.steps[1].Skip {
+		t.Error("space again should include the step")
+	}
+}
+
+func TestScriptModel_Edit(t *testing.T) {
+	m, _ := sendScriptKeys(t, newTestScriptModel(llm.ShellBash), runeKey("e"))
+	if m.mode != scriptModeEdit || m.input.Value() != "pg_dump app > /tmp/app.sql" {
+		t.Fatalf("e should edit the command, got mode %d and input %q", m.mode, m.input.Value())
+	}
+
+	m, _ = sendScriptKeys(t, m, tea.KeyMsg{Type: tea.KeyBackspace}, tea.KeyMsg{Type: tea.KeyBackspace},
+		tea.KeyMsg{Type: tea.KeyBackspace}, runeKey("dump"), tea.KeyMsg{Type: tea.KeyEnter})
+	if m.mode != scriptModeList {
+		t.Errorf("enter should return to the list, mode = %d", m.mode)
+	}
+	if m.steps[0].Command != "pg_dump app > /tmp/app.dump" {
+		t.Errorf("edited command = %q, want %q", m.steps[0].Command, "pg_dump app > /tmp/app.dump")
+	}
+
+	m, _ = sendScriptKeys(t, m, tea.KeyMsg{Type: tea.KeyEnter}, runeKey(" --verbose"), tea.KeyMsg{Type: tea.KeyEsc})
+	if m.mode != scriptModeList || m.steps[0].Command != "pg_dump app > /tmp/app.dump" {
+		t.Errorf("esc should discard the edit, got mode %d and command %q", m.mode, m.steps[0].Command)
+	}
+}
+
+func TestScriptModel_Run(t *testing.T) {
+	m, cmd := sendScriptKeys(t, newTestScriptModel(llm.ShellBash), tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}}, runeKey("r"))
+	if cmd == nil || !m.done {
+		t.Fatal("r should quit the review")
+	}
+	if m.result.Action != ScriptRun {
+		t.Errorf("Action = %d, want ScriptRun", m.result.Action)
+	}
+	if len(m.result.Steps) != 3 || !m.result.Steps[0].Skip {
+		t.Errorf("Steps = %+v, want every step with the first skipped", m.result.Steps)
+	}
+}
+
+func TestScriptModel_RunAllSkipped(t *testing.T) {
+	space := tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}}
+	down := tea.KeyMsg{Type: tea.KeyDown}
+	m, _ := sendScriptKeys(t, newTestScriptModel(llm.ShellBash), space, down, space, down, space, runeKey("r"))
+	if m.done {
+		t.Fatal("run should be refused when every step is skipped")
+	}
+	if view := m.View(); !strings.Contains(view, "every step is skipped") {
+		t.Errorf("View() should explain why, got:\n%s", view)
+	}
+}
+
+func TestScriptModel_Save(t *testing.T) {
+	tests := []struct {
+		name  string
+		shell llm.Shell
+		want  string
+	}{
+		{"bash", llm.ShellBash, "script.sh"},
+		{"fish", llm.ShellFish, "script.fish"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := sendScriptKeys(t, newTestScriptModel(tt.shell), runeKey("w"))
+			if m.mode != scriptModePath || m.input.Value() != tt.want {
+				t.Fatalf("w should ask for a path, got mode %d and input %q", m.mode, m.input.Value())
+			}
+
+			m, _ = sendScriptKeys(t, m, tea.KeyMsg{Type: tea.KeyEnter})
+			if !m.done || m.result.Action != ScriptSave || m.result.Path != tt.want {
+				t.Errorf("result = %+v, want ScriptSave to %s", m.result, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptModel_Cancel(t *testing.T) {
+	tests := []struct {
+		name string
+		key  tea.KeyMsg
+	}{
+		{"esc", tea.KeyMsg{Type: tea.KeyEsc}},
+		{"q", runeKey("q")},
+		{"ctrl+c", tea.KeyMsg{Type: tea.KeyCtrlC}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, cmd := sendScriptKeys(t, newTestScriptModel(llm.ShellBash), tt.key)
+			if cmd == nil || m.result.Action != ScriptCancel {
+				t.Errorf("%s should cancel the review, got %+v", tt.name, m.result)
+			}
+		})
+	}
+}
+
+func TestScriptModel_View(t *testing.T) {
+	view := newTestScriptModel(llm.ShellBash).View()
+	for _, want := range []string{"back up the app database", "1. Dump the database", "gzip -f /tmp/app.sql", "3. Upload the dump", "r run step by step"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("View() missing %q:\n%s", want, view)
+		}
+	}
+}
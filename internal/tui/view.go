@@ -13,12 +13,23 @@ func (m Model) View() string {
 		return ""
 	}
 
+	if m.showHelp {
+		return m.viewHelp()
+	}
+
 	var b strings.Builder
 
 	switch m.state {
 	case stateInput:
 		b.WriteString(m.textArea.View())
 		b.WriteString("\n")
+		if m.recall.searching {
+			b.WriteString(m.viewSearch())
+		}
+		if m.pipeSummary != "" {
+			b.WriteString(m.theme.MutedStyle().Render("stdin: " + m.pipeSummary))
+			b.WriteString("\n")
+		}
 		if m.err != nil {
 			b.WriteString(m.theme.MutedStyle().Render(fmt.Sprintf("Error: %v", m.err)))
 			b.WriteString("\n")
@@ -26,7 +37,8 @@ func (m Model) View() string {
 
 	case stateLoading:
 		b.WriteString(m.spinner.View())
-		b.WriteString(m.theme.MutedStyle().Render(" Generating commands..."))
+		b.WriteString(m.theme.MutedStyle().Render(
+			fmt.Sprintf(" Generating commands... (%s to cancel)", m.keys.keymap.Cancel[0])))
 		b.WriteString("\n")
 
 	case stateSelect:
@@ -56,10 +68,14 @@ func (m Model) viewSelector() string {
 
 	for i := m.scrollOffset; i < end; i++ {
 		displayText := m.getDisplayText(i)
+		score := ""
+		if s, ok := m.scoreOf(i); ok && m.showScores {
+			score = m.theme.MutedStyle().Render(fmt.Sprintf("%.2f", s.Total)) + " "
+		}
 		if i == m.cursor {
-			content.WriteString(m.theme.Pointer + " " + m.theme.SelectedStyle().Render(displayText))
+			content.WriteString(m.theme.Pointer + " " + score + m.theme.SelectedStyle().Render(displayText))
 		} else {
-			content.WriteString(padding + " " + m.theme.NormalStyle().Render(displayText))
+			content.WriteString(padding + " " + score + m.theme.NormalStyle().Render(displayText))
 		}
 		content.WriteString("\n")
 	}
@@ -68,7 +84,15 @@ func (m Model) viewSelector() string {
 	if m.selectorMode {
 		total = len(m.items)
 	}
-	content.WriteString(m.theme.MutedStyle().Render(fmt.Sprintf("%d/%d", len(m.filtered), total)))
+	counter := fmt.Sprintf("%d/%d", len(m.filtered), total)
+	if m.cached {
+		counter += " · cached"
+	}
+	if s, ok := m.scoreOf(m.cursor); ok && m.showScores {
+		counter += " · " + s.String()
+	}
+	content.WriteString(m.theme.MutedStyle().Render(counter))
+	content.WriteString(m.viewPreview())
 
 	borderStyle := m.theme.BorderStyle()
 	if m.width > 0 {
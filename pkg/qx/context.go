@@ -0,0 +1,26 @@
+package qx
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/evgfitil/qx/internal/llm"
+)
+
+// CollectContext gathers the blocks of all providers in order.
+func CollectContext(ctx context.Context, providers []ContextProvider) ([]ContextBlock, error) {
+	var blocks []ContextBlock
+	for _, p := range providers {
+		b, err := p.Collect(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s context: %w", p.Name(), err)
+		}
+		blocks = append(blocks, b...)
+	}
+	return blocks, nil
+}
+
+// FormatContext renders blocks exactly as they are sent to the model.
+func FormatContext(blocks []ContextBlock) string {
+	return llm.FormatContext(toLLMBlocks(blocks))
+}
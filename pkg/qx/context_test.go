@@ -0,0 +1,48 @@
+package qx
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type stubContext struct {
+	name   string
+	blocks []ContextBlock
+	err    error
+}
+
+func (p stubContext) Name() string { return p.name }
+
+func (p stubContext) Collect(context.Context) ([]ContextBlock, error) { return p.blocks, p.err }
+
+func TestCollectContext(t *testing.T) {
+	blocks, err := CollectContext(context.Background(), []ContextProvider{
+		stubContext{name: "a", blocks: []ContextBlock{{Name: "a", Content: "1"}}},
+		stubContext{name: "empty"},
+		stubContext{name: "b", blocks: []ContextBlock{{Name: "b", Content: "2"}, {Name: "b", Content: "3"}}},
+	})
+	if err != nil {
+		t.Fatalf("CollectContext() error = %v", err)
+	}
+
+	var got string
+	for _, b := range blocks {
+		got += b.Content
+	}
+	if got != "123" {
+		t.Errorf("blocks = %v, want provider order preserved", blocks)
+	}
+}
+
+func TestCollectContext_Error(t *testing.T) {
+	errBoom := errors.New("boom")
+	_, err := CollectContext(context.Background(), []ContextProvider{stubContext{name: "git", err: errBoom}})
+
+	if !errors.Is(err, errBoom) {
+		t.Fatalf("CollectContext() error = %v, want wrapped provider error", err)
+	}
+	if err.Error() != "git context: boom" {
+		t.Errorf("error = %q, want provider name prefix", err)
+	}
+}
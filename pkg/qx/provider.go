@@ -0,0 +1,102 @@
+package qx
+
+import (
+	"context"
+
+	"github.com/evgfitil/qx/internal/llm"
+)
+
+// NewProvider returns the OpenAI-compatible provider for cfg. Providers
+// are created once per configuration and reused for the lifetime of the
+// process.
+func NewProvider(cfg ProviderConfig) (Provider, error) {
+	p, err := llm.NewProvider(llm.Config(cfg))
+	if err != nil {
+		return nil, err
+	}
+	return llmProvider{p}, nil
+}
+
+// Prewarm opens a connection to the API of cfg in the shared HTTP client,
+// so the first request skips DNS, TCP and TLS setup.
+func Prewarm(ctx context.Context, cfg ProviderConfig) error {
+	return llm.Prewarm(ctx, llm.Config(cfg))
+}
+
+// llmProvider adapts the internal provider to Provider.
+type llmProvider struct {
+	p llm.Provider
+}
+
+func (a llmProvider) Generate(ctx context.Context, r ProviderRequest) ([]Variant, error) {
+	variants, err := a.p.Generate(ctx, toLLMRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Variant, len(variants))
+	for i, v := range variants {
+		out[i] = Variant(v)
+	}
+	return out, nil
+}
+
+func (a llmProvider) Explain(ctx context.Context, r ExplainRequest) (*Explanation, error) {
+	e, err := a.p.Explain(ctx, llm.ExplainRequest{
+		Command: r.Command,
+		Shell:   llm.Shell(r.Shell),
+		Context: toLLMBlocks(r.Context),
+	})
+	if err != nil || e == nil {
+		return nil, err
+	}
+	out := &Explanation{
+		Summary:  e.Summary,
+		Stages:   make([]Stage, len(e.Stages)),
+		Warnings: e.Warnings,
+	}
+	for i, s := range e.Stages {
+		out.Stages[i] = Stage{Command: s.Command, Description: s.Description, Flags: make([]Flag, len(s.Flags))}
+		for j, f := range s.Flags {
+			out.Stages[i].Flags[j] = Flag(f)
+		}
+	}
+	return out, nil
+}
+
+func (a llmProvider) GenerateScript(ctx context.Context, r ProviderRequest) ([]ScriptStep, error) {
+	steps, err := a.p.GenerateScript(ctx, toLLMRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ScriptStep, len(steps))
+	for i, s := range steps {
+		out[i] = ScriptStep(s)
+	}
+	return out, nil
+}
+
+func toLLMRequest(r ProviderRequest) llm.Request {
+	req := llm.Request{
+		Query:       r.Query,
+		Count:       r.Count,
+		Shell:       llm.Shell(r.Shell),
+		PipeContext: r.PipeContext,
+		Context:     toLLMBlocks(r.Context),
+	}
+	if r.FollowUp != nil {
+		f := llm.FollowUpContext(*r.FollowUp)
+		req.FollowUp = &f
+	}
+	return req
+}
+
+func toLLMBlocks(blocks []ContextBlock) []llm.ContextBlock {
+	if blocks == nil {
+		return nil
+	}
+	out := make([]llm.ContextBlock, len(blocks))
+	for i, b := range blocks {
+		out[i] = llm.ContextBlock(b)
+	}
+	return out
+}
@@ -0,0 +1,91 @@
+package qx
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/evgfitil/qx/internal/llm"
+)
+
+// fakeLLM is an internal provider that records the requests it gets.
+type fakeLLM struct {
+	got        llm.Request
+	gotExplain llm.ExplainRequest
+}
+
+func (f *fakeLLM) Generate(_ context.Context, r llm.Request) ([]llm.Variant, error) {
+	f.got = r
+	return []llm.Variant{{Command: "ls", Confidence: 0.8, Cached: true}}, nil
+}
+
+func (f *fakeLLM) Explain(_ context.Context, r llm.ExplainRequest) (*llm.Explanation, error) {
+	f.gotExplain = r
+	return &llm.Explanation{
+		Summary:  "Lists files",
+		Stages:   []llm.Stage{{Command: "ls", Description: "list", Flags: []llm.Flag{{Flag: "-l", Description: "long"}}}},
+		Warnings: []string{"none"},
+	}, nil
+}
+
+func (f *fakeLLM) GenerateScript(_ context.Context, r llm.Request) ([]llm.ScriptStep, error) {
+	f.got = r
+	return []llm.ScriptStep{{Description: "build", Command: "make"}}, nil
+}
+
+func TestLLMProvider(t *testing.T) {
+	f := &fakeLLM{}
+	p := llmProvider{f}
+	ctx := context.Background()
+	blocks := []ContextBlock{{Name: "git", Source: "repo", Content: "main", Rule: "r"}}
+
+	variants, err := p.Generate(ctx, ProviderRequest{
+		Query:    "fix it",
+		Count:    2,
+		Shell:    ShellZsh,
+		FollowUp: &FollowUp{PreviousCommand: "mkae", ExitCode: 127, ErrorOutput: "not found"},
+		Context:  blocks,
+	})
+	if err != nil || len(variants) != 1 || variants[0] != (Variant{Command: "ls", Confidence: 0.8, Cached: true}) {
+		t.Fatalf("Generate() = %+v, %v", variants, err)
+	}
+	if f.got.Query != "fix it" || f.got.Count != 2 || f.got.Shell != llm.ShellZsh ||
+		f.got.FollowUp == nil || f.got.FollowUp.ExitCode != 127 || f.got.FollowUp.ErrorOutput != "not found" ||
+		len(f.got.Context) != 1 || f.got.Context[0] != llm.ContextBlock(blocks[0]) {
+		t.Errorf("provider got %+v", f.got)
+	}
+
+	e, err := p.Explain(ctx, ExplainRequest{Command: "ls -l", Shell: ShellFish, Context: blocks})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.gotExplain.Command != "ls -l" || f.gotExplain.Shell != llm.ShellFish || len(f.gotExplain.Context) != 1 {
+		t.Errorf("provider got %+v", f.gotExplain)
+	}
+	if e.Summary != "Lists files" || len(e.Stages) != 1 || e.Stages[0].Flags[0] != (Flag{Flag: "-l", Description: "long"}) || e.Warnings[0] != "none" {
+		t.Errorf("Explain() = %+v", e)
+	}
+
+	steps, err := p.GenerateScript(ctx, ProviderRequest{Query: "build"})
+	if err != nil || len(steps) != 1 || steps[0] != (ScriptStep{Description: "build", Command: "make"}) {
+		t.Errorf("GenerateScript() = %+v, %v", steps, err)
+	}
+}
+
+func TestClassifyRisk_JSON(t *testing.T) {
+	data, err := json.Marshal(ClassifyRisk("ls -la"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), `{"level":"low","reasons":["only reads state"]}`; got != want {
+		t.Errorf("ClassifyRisk() JSON = %s, want %s", got, want)
+	}
+
+	var r Risk
+	if err := json.Unmarshal([]byte(`{"level":"high"}`), &r); err != nil || r.Level != RiskHigh {
+		t.Errorf("decoded %+v, %v, want high", r, err)
+	}
+	if err := json.Unmarshal([]byte(`{"level":"severe"}`), &r); err == nil {
+		t.Error("decoding an unknown level should fail")
+	}
+}
@@ -0,0 +1,382 @@
+// Package qx generates shell commands from natural language descriptions.
+// It is the pipeline behind the qx CLI for programs that embed it: queries
+// are checked for secrets, extra context is collected, the LLM is asked
+// for variants and its output is sanitized before it is returned.
+//
+// A Client needs a Provider, either one created from a ProviderConfig
+// with WithConfig or any implementation passed to WithProvider:
+//
+//	client, err := qx.New(qx.WithConfig(qx.ProviderConfig{
+//		BaseURL: "https://api.openai.com/v1",
+//		APIKey:  os.Getenv("OPENAI_API_KEY"),
+//		Model:   "gpt-4o-mini",
+//		Count:   3,
+//	}))
+//	if err != nil {
+//		return err
+//	}
+//	variants, err := client.Generate(ctx, qx.Request{Query: "find files larger than 1GB"})
+package qx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/evgfitil/qx/internal/guard"
+	"github.com/evgfitil/qx/internal/history"
+)
+
+// DefaultContextTimeout bounds how long collecting context may delay a
+// request.
+const DefaultContextTimeout = 5 * time.Second
+
+// ErrNoCommands is returned when the LLM returned no commands.
+var ErrNoCommands = errors.New("no commands generated")
+
+// GuardPolicy decides what happens to text that contains secrets.
+type GuardPolicy int
+
+const (
+	// GuardBlock rejects text with secrets with a *SecretsError before
+	// anything is sent. It is the default.
+	GuardBlock GuardPolicy = iota
+	// GuardAllow sends text with secrets anyway, like --force-send.
+	GuardAllow
+)
+
+// Client runs the generation pipeline. It is safe for concurrent use if
+// its Provider and ContextProviders are.
+type Client struct {
+	provider       Provider
+	config         *ProviderConfig
+	policy         GuardPolicy
+	history        *history.Store
+	contexts       []ContextProvider
+	contextTimeout time.Duration
+	shell          Shell
+	count          int
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithProvider makes the client use p.
+func WithProvider(p Provider) Option {
+	return func(c *Client) { c.provider = p }
+}
+
+// WithConfig makes the client create an OpenAI-compatible provider from
+// cfg, unless WithProvider is given too. cfg.Count becomes the default
+// number of variants.
+func WithConfig(cfg ProviderConfig) Option {
+	return func(c *Client) {
+		c.config = &cfg
+		if c.count == 0 {
+			c.count = cfg.Count
+		}
+	}
+}
+
+// WithGuardPolicy sets what happens to queries with secrets.
+func WithGuardPolicy(p GuardPolicy) Option {
+	return func(c *Client) { c.policy = p }
+}
+
+// WithHistory keeps the history in dir, the directory of history.json;
+// the CLI uses ~/.config/qx.
+func WithHistory(dir string) Option {
+	return func(c *Client) { c.history = history.NewStore(dir) }
+}
+
+// WithContextProviders adds providers whose blocks are sent with every
+// request, in order.
+func WithContextProviders(providers ...ContextProvider) Option {
+	return func(c *Client) { c.contexts = append(c.contexts, providers...) }
+}
+
+// WithContextTimeout bounds how long collecting context may take;
+// DefaultContextTimeout when not set.
+func WithContextTimeout(d time.Duration) Option {
+	return func(c *Client) { c.contextTimeout = d }
+}
+
+// WithShell sets the dialect of generated commands; ShellPOSIX when not
+// set.
+func WithShell(s Shell) Option {
+	return func(c *Client) { c.shell = s }
+}
+
+// WithCount sets the default number of variants to generate.
+func WithCount(n int) Option {
+	return func(c *Client) { c.count = n }
+}
+
+// New creates a Client. It fails without a provider or a config to
+// create one from.
+func New(opts ...Option) (*Client, error) {
+	c := &Client{contextTimeout: DefaultContextTimeout}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.provider == nil {
+		if c.config == nil {
+			return nil, errors.New("qx: a provider or a provider config is required")
+		}
+		p, err := NewProvider(*c.config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create LLM provider: %w", err)
+		}
+		c.provider = p
+	}
+	if c.count < 1 {
+		c.count = 1
+	}
+	return c, nil
+}
+
+// Shell returns the dialect of the generated commands.
+func (c *Client) Shell() Shell {
+	return c.shell
+}
+
+// ForShell returns a copy of the client that generates and explains
+// commands for shell.
+func (c *Client) ForShell(shell Shell) *Client {
+	clone := *c
+	clone.shell = shell
+	return &clone
+}
+
+// Request describes a generation.
+type Request struct {
+	Query string
+	// PipeContext is input the command will process, as piped into qx.
+	PipeContext string
+	// FollowUp refines or fixes a previous command.
+	FollowUp *FollowUp
+	// Count overrides the number of variants when positive.
+	Count int
+	// Shell overrides the client's dialect when set.
+	Shell Shell
+}
+
+// Generate returns command variants for req in the order the model
+// ranked them. The query, piped input and error output of a failed
+// command are checked for secrets first.
+func (c *Client) Generate(ctx context.Context, req Request) ([]Variant, error) {
+	preq, err := c.prepare(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	variants, err := c.provider.Generate(ctx, preq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate commands: %w", err)
+	}
+	for i := range variants {
+		variants[i].Command = guard.SanitizeOutput(variants[i].Command)
+	}
+	if len(variants) == 0 {
+		return nil, ErrNoCommands
+	}
+	return variants, nil
+}
+
+// GenerateScript breaks the task in req down into ordered steps, with
+// their descriptions and commands sanitized. req.Count and req.FollowUp
+// are ignored.
+func (c *Client) GenerateScript(ctx context.Context, req Request) ([]ScriptStep, error) {
+	req.FollowUp = nil
+	preq, err := c.prepare(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	steps, err := c.provider.GenerateScript(ctx, preq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate script: %w", err)
+	}
+	for i := range steps {
+		steps[i].Description = guard.SanitizeOutput(steps[i].Description)
+		steps[i].Command = guard.SanitizeOutput(steps[i].Command)
+	}
+	return steps, nil
+}
+
+// Explain breaks command down into its pipeline stages and flags, with
+// every text sanitized. Use ClassifyRisk for its risk, which is computed
+// locally.
+func (c *Client) Explain(ctx context.Context, command string) (*Explanation, error) {
+	if err := c.CheckSecrets(command); err != nil {
+		return nil, err
+	}
+	blocks, err := c.collectContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	explanation, err := c.provider.Explain(ctx, ExplainRequest{Command: command, Shell: c.shell, Context: blocks})
+	if err != nil {
+		return nil, fmt.Errorf("failed to explain command: %w", err)
+	}
+	if explanation == nil {
+		return &Explanation{}, nil
+	}
+	return sanitizeExplanation(explanation), nil
+}
+
+// sanitizeExplanation removes control characters from every text of e.
+func sanitizeExplanation(e *Explanation) *Explanation {
+	clean := guard.SanitizeOutput
+	out := &Explanation{
+		Summary:  clean(e.Summary),
+		Stages:   make([]Stage, len(e.Stages)),
+		Warnings: make([]string, len(e.Warnings)),
+	}
+	for i, s := range e.Stages {
+		stage := Stage{Command: clean(s.Command), Description: clean(s.Description), Flags: make([]Flag, len(s.Flags))}
+		for j, f := range s.Flags {
+			stage.Flags[j] = Flag{Flag: clean(f.Flag), Description: clean(f.Description)}
+		}
+		out.Stages[i] = stage
+	}
+	for i, w := range e.Warnings {
+		out.Warnings[i] = clean(w)
+	}
+	return out
+}
+
+// prepare checks req for secrets and turns it into a provider request
+// with the collected context.
+func (c *Client) prepare(ctx context.Context, req Request) (ProviderRequest, error) {
+	text := req.Query + "\n" + req.PipeContext
+	if req.FollowUp != nil {
+		text += "\n" + req.FollowUp.ErrorOutput
+	}
+	if err := c.CheckSecrets(text); err != nil {
+		return ProviderRequest{}, err
+	}
+	blocks, err := c.collectContext(ctx)
+	if err != nil {
+		return ProviderRequest{}, err
+	}
+
+	preq := ProviderRequest{
+		Query:       req.Query,
+		Count:       c.count,
+		Shell:       c.shell,
+		PipeContext: req.PipeContext,
+		FollowUp:    req.FollowUp,
+		Context:     blocks,
+	}
+	if req.Count > 0 {
+		preq.Count = req.Count
+	}
+	if req.Shell != ShellPOSIX {
+		preq.Shell = req.Shell
+	}
+	return preq, nil
+}
+
+func (c *Client) collectContext(ctx context.Context) ([]ContextBlock, error) {
+	if len(c.contexts) == 0 {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.contextTimeout)
+	defer cancel()
+	return CollectContext(ctx, c.contexts)
+}
+
+// CheckSecrets returns a *SecretsError if text contains secrets and the
+// client's policy blocks them.
+func (c *Client) CheckSecrets(text string) error {
+	return CheckSecrets(text, c.policy)
+}
+
+// CheckSecrets returns a *SecretsError if text contains secrets and
+// policy blocks them.
+func CheckSecrets(text string, policy GuardPolicy) error {
+	if policy == GuardAllow {
+		return nil
+	}
+	if detected := DetectSecrets(text); len(detected) > 0 {
+		return &SecretsError{Detected: detected}
+	}
+	return nil
+}
+
+// DetectSecrets lists the secrets in text.
+func DetectSecrets(text string) []Detection {
+	var detected []Detection
+	for _, d := range guard.New().Check(text).Detected {
+		detected = append(detected, Detection(d))
+	}
+	return detected
+}
+
+// ClassifyRisk rates command by the most dangerous thing it does.
+func ClassifyRisk(command string) Risk {
+	r := guard.ClassifyRisk(command)
+	return Risk{Level: RiskLevel(r.Level), Reasons: append([]string{}, r.Reasons...)}
+}
+
+// Rate pairs command with confidence and its risk.
+func Rate(command string, confidence float64) RatedCommand {
+	return RatedCommand{Command: command, Confidence: confidence, Risk: ClassifyRisk(command)}
+}
+
+// Record adds the command picked for query to the history. It does
+// nothing without WithHistory.
+func (c *Client) Record(query, selected, pipeContext string) error {
+	if c.history == nil {
+		return nil
+	}
+	return c.history.Add(history.Entry{
+		Query:       query,
+		Selected:    selected,
+		PipeContext: pipeContext,
+		Timestamp:   time.Now(),
+	})
+}
+
+// History returns the history, newest first; nothing without WithHistory.
+func (c *Client) History() ([]HistoryEntry, error) {
+	if c.history == nil {
+		return nil, nil
+	}
+	return fromHistory(c.history.List())
+}
+
+// SearchHistory returns the history entries whose query or command
+// contains term, ignoring case, newest first.
+func (c *Client) SearchHistory(term string) ([]HistoryEntry, error) {
+	if c.history == nil {
+		return nil, nil
+	}
+	return fromHistory(c.history.Search(term))
+}
+
+// redacted replaces a history text that contains secrets.
+const redacted = "[redacted]"
+
+// Redacted returns e with every text that contains secrets replaced by
+// "[redacted]", for entries that leave the machine. Entries recorded with
+// GuardAllow may contain exactly the secrets the guard would block.
+func (e HistoryEntry) Redacted() HistoryEntry {
+	for _, field := range []*string{&e.Query, &e.Selected, &e.PipeContext} {
+		if len(DetectSecrets(*field)) > 0 {
+			*field = redacted
+		}
+	}
+	return e
+}
+
+func fromHistory(entries []history.Entry, err error) ([]HistoryEntry, error) {
+	if err != nil {
+		return nil, err
+	}
+	out := make([]HistoryEntry, len(entries))
+	for i, e := range entries {
+		out[i] = HistoryEntry(e)
+	}
+	return out, nil
+}
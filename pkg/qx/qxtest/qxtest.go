@@ -0,0 +1,50 @@
+// Package qxtest provides a qx.Provider for testing code built on qx,
+// without an LLM.
+package qxtest
+
+import (
+	"context"
+
+	"github.com/evgfitil/qx/pkg/qx"
+)
+
+// Provider answers with fixed results and records the last request each
+// method got; a request field is nil until its method is called.
+type Provider struct {
+	Variants    []qx.Variant
+	Explanation *qx.Explanation
+	Steps       []qx.ScriptStep
+	// Err is returned by every method when set.
+	Err error
+
+	Got        *qx.ProviderRequest
+	GotScript  *qx.ProviderRequest
+	GotExplain *qx.ExplainRequest
+}
+
+// Generate records r and returns p.Variants.
+func (p *Provider) Generate(_ context.Context, r qx.ProviderRequest) ([]qx.Variant, error) {
+	p.Got = &r
+	if p.Err != nil {
+		return nil, p.Err
+	}
+	return append([]qx.Variant(nil), p.Variants...), nil
+}
+
+// Explain records r and returns p.Explanation.
+func (p *Provider) Explain(_ context.Context, r qx.ExplainRequest) (*qx.Explanation, error) {
+	p.GotExplain = &r
+	if p.Err != nil {
+		return nil, p.Err
+	}
+	return p.Explanation, nil
+}
+
+// GenerateScript records r and returns p.Steps.
+func (p *Provider) GenerateScript(_ context.Context, r qx.ProviderRequest) ([]qx.ScriptStep, error) {
+	p.GotScript = &r
+	if p.Err != nil {
+		return nil, p.Err
+	}
+	return append([]qx.ScriptStep(nil), p.Steps...), nil
+}
@@ -0,0 +1,213 @@
+package qx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Shell is the dialect commands are generated for.
+type Shell string
+
+// Shell dialects; ShellPOSIX works in all of them.
+const (
+	ShellPOSIX Shell = ""
+	ShellBash  Shell = "bash"
+	ShellZsh   Shell = "zsh"
+	ShellFish  Shell = "fish"
+)
+
+// ParseShell validates a shell name. An empty name yields ShellPOSIX.
+func ParseShell(name string) (Shell, error) {
+	switch s := Shell(name); s {
+	case ShellPOSIX, ShellBash, ShellZsh, ShellFish:
+		return s, nil
+	}
+	return ShellPOSIX, fmt.Errorf("unsupported shell: %s (supported: bash, zsh, fish)", name)
+}
+
+// Provider generates, explains and scripts commands with an LLM.
+type Provider interface {
+	Generate(ctx context.Context, req ProviderRequest) ([]Variant, error)
+	Explain(ctx context.Context, req ExplainRequest) (*Explanation, error)
+	GenerateScript(ctx context.Context, req ProviderRequest) ([]ScriptStep, error)
+}
+
+// ProviderConfig configures the OpenAI-compatible provider.
+type ProviderConfig struct {
+	BaseURL string
+	APIKey  string
+	Model   string
+	// Provider is the provider name from the qx configuration.
+	Provider string
+	// Count is the default number of variants to generate.
+	Count int
+
+	// CacheDir enables the response cache in this directory when set.
+	CacheDir        string
+	CacheTTL        time.Duration
+	CacheMaxEntries int
+}
+
+// ProviderRequest is what a Provider receives for a generation.
+type ProviderRequest struct {
+	Query       string
+	Count       int
+	Shell       Shell
+	PipeContext string
+	FollowUp    *FollowUp
+	Context     []ContextBlock
+}
+
+// ExplainRequest is what a Provider receives for an explanation.
+type ExplainRequest struct {
+	Command string
+	Shell   Shell
+	Context []ContextBlock
+}
+
+// FollowUp refines a previous command or fixes a failed one. A failed
+// command carries its exit code and error output; PreviousQuery is empty
+// when the command was not generated by qx.
+type FollowUp struct {
+	PreviousQuery   string
+	PreviousCommand string
+	ExitCode        int
+	ErrorOutput     string
+}
+
+// Variant is a generated command with the model's confidence, between 0
+// and 1, that it does exactly what was asked.
+type Variant struct {
+	Command    string  `json:"command"`
+	Confidence float64 `json:"confidence"`
+	// Cached reports that the variant was served from the response cache.
+	Cached bool `json:"-"`
+}
+
+// Explanation is a structured breakdown of a command.
+type Explanation struct {
+	Summary  string   `json:"summary"`
+	Stages   []Stage  `json:"stages"`
+	Warnings []string `json:"warnings"`
+}
+
+// Stage is one command of a pipeline or command list.
+type Stage struct {
+	Command     string `json:"command"`
+	Description string `json:"description"`
+	Flags       []Flag `json:"flags"`
+}
+
+// Flag explains a single flag or argument of a stage.
+type Flag struct {
+	Flag        string `json:"flag"`
+	Description string `json:"description"`
+}
+
+// ScriptStep is one command of a multi-step script.
+type ScriptStep struct {
+	Description string `json:"description"`
+	Command     string `json:"command"`
+}
+
+// ContextBlock is a named piece of context sent with a query, such as a
+// description of the user's environment.
+type ContextBlock struct {
+	Name    string // tag the content is wrapped in, e.g. "environment"
+	Source  string // optional origin of the content, e.g. a file path
+	Content string
+	Rule    string // optional instruction added to the system prompt
+}
+
+// ContextProvider supplies context blocks, such as the environment.
+type ContextProvider interface {
+	// Name identifies the provider.
+	Name() string
+	// Collect returns the blocks to send, none when there is nothing to add.
+	Collect(ctx context.Context) ([]ContextBlock, error)
+}
+
+// Detection is a secret found in a text.
+type Detection struct {
+	RuleID      string `json:"rule_id"`
+	Description string `json:"description"`
+}
+
+// SecretsError is returned for a text with secrets under GuardBlock.
+type SecretsError struct {
+	Detected []Detection
+}
+
+func (e *SecretsError) Error() string {
+	descriptions := make([]string, len(e.Detected))
+	for i, d := range e.Detected {
+		descriptions[i] = d.Description
+	}
+	return "secrets detected: " + strings.Join(descriptions, ", ")
+}
+
+// RiskLevel ranks how much damage running a command could do.
+type RiskLevel int
+
+// Risk levels.
+const (
+	// RiskLow commands only read state.
+	RiskLow RiskLevel = iota
+	// RiskMedium commands change state in ways that are usually
+	// recoverable, or could not be verified as read-only.
+	RiskMedium
+	// RiskHigh commands destroy data, rewrite history or affect the whole
+	// system and are hard or impossible to undo.
+	RiskHigh
+)
+
+func (l RiskLevel) String() string {
+	switch l {
+	case RiskLow:
+		return "low"
+	case RiskMedium:
+		return "medium"
+	}
+	return "high"
+}
+
+// MarshalText encodes l as its name, e.g. "low".
+func (l RiskLevel) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText decodes a level encoded by MarshalText.
+func (l *RiskLevel) UnmarshalText(text []byte) error {
+	for _, level := range []RiskLevel{RiskLow, RiskMedium, RiskHigh} {
+		if string(text) == level.String() {
+			*l = level
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown risk level: %s", text)
+}
+
+// Risk rates how much damage running a command could do, with the
+// reasons behind the rating.
+type Risk struct {
+	Level   RiskLevel `json:"level"`
+	Reasons []string  `json:"reasons"`
+}
+
+// RatedCommand is a generated command with the model's confidence and the
+// locally computed risk of running it.
+type RatedCommand struct {
+	Command    string  `json:"command"`
+	Confidence float64 `json:"confidence"`
+	Risk       Risk    `json:"risk"`
+}
+
+// HistoryEntry is a query with the command picked for it.
+type HistoryEntry struct {
+	Query       string    `json:"query"`
+	Selected    string    `json:"selected"`
+	PipeContext string    `json:"pipe_context,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+}